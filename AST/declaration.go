@@ -16,10 +16,42 @@ type DeclarationVariable struct {
 	RHS      Expression
 }
 
+// DeclarationDestructure is var a, b := f(); it declares one variable per value f returns,
+// their types are filled in by the TypeChecker
+type DeclarationDestructure struct {
+	Tok       Token.Token
+	Variables []*DeclarationVariable
+	RHS       Expression
+}
+
+// DeclarationFunction is a function, or a method when it has a Receiver. The receiver, a struct
+// or a pointer to one, is the first of DeclType.Parameters. A generic function has TypeParameters,
+// its body is checked once with them and runs unchanged for every instantiation.
 type DeclarationFunction struct {
-	Tok      Token.Token
-	DeclType *TS.Type
-	Block    *StatementBlock
+	Tok            Token.Token
+	DeclType       *TS.Type
+	Block          *StatementBlock
+	Receiver       *TS.Type
+	TypeParameters []*TS.Type
+}
+
+// Name is what the function is called by, methods are qualified with their struct e.g. Person.greet
+func (d *DeclarationFunction) Name() string {
+	if d.Receiver == nil {
+		return d.Tok.Lexeme
+	}
+
+	base := d.Receiver
+	if base.IsPointer() {
+		base = base.Next
+	}
+
+	return base.String() + "." + d.Tok.Lexeme
+}
+
+// Anonymous reports whether d is the body of a function literal, which is named after its fn keyword
+func (d *DeclarationFunction) Anonymous() bool {
+	return d.Tok.Kind == Token.FN
 }
 
 type Member struct {
@@ -27,8 +59,50 @@ type Member struct {
 	DeclType *TS.Type
 }
 
+// DeclarationStruct is a struct, a generic one has TypeParameters. The TypeChecker declares each
+// instantiation, e.g. Pair[int, string], as a struct of its own with the type arguments substituted.
 type DeclarationStruct struct {
-	Tok          Token.Token
-	Members      []Member
-	MemberLookup map[string]Member
+	Tok            Token.Token
+	Members        []Member
+	MemberLookup   map[string]Member
+	Methods        map[string]*DeclarationFunction // filled in by the TypeChecker
+	TypeParameters []*TS.Type
+	TypeArguments  []*TS.Type
+	Generic        *DeclarationStruct // the generic struct an instantiation is made from
+}
+
+// Name is the struct's type as it reads, with the type arguments of an instantiation
+func (d *DeclarationStruct) Name() string {
+	if d.Generic == nil {
+		return d.Tok.Lexeme
+	}
+
+	return TS.NewStructType(d.Tok.Lexeme, d.TypeArguments).String()
+}
+
+// Origin is the struct d is made from, which is d itself unless it's an instantiation
+func (d *DeclarationStruct) Origin() *DeclarationStruct {
+	if d.Generic == nil {
+		return d
+	}
+
+	return d.Generic
+}
+
+// DeclarationEnum is enum Name { A, B, ... }, a variant's value is its position
+type DeclarationEnum struct {
+	Tok      Token.Token
+	Variants []Token.Token
+}
+
+// DeclarationUnion is union Name { A: T, B: U, ... }, a value is one of the variants
+// together with a payload of that variant's type
+type DeclarationUnion struct {
+	Tok      Token.Token
+	Variants []Member
+}
+
+// DeclarationError stands in for a top level declaration that failed to parse
+type DeclarationError struct {
+	Tok Token.Token
 }
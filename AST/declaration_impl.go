@@ -3,8 +3,20 @@ package AST
 func (*DeclarationVariable) isNode()        {}
 func (*DeclarationVariable) isDeclaration() {}
 
+func (*DeclarationDestructure) isNode()        {}
+func (*DeclarationDestructure) isDeclaration() {}
+
 func (*DeclarationFunction) isNode()        {}
 func (*DeclarationFunction) isDeclaration() {}
 
 func (d DeclarationStruct) isNode()        {}
 func (d DeclarationStruct) isDeclaration() {}
+
+func (*DeclarationEnum) isNode()        {}
+func (*DeclarationEnum) isDeclaration() {}
+
+func (*DeclarationUnion) isNode()        {}
+func (*DeclarationUnion) isDeclaration() {}
+
+func (*DeclarationError) isNode()        {}
+func (*DeclarationError) isDeclaration() {}
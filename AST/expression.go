@@ -10,18 +10,30 @@ type Expression interface {
 	isExpression()
 }
 
+// ExpressionInteger is an integer literal, Kind is its suffix (255u8) or without one the type
+// the TypeChecker infers from where it's used, it's an int while Kind is empty
 type ExpressionInteger struct {
-	Value int
+	Tok   Token.Token
+	Value uint64
+	Kind  TS.TypeKind
 }
 
+// ExpressionFloat is a float literal, a float while Kind is empty, see ExpressionInteger
 type ExpressionFloat struct {
-	Value float32
+	Tok   Token.Token
+	Value float64
+	Kind  TS.TypeKind
 }
 
 type ExpressionString struct {
 	Value string
 }
 
+// ExpressionChar is a character literal, a single Unicode code point
+type ExpressionChar struct {
+	Value rune
+}
+
 type ExpressionBoolean struct {
 	Value bool
 }
@@ -52,6 +64,7 @@ type ExpressionBinary struct {
 }
 
 type ExpressionArray struct {
+	Tok      Token.Token
 	Elements []Expression
 	DeclType *TS.Type
 }
@@ -61,31 +74,73 @@ type ExpressionArrayAccess struct {
 	Index Expression
 }
 
+// ExpressionAccessChain starts from the variable Tok, or from the value of Base when it's set
+// (the result of a call, e.g. unwrap(x).v, with Tok the call's token)
 type ExpressionAccessChain struct {
 	Tok        Token.Token
+	Base       Expression
 	AccessKeys []Expression // if its a struct then its an identifier key, if its a array its a index key
 }
 
+// ExpressionStruct is Name.{...}, a generic struct's type arguments are either given as
+// Name[A, B].{...} or inferred from the members. Decl is the instantiation, set by the TypeChecker.
 type ExpressionStruct struct {
-	Tok          Token.Token
-	MemberValues map[string]Expression
+	Tok           Token.Token
+	MemberValues  map[string]Expression
+	TypeArguments []*TS.Type
+	Decl          *DeclarationStruct
 }
 
-type ExpressionLen struct {
-	Iterable Expression
+// ExpressionEnumValue is Enum.Variant, Index is the variant's position in DeclType.Variants
+type ExpressionEnumValue struct {
+	Tok      Token.Token
+	Variant  Token.Token
+	DeclType *TS.Type
+	Index    int
 }
 
-type PseudoBehavior int
+// ExpressionUnion is Union.{Variant: Payload}, Index is the variant's position in DeclType.Variants
+type ExpressionUnion struct {
+	Tok      Token.Token
+	Variant  Token.Token
+	Index    int
+	Payload  Expression
+	DeclType *TS.Type
+}
 
-const (
-	BREAK PseudoBehavior = iota
-	RETURN
-	CONTINUE
-)
+// ExpressionAddressOf is &Operand, the operand is a variable, an access chain
+// or a struct, union or array literal which gets allocated
+type ExpressionAddressOf struct {
+	Tok     Token.Token
+	Operand Expression
+}
 
-type ExpressionPseudo struct {
-	Expr     Expression
-	Behavior PseudoBehavior
+// ExpressionDereference is *Operand
+type ExpressionDereference struct {
+	Tok     Token.Token
+	Operand Expression
+}
+
+type ExpressionNullptr struct {
+	Tok Token.Token
+}
+
+// ExpressionTuple is (a, b, ...), only ever the value of a return statement
+type ExpressionTuple struct {
+	Tok      Token.Token
+	Elements []Expression
+}
+
+// ExpressionFunction is an anonymous function, fn(x: int) -> int { ... }. Decl is named after
+// the fn keyword, the function captures the variables in scope where it's evaluated.
+type ExpressionFunction struct {
+	Tok  Token.Token
+	Decl *DeclarationFunction
+}
+
+type ExpressionLen struct {
+	Tok      Token.Token
+	Iterable Expression
 }
 
 // Identifier
@@ -6,6 +6,9 @@ func (*ExpressionInteger) isExpression() {}
 func (*ExpressionFloat) isNode()       {}
 func (*ExpressionFloat) isExpression() {}
 
+func (*ExpressionChar) isNode()       {}
+func (*ExpressionChar) isExpression() {}
+
 func (*ExpressionBoolean) isNode()       {}
 func (*ExpressionBoolean) isExpression() {}
 
@@ -36,11 +39,29 @@ func (e ExpressionArrayAccess) isExpression() {}
 func (*ExpressionStruct) isNode()       {}
 func (*ExpressionStruct) isExpression() {}
 
+func (*ExpressionEnumValue) isNode()       {}
+func (*ExpressionEnumValue) isExpression() {}
+
+func (*ExpressionUnion) isNode()       {}
+func (*ExpressionUnion) isExpression() {}
+
 func (*ExpressionAccessChain) isNode()       {}
 func (*ExpressionAccessChain) isExpression() {}
 
+func (*ExpressionAddressOf) isNode()       {}
+func (*ExpressionAddressOf) isExpression() {}
+
+func (*ExpressionDereference) isNode()       {}
+func (*ExpressionDereference) isExpression() {}
+
+func (*ExpressionNullptr) isNode()       {}
+func (*ExpressionNullptr) isExpression() {}
+
+func (*ExpressionTuple) isNode()       {}
+func (*ExpressionTuple) isExpression() {}
+
+func (*ExpressionFunction) isNode()       {}
+func (*ExpressionFunction) isExpression() {}
+
 func (*ExpressionLen) isNode()       {}
 func (*ExpressionLen) isExpression() {}
-
-func (*ExpressionPseudo) isNode()       {}
-func (*ExpressionPseudo) isExpression() {}
@@ -7,13 +7,19 @@ type Statement interface {
 	isStatement()
 }
 
+// StatementAssignment is LHS = RHS, or LHS op= RHS when Operator is set. A compound
+// assignment evaluates LHS's location once and stores LHS op RHS there. x++ and x--
+// are parsed as x += 1 and x -= 1 with Increment set.
 type StatementAssignment struct {
-	Tok Token.Token
-	LHS Expression
-	RHS Expression
+	Tok       Token.Token
+	LHS       Expression
+	RHS       Expression
+	Operator  *Token.Token // the binary operator of a compound assignment, nil for =
+	Increment bool
 }
 
 type StatementPrint struct {
+	Tok       Token.Token
 	IsNewLine bool
 	Expr      Expression
 }
@@ -32,10 +38,16 @@ type StatementDefer struct {
 	DeferredNode Deferrable
 }
 
-type StatementBreak struct{}
-type StatementContinue struct{}
+type StatementBreak struct {
+	Tok Token.Token
+}
+
+type StatementContinue struct {
+	Tok Token.Token
+}
 
 type StatementFor struct {
+	Tok         Token.Token
 	Initializer *DeclarationVariable
 	Condition   Expression
 	Increment   *StatementAssignment
@@ -43,16 +55,58 @@ type StatementFor struct {
 }
 
 type StatementWhile struct {
+	Tok       Token.Token
 	Condition Expression
 	Block     *StatementBlock
 }
 
 type StatementIfElse struct {
+	Tok       Token.Token
 	Condition Expression
 	IfBlock   *StatementBlock
 	ElseBlock *StatementBlock
 }
 
+// StatementSwitch runs the body of the first case with a value equal to Value, or Default when
+// there's none. Cases don't fall through, break and continue belong to the enclosing loop.
+type StatementSwitch struct {
+	Tok     Token.Token
+	Value   Expression
+	Cases   []*SwitchCase
+	Default *StatementBlock // nil without a default case
+}
+
+type SwitchCase struct {
+	Tok    Token.Token
+	Values []Expression
+	Body   *StatementBlock
+}
+
+// StatementMatch runs the body of the case naming the variant Value holds, or Default when
+// there's none, with the variant's payload bound to the case's Binding. Like a switch, cases
+// don't fall through.
+type StatementMatch struct {
+	Tok     Token.Token
+	Value   Expression
+	Cases   []*MatchCase
+	Default *StatementBlock // nil without a default case
+}
+
+// MatchCase is case Variant(binding): or case Variant: when the payload isn't needed,
+// Index is filled in by the TypeChecker
+type MatchCase struct {
+	Tok     Token.Token
+	Variant Token.Token
+	Binding *Token.Token
+	Index   int
+	Body    *StatementBlock
+}
+
+// StatementError stands in for a statement or declaration in a block that failed to parse
+type StatementError struct {
+	Tok Token.Token
+}
+
 // Block
 // Assignment
 // VariableDeclaration
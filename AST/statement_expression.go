@@ -8,8 +8,11 @@ type StatementExpression interface {
 	isStatementExpression()
 }
 
+// SE_FunctionCall calls the function or native called Tok, or the function value Callee when
+// it's set: a call through a variable, a member or the result of another call.
 type SE_FunctionCall struct {
 	Tok       Token.Token
+	Callee    Expression
 	Arguments []Expression
 }
 
@@ -18,3 +21,22 @@ func (*SE_FunctionCall) isStatementExpression() {}
 func (*SE_FunctionCall) isExpression()          {}
 func (*SE_FunctionCall) isStatement()           {}
 func (*SE_FunctionCall) isDeferrable()          {}
+
+// SE_MethodCall is receiver.method(arguments), Receiver is an identifier or an access chain.
+// The TypeChecker resolves Method and sets ReceiverArgument to the receiver adjusted to the
+// method's receiver type, its address for a pointer receiver or dereferenced for a value one.
+// Calling a member that holds a function sets Callee to the member instead.
+type SE_MethodCall struct {
+	Tok              Token.Token
+	Receiver         Expression
+	Arguments        []Expression
+	Method           *DeclarationFunction
+	ReceiverArgument Expression
+	Callee           Expression
+}
+
+func (*SE_MethodCall) isNode()                {}
+func (*SE_MethodCall) isStatementExpression() {}
+func (*SE_MethodCall) isExpression()          {}
+func (*SE_MethodCall) isStatement()           {}
+func (*SE_MethodCall) isDeferrable()          {}
@@ -31,3 +31,12 @@ func (*StatementWhile) isStatement() {}
 
 func (*StatementIfElse) isNode()      {}
 func (*StatementIfElse) isStatement() {}
+
+func (*StatementSwitch) isNode()      {}
+func (*StatementSwitch) isStatement() {}
+
+func (*StatementMatch) isNode()      {}
+func (*StatementMatch) isStatement() {}
+
+func (*StatementError) isNode()      {}
+func (*StatementError) isStatement() {}
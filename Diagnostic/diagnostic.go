@@ -0,0 +1,156 @@
+package Diagnostic
+
+import (
+	"fmt"
+	"ion-go/Token"
+	"strings"
+	"unicode/utf8"
+)
+
+type Severity int
+
+const (
+	ERROR Severity = iota
+	WARNING
+	NOTE
+)
+
+func (s Severity) String() string {
+	switch s {
+	case ERROR:
+		return "error"
+	case WARNING:
+		return "warning"
+	case NOTE:
+		return "note"
+	}
+
+	return "unknown"
+}
+
+type Diagnostic struct {
+	Severity Severity
+	File     string
+	Line     int
+	Column   int
+	Span     int // number of characters starting at Column that the caret underlines
+	Message  string
+	Notes    []string
+}
+
+func New(severity Severity, tok Token.Token, format string, args ...interface{}) Diagnostic {
+	lexeme := tok.Lexeme
+	if newLine := strings.IndexByte(lexeme, '\n'); newLine >= 0 {
+		lexeme = lexeme[:newLine] // multi-line raw strings only underline their first line
+	}
+
+	span := utf8.RuneCountInString(lexeme)
+
+	if span == 0 {
+		span = 1
+	}
+
+	return Diagnostic{
+		Severity: severity,
+		File:     tok.File,
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Span:     span,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func Error(tok Token.Token, format string, args ...interface{}) Diagnostic {
+	return New(ERROR, tok, format, args...)
+}
+
+func (d Diagnostic) WithNote(format string, args ...interface{}) Diagnostic {
+	d.Notes = append(append([]string{}, d.Notes...), fmt.Sprintf(format, args...))
+	return d
+}
+
+// Error lets a Diagnostic travel through code paths that expect a Go error
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+}
+
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == ERROR {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sourceLine finds the line in source, which is empty when the file couldn't be read
+func sourceLine(source []byte, line int) (string, bool) {
+	if line <= 0 || len(source) == 0 {
+		return "", false
+	}
+
+	lines := strings.Split(string(source), "\n")
+	if line > len(lines) {
+		return "", false
+	}
+
+	return strings.TrimRight(lines[line-1], "\r"), true
+}
+
+// Render formats the diagnostic followed by the offending source line and a caret
+// underneath the span, source may be nil in which case only the header and notes are printed.
+//
+// struct.ion:12:5: error: Undeclared Identifier: foo
+//
+//	12 |     foo = 5;
+//	   |     ^^^
+//	   = note: ...
+func (d Diagnostic) Render(source []byte) string {
+	var sb strings.Builder
+	sb.WriteString(d.Error())
+	sb.WriteString("\n")
+
+	gutter := len(fmt.Sprintf("%d", d.Line))
+	text, ok := sourceLine(source, d.Line)
+	if ok {
+		column := d.Column
+		if column <= 0 {
+			column = 1
+		}
+
+		// Columns count bytes but the caret is padded a character at a time, tabs are kept
+		// so it lines up with the source line no matter the tab width
+		var padding strings.Builder
+		for _, r := range text[:min(column-1, len(text))] {
+			if r == '\t' {
+				padding.WriteRune('\t')
+			} else {
+				padding.WriteRune(' ')
+			}
+		}
+
+		span := d.Span
+		if span <= 0 {
+			span = 1
+		}
+
+		sb.WriteString(fmt.Sprintf(" %*d | %s\n", gutter, d.Line, text))
+		sb.WriteString(fmt.Sprintf(" %*s | %s%s\n", gutter, "", padding.String(), strings.Repeat("^", span)))
+	}
+
+	for _, note := range d.Notes {
+		sb.WriteString(fmt.Sprintf(" %*s = note: %s\n", gutter, "", note))
+	}
+
+	return sb.String()
+}
+
+func RenderAll(diagnostics []Diagnostic, source []byte) string {
+	var sb strings.Builder
+	for _, d := range diagnostics {
+		sb.WriteString(d.Render(source))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,92 @@
+package Diagnostic
+
+import (
+	"ion-go/Token"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		tok      Token.Token
+		expected string
+	}{
+		{
+			name:   "identifier",
+			source: "fn main() -> void {\n    foo = 5;\n}\n",
+			tok:    Token.CreateToken(Token.IDENTIFIER, "foo", "test.ion", 2, 5),
+			expected: "test.ion:2:5: error: message\n" +
+				" 2 |     foo = 5;\n" +
+				"   |     ^^^\n",
+		},
+		{
+			name:   "tabs are kept",
+			source: "\tx := \t\tvalue;\n",
+			tok:    Token.CreateToken(Token.IDENTIFIER, "value", "test.ion", 1, 9),
+			expected: "test.ion:1:9: error: message\n" +
+				" 1 | \tx := \t\tvalue;\n" +
+				"   | \t     \t\t^^^^^\n",
+		},
+		{
+			name:   "multibyte characters before the column",
+			source: "println(\"é\" + x);\n",
+			tok:    Token.CreateToken(Token.IDENTIFIER, "x", "test.ion", 1, 16),
+			expected: "test.ion:1:16: error: message\n" +
+				" 1 | println(\"é\" + x);\n" +
+				"   |               ^\n",
+		},
+		{
+			name:   "multibyte characters in the span",
+			source: "var s := \"héllo\";\n",
+			tok:    Token.CreateToken(Token.STRING_LITERAL, "\"héllo\"", "test.ion", 1, 10),
+			expected: "test.ion:1:10: error: message\n" +
+				" 1 | var s := \"héllo\";\n" +
+				"   |          ^^^^^^^\n",
+		},
+		{
+			name:   "multi-line raw strings underline their first line",
+			source: "var s := `one\ntwo`;\n",
+			tok:    Token.CreateToken(Token.STRING_LITERAL, "`one\ntwo`", "test.ion", 1, 10),
+			expected: "test.ion:1:10: error: message\n" +
+				" 1 | var s := `one\n" +
+				"   |          ^^^^\n",
+		},
+		{
+			name:   "wide gutter",
+			source: "\n\n\n\n\n\n\n\n\nx;\n",
+			tok:    Token.CreateToken(Token.IDENTIFIER, "x", "test.ion", 10, 1),
+			expected: "test.ion:10:1: error: message\n" +
+				" 10 | x;\n" +
+				"    | ^\n",
+		},
+		{
+			name:     "unreadable file",
+			source:   "",
+			tok:      Token.CreateToken(Token.EOF, "", "missing.ion", 1, 1),
+			expected: "missing.ion:1:1: error: message\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if rendered := Error(test.tok, "message").Render([]byte(test.source)); rendered != test.expected {
+				t.Errorf("expected\n%s\ngot\n%s", test.expected, rendered)
+			}
+		})
+	}
+}
+
+func TestRenderNotes(t *testing.T) {
+	d := Error(Token.CreateToken(Token.EOF, "", "test.ion", 3, 2), "Expected: RIGHT_CURLY before end of file").
+		WithNote("block opened at line %d", 1)
+
+	expected := "test.ion:3:2: error: Expected: RIGHT_CURLY before end of file\n" +
+		" 3 | }\n" +
+		"   |  ^\n" +
+		"   = note: block opened at line 1\n"
+
+	if rendered := d.Render([]byte("{\n{\n}")); rendered != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, rendered)
+	}
+}
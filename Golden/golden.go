@@ -0,0 +1,256 @@
+package Golden
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"ion-go/AST"
+	"ion-go/Diagnostic"
+	"ion-go/Lexer"
+	"ion-go/Parser"
+	"ion-go/Runtime"
+	"ion-go/TypeChecker"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ExpectedError comes from a `// ERROR: <message>` annotation, the program must fail to
+// compile with a diagnostic on that line whose message contains Message.
+type ExpectedError struct {
+	Line    int
+	Message string
+}
+
+type Expectation struct {
+	Output    string
+	HasOutput bool
+	Errors    []ExpectedError
+
+	// RuntimeError comes from a `// RUNTIME ERROR: <message>` annotation, the program must compile
+	// and then fail on that line, the OUTPUT block is what it printed before failing.
+	RuntimeError *ExpectedError
+}
+
+// Engine runs a type checked program, Interpreter.InterpretProgram and VM.RunProgram both are one
+type Engine func(program AST.Program, args []string, stdout io.Writer) (int, error)
+
+type Result struct {
+	File     string
+	Skipped  bool
+	Failures []string
+}
+
+func (r Result) Passed() bool {
+	return !r.Skipped && len(r.Failures) == 0
+}
+
+var outputBlock = regexp.MustCompile(`(?s)/\*\s*OUTPUT:[ \t]*\r?\n?(.*?)\*/`)
+var errorAnnotation = regexp.MustCompile(`//\s*ERROR:\s*(.*?)\s*$`)
+var runtimeErrorAnnotation = regexp.MustCompile(`//\s*RUNTIME ERROR:\s*(.*?)\s*$`)
+
+// normalize ignores trailing whitespace on every line and blank lines at either end,
+// so OUTPUT blocks can be indented like the comment they live in.
+func normalize(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+func ParseExpectation(source []byte) Expectation {
+	var expectation Expectation
+
+	if match := outputBlock.FindSubmatch(source); match != nil {
+		expectation.HasOutput = true
+		expectation.Output = normalize(string(match[1]))
+	}
+
+	for i, line := range strings.Split(string(source), "\n") {
+		if match := errorAnnotation.FindStringSubmatch(line); match != nil {
+			expectation.Errors = append(expectation.Errors, ExpectedError{
+				Line:    i + 1,
+				Message: match[1],
+			})
+		}
+
+		if match := runtimeErrorAnnotation.FindStringSubmatch(line); match != nil && expectation.RuntimeError == nil {
+			expectation.RuntimeError = &ExpectedError{
+				Line:    i + 1,
+				Message: match[1],
+			}
+		}
+	}
+
+	return expectation
+}
+
+func diff(expected, actual string) string {
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+
+	var sb strings.Builder
+	for i := 0; i < max(len(expectedLines), len(actualLines)); i++ {
+		e, a := "<missing>", "<missing>"
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+
+		if e != a {
+			sb.WriteString(fmt.Sprintf("    line %d:\n        expected: %q\n        got:      %q\n", i+1, e, a))
+		}
+	}
+
+	return sb.String()
+}
+
+func matchErrors(expected []ExpectedError, diagnostics []Diagnostic.Diagnostic) []string {
+	var failures []string
+	matched := make([]bool, len(diagnostics))
+
+	for _, e := range expected {
+		found := false
+		for i, d := range diagnostics {
+			if !matched[i] && d.Line == e.Line && strings.Contains(d.Message, e.Message) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			failures = append(failures, fmt.Sprintf("line %d: expected error containing %q", e.Line, e.Message))
+		}
+	}
+
+	for i, d := range diagnostics {
+		if !matched[i] {
+			failures = append(failures, fmt.Sprintf("line %d: unexpected error: %s", d.Line, d.Message))
+		}
+	}
+
+	return failures
+}
+
+func matchRuntimeError(expected *ExpectedError, err error) []string {
+	runtimeError, _ := err.(*Runtime.Error)
+
+	switch {
+	case expected == nil && err == nil:
+		return nil
+	case expected == nil:
+		return []string{fmt.Sprintf("unexpected %v", err)}
+	case err == nil:
+		return []string{fmt.Sprintf("line %d: expected runtime error containing %q but the program finished", expected.Line, expected.Message)}
+	case runtimeError == nil || runtimeError.Line() != expected.Line || !strings.Contains(runtimeError.Message, expected.Message):
+		return []string{fmt.Sprintf("line %d: expected runtime error containing %q, got %v", expected.Line, expected.Message, err)}
+	}
+
+	return nil
+}
+
+func RunFile(filePath string, engine Engine) Result {
+	source, err := os.ReadFile(filePath)
+	if err != nil {
+		return Result{File: filePath, Failures: []string{err.Error()}}
+	}
+
+	return RunSource(filePath, source, engine)
+}
+
+// RunSource compiles the program and checks it against the OUTPUT block, ERROR and
+// RUNTIME ERROR annotations found in the source, files with none of them are skipped.
+func RunSource(filePath string, source []byte, engine Engine) Result {
+	result := Result{File: filePath}
+	expectation := ParseExpectation(source)
+	if !expectation.HasOutput && len(expectation.Errors) == 0 && expectation.RuntimeError == nil {
+		result.Skipped = true
+		return result
+	}
+
+	tokenStream, diagnostics := Lexer.GenerateTokenStreamFromSource(filePath, source)
+	program, parseDiagnostics := Parser.ParseProgram(tokenStream)
+	diagnostics = append(diagnostics, parseDiagnostics...)
+	if !Diagnostic.HasErrors(diagnostics) {
+		diagnostics = append(diagnostics, TypeChecker.TypeCheckProgram(program, nil)...)
+	}
+
+	if len(expectation.Errors) > 0 {
+		if !Diagnostic.HasErrors(diagnostics) {
+			result.Failures = append(result.Failures, "expected compile errors but the program compiled")
+		}
+
+		result.Failures = append(result.Failures, matchErrors(expectation.Errors, diagnostics)...)
+		return result
+	}
+
+	if Diagnostic.HasErrors(diagnostics) {
+		result.Failures = append(result.Failures, "unexpected compile errors:\n"+Diagnostic.RenderAll(diagnostics, source))
+		return result
+	}
+
+	var stdout bytes.Buffer
+	_, err := engine(program, nil, &stdout)
+	result.Failures = append(result.Failures, matchRuntimeError(expectation.RuntimeError, err)...)
+
+	if actual := normalize(stdout.String()); expectation.HasOutput && actual != expectation.Output {
+		result.Failures = append(result.Failures, "output mismatch:\n"+diff(expectation.Output, actual))
+	}
+
+	return result
+}
+
+// CollectFiles expands directories into the .ion files they contain, hidden directories are skipped
+// and a file reached through several of the paths is only listed once
+func CollectFiles(paths []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) {
+		if clean := filepath.Clean(file); !seen[clean] {
+			seen[clean] = true
+			files = append(files, file)
+		}
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			add(path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() && p != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			if !d.IsDir() && filepath.Ext(p) == ".ion" {
+				add(p)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
@@ -0,0 +1,83 @@
+package Golden
+
+import (
+	"ion-go/Interpreter"
+	"ion-go/VM"
+	"path/filepath"
+	"testing"
+)
+
+var engines = map[string]Engine{
+	"tree": Interpreter.InterpretProgram,
+	"vm":   VM.RunProgram,
+}
+
+// TestGoldenFiles runs every .ion program in the repository against its OUTPUT block and ERROR annotations
+func TestGoldenFiles(t *testing.T) {
+	files, err := CollectFiles([]string{".."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Fatal("no .ion files found")
+	}
+
+	for engineName, engine := range engines {
+		for _, file := range files {
+			name, _ := filepath.Rel("..", file)
+			t.Run(engineName+"/"+name, func(t *testing.T) {
+				result := RunFile(file, engine)
+				if result.Skipped {
+					t.Skip("no OUTPUT block or ERROR annotations")
+				}
+
+				for _, failure := range result.Failures {
+					t.Error(failure)
+				}
+			})
+		}
+	}
+}
+
+func TestParseExpectation(t *testing.T) {
+	source := []byte("fn main() -> void {\n    x = 1; // ERROR: Undeclared Identifier: x\n}\n\n/* OUTPUT:\nhello   \n  world\n\n*/\n")
+
+	expectation := ParseExpectation(source)
+	if !expectation.HasOutput || expectation.Output != "hello\n  world" {
+		t.Errorf("unexpected output block: %q", expectation.Output)
+	}
+
+	if len(expectation.Errors) != 1 {
+		t.Fatalf("expected 1 error annotation, got %d", len(expectation.Errors))
+	}
+
+	if e := expectation.Errors[0]; e.Line != 2 || e.Message != "Undeclared Identifier: x" {
+		t.Errorf("unexpected error annotation: %+v", e)
+	}
+
+	expectation = ParseExpectation([]byte("fn main() -> void {\n    println(1 / 0); // RUNTIME ERROR: division by zero\n}\n"))
+	if len(expectation.Errors) != 0 {
+		t.Errorf("RUNTIME ERROR was parsed as a compile error: %+v", expectation.Errors)
+	}
+
+	if e := expectation.RuntimeError; e == nil || e.Line != 2 || e.Message != "division by zero" {
+		t.Errorf("unexpected runtime error annotation: %+v", e)
+	}
+}
+
+func TestCollectFilesOnce(t *testing.T) {
+	all, err := CollectFiles([]string{".."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overlapping, err := CollectFiles([]string{"../Tests", "..", "./../Tests/functions.ion"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(overlapping) != len(all) {
+		t.Errorf("expected every file once, got %d files instead of %d", len(overlapping), len(all))
+	}
+}
@@ -0,0 +1,254 @@
+package Golden
+
+import (
+	"fmt"
+	"testing"
+)
+
+const operatorDeclarations = `struct Point {
+    x: int,
+    y: int
+}
+
+struct Named {
+    name: string,
+    at: Point,
+    next: *Named
+}
+
+struct Bag {
+    items: []int
+}
+
+struct Other {
+    x: int,
+    y: int
+}
+
+enum Color { Red, Green }
+
+enum Answer { Yes, No }
+`
+
+// operatorCase prints expression and expects either output, a compile error or a runtime error
+type operatorCase struct {
+	expression   string
+	output       string
+	err          string
+	runtimeError string
+}
+
+var operatorCases = []operatorCase{
+	// int
+	{expression: "7 + 2", output: "9"},
+	{expression: "7 - 9", output: "-2"},
+	{expression: "7 * 2", output: "14"},
+	{expression: "7 / 2", output: "3"},
+	{expression: "-7 / 2", output: "-3"},
+	{expression: "7 % 3", output: "1"},
+	{expression: "-7 % 3", output: "-1"},
+	{expression: "7 % -3", output: "1"},
+	{expression: "7 == 7", output: "true"},
+	{expression: "7 != 7", output: "false"},
+	{expression: "2 < 3", output: "true"},
+	{expression: "3 <= 3", output: "true"},
+	{expression: "2 > 3", output: "false"},
+	{expression: "3 >= 4", output: "false"},
+	{expression: "6 & 3", output: "2"},
+	{expression: "6 | 3", output: "7"},
+	{expression: "6 ^ 3", output: "5"},
+	{expression: "1 << 4", output: "16"},
+	{expression: "-8 >> 1", output: "-4"},
+	{expression: "-7", output: "-7"},
+	{expression: "+7", output: "7"},
+	{expression: "~7", output: "-8"},
+	{expression: "1 + 2 * 3 % 4", output: "3"},
+	{expression: "1 % 0", runtimeError: "integer modulo by zero"},
+	{expression: "1 / 0", runtimeError: "integer division by zero"},
+	{expression: "1 << -1", runtimeError: "negative shift count -1"},
+
+	// float and int promoted to float
+	{expression: "1.5 + 1", output: "2.5"},
+	{expression: "1 - 0.25", output: "0.75"},
+	{expression: "1.5 * 2.0", output: "3"},
+	{expression: "3 / 2.0", output: "1.5"},
+	{expression: "1.5 == 1.5", output: "true"},
+	{expression: "2 == 2.0", output: "true"},
+	{expression: "1.5 != 1.5", output: "false"},
+	{expression: "1.5 < 2", output: "true"},
+	{expression: "2 <= 1.5", output: "false"},
+	{expression: "1.5 > 1", output: "true"},
+	{expression: "1.5 >= 1.5", output: "true"},
+	{expression: "-1.5", output: "-1.5"},
+	{expression: "+1.5", output: "1.5"},
+	{expression: "1.5 % 2", err: "Operation % not supported on Left: float | Right: int"},
+	{expression: "1.5 & 1", err: "Operation & not supported on Left: float | Right: int"},
+	{expression: "~1.5", err: "Operation ~ not supported on float"},
+
+	// sized integers wrap around, unsigned ones divide, compare and shift as unsigned
+	{expression: "100i8 + 100i8", output: "-56"},
+	{expression: "10u8 - 20u8", output: "246"},
+	{expression: "16u16 * 4096u16", output: "0"},
+	{expression: "-7i32 / 2i32", output: "-3"},
+	{expression: "0xFFFF_FFFF_FFFF_FFFFu64 / 3u64", output: "6148914691236517205"},
+	{expression: "-7i16 % 3i16", output: "-1"},
+	{expression: "0xFFFF_FFFF_FFFF_FFFFu64 % 10u64", output: "5"},
+	{expression: "0xF0u8 & 0x3Cu8", output: "48"},
+	{expression: "0xF0u8 | 0x0Fu8", output: "255"},
+	{expression: "0xF0u8 ^ 0xFFu8", output: "15"},
+	{expression: "1u8 << 7", output: "128"},
+	{expression: "128u8 >> 7", output: "1"},
+	{expression: "-128i8 >> 7", output: "-1"},
+	{expression: "0xFFFF_FFFF_FFFF_FFFFu64 >> 63", output: "1"},
+	{expression: "1 << 3u8", output: "8"},
+	{expression: "1u64 << 64", output: "0"},
+	{expression: "0xFFFF_FFFF_FFFF_FFFFu64 > 1u64", output: "true"},
+	{expression: "-1i64 < 1i64", output: "true"},
+	{expression: "200u8 == 200u8", output: "true"},
+	{expression: "3u32 <= 2u32", output: "false"},
+	{expression: "-1i8 >= -1i8", output: "true"},
+	{expression: "-(-128i8)", output: "-128"},
+	{expression: "-(1u32)", output: "4294967295"},
+	{expression: "-1u32", err: "Integer literal -1u32 is out of range for u32"},
+	{expression: "~0u8", output: "255"},
+	{expression: "+5i16", output: "5"},
+	{expression: "1i8 / 0i8", runtimeError: "integer division by zero"},
+	{expression: "1u64 % 0u64", runtimeError: "integer modulo by zero"},
+	{expression: "1i32 << -1i32", runtimeError: "negative shift count -1"},
+
+	// sized kinds promote to a wider kind that holds every value of both
+	{expression: "100i8 * 1000i16", output: "-31072"},
+	{expression: "255u8 + 1u16", output: "256"},
+	{expression: "255u8 + 1i16", output: "256"},
+	{expression: "0.5f32 + 0.25f64", output: "0.75"},
+	{expression: "1u8 + 1i8", err: "Operation + not supported on Left: u8 | Right: i8"},
+	{expression: "1u16 + 1i16", err: "Operation + not supported on Left: u16 | Right: i16"},
+	{expression: "1i64 + 1u64", err: "Operation + not supported on Left: i64 | Right: u64"},
+	{expression: "1i32 + 1", output: "2"},
+	{expression: "1i32 + 1.5", err: "Operation + not supported on Left: i32 | Right: float"},
+	{expression: "1f32 + 1.5", output: "2.5"},
+	{expression: "1i32 + 1f32", err: "Operation + not supported on Left: i32 | Right: f32"},
+	{expression: "1.5f64 % 1f64", err: "Operation % not supported on Left: f64 | Right: f64"},
+	{expression: "~1f64", err: "Operation ~ not supported on f64"},
+
+	// f64
+	{expression: "0.1f64 + 0.2f64", output: "0.30000000000000004"},
+	{expression: "1f64 - 0.75f64", output: "0.25"},
+	{expression: "1e300f64 * 10f64", output: "1e+301"},
+	{expression: "1f64 / 0f64", output: "+Inf"},
+	{expression: "0.5f64 < 1", output: "true"},
+	{expression: "-2.5f64", output: "-2.5"},
+	{expression: `"n" + 7u8`, output: "n7"},
+	{expression: `1.5f64 + "x"`, output: "1.5x"},
+
+	// string
+	{expression: `"ab" + "c"`, output: "abc"},
+	{expression: `"n" + 1`, output: "n1"},
+	{expression: `1 + "n"`, output: "1n"},
+	{expression: `"f" + 1.5`, output: "f1.5"},
+	{expression: `"b" + true`, output: "btrue"},
+	{expression: `false + "b"`, output: "falseb"},
+	{expression: `"a" == "a"`, output: "true"},
+	{expression: `"a" != "a"`, output: "false"},
+	{expression: `"a" < "b"`, output: "true"},
+	{expression: `"ab" < "a"`, output: "false"},
+	{expression: `"" < "a"`, output: "true"},
+	{expression: `"b" <= "a"`, output: "false"},
+	{expression: `"b" > "a"`, output: "true"},
+	{expression: `"a" >= "a"`, output: "true"},
+	{expression: `"a" - "b"`, err: "Operation - not supported on Left: string | Right: string"},
+	{expression: `"a" == 1`, err: "Operation == not supported on Left: string | Right: int"},
+	{expression: `"a" < 1`, err: "Operation < not supported on Left: string | Right: int"},
+	{expression: `+"a"`, err: "Operation + not supported on string"},
+
+	// char, compared by code point
+	{expression: "'a' == 'a'", output: "true"},
+	{expression: "'a' != 'b'", output: "true"},
+	{expression: "'a' < 'b'", output: "true"},
+	{expression: "'b' <= 'a'", output: "false"},
+	{expression: "'é' > 'z'", output: "true"},
+	{expression: "'a' >= 'a'", output: "true"},
+	{expression: `"ab" + 'c'`, output: "abc"},
+	{expression: `'c' + "ab"`, output: "cab"},
+	{expression: "'a' + 'b'", err: "Operation + not supported on Left: char | Right: char"},
+	{expression: "'a' + 1", err: "Operation + not supported on Left: char | Right: int"},
+	{expression: "'a' == 97", err: "Operation == not supported on Left: char | Right: int"},
+	{expression: `'a' == "a"`, err: "Operation == not supported on Left: char | Right: string"},
+	{expression: "-'a'", err: "Operation - not supported on char"},
+
+	// bool
+	{expression: "true == true", output: "true"},
+	{expression: "true != false", output: "true"},
+	{expression: "false == true", output: "false"},
+	{expression: "!true", output: "false"},
+	{expression: "!!true", output: "true"},
+	{expression: "!(1 > 2)", output: "true"},
+	{expression: "true && false", output: "false"},
+	{expression: "false || true", output: "true"},
+	{expression: "true < false", err: "Operation < not supported on Left: bool | Right: bool"},
+	{expression: "true + 1", err: "Operation + not supported on Left: bool | Right: int"},
+	{expression: "-true", err: "Operation - not supported on bool"},
+	{expression: "!1", err: "Operation ! not supported on int"},
+	{expression: "1 && true", err: "Operation && not supported on Left: int | Right: bool"},
+
+	// struct, compared member by member
+	{expression: "Point.{1, 2} == Point.{1, 2}", output: "true"},
+	{expression: "Point.{1, 2} == Point.{2, 1}", output: "false"},
+	{expression: "Point.{1, 2} != Point.{1, 3}", output: "true"},
+	{expression: `Named.{"a", Point.{1, 2}, nullptr} == Named.{"a", Point.{1, 2}, nullptr}`, output: "true"},
+	{expression: `Named.{"a", Point.{1, 2}, nullptr} == Named.{"a", Point.{1, 3}, nullptr}`, output: "false"},
+	{expression: `Named.{"a", Point.{1, 2}, nullptr} != Named.{"b", Point.{1, 2}, nullptr}`, output: "true"},
+	{expression: "Point.{1, 2} == Other.{1, 2}", err: "Operation == not supported on Left: Point | Right: Other"},
+	{expression: "Point.{1, 2} < Point.{1, 2}", err: "Operation < not supported on Left: Point | Right: Point"},
+	{expression: "Point.{1, 2} + Point.{1, 2}", err: "Operation + not supported on Left: Point | Right: Point"},
+	{expression: "Bag.{[]int.[1]} == Bag.{[]int.[1]}", err: "member items of type []int can't be compared"},
+
+	// enum, compared by variant
+	{expression: "Color.Red == Color.Red", output: "true"},
+	{expression: "Color.Red == Color.Green", output: "false"},
+	{expression: "Color.Red != Color.Green", output: "true"},
+	{expression: `"is " + Color.Green`, output: "is Green"},
+	{expression: `Color.Red + "!"`, output: "Red!"},
+	{expression: "Color.Red == Answer.Yes", err: "Operation == not supported on Left: Color | Right: Answer"},
+	{expression: "Color.Red < Color.Green", err: "Operation < not supported on Left: Color | Right: Color"},
+	{expression: "Color.Red + 1", err: "Operation + not supported on Left: Color | Right: int"},
+	{expression: "-Color.Red", err: "Operation - not supported on Color"},
+
+	// array and pointer
+	{expression: "[]int.[1] == []int.[1]", err: "Operation == not supported on Left: []int | Right: []int"},
+	{expression: "nullptr == nullptr", output: "true"},
+	{expression: "&Point.{1, 2} == &Point.{1, 2}", output: "false"},
+	{expression: "&Point.{1, 2} == nullptr", output: "false"},
+	{expression: "&Point.{1, 2} < nullptr", err: "Operation < not supported on Left: *Point | Right: nullptr"},
+}
+
+func (c operatorCase) source() string {
+	annotation := ""
+	if c.err != "" {
+		annotation = " // ERROR: " + c.err
+	} else if c.runtimeError != "" {
+		annotation = " // RUNTIME ERROR: " + c.runtimeError
+	}
+
+	source := fmt.Sprintf("%s\nfn main() -> void {\n    println(%s);%s\n}\n", operatorDeclarations, c.expression, annotation)
+	if c.err == "" {
+		source += "\n/* OUTPUT:\n" + c.output + "\n*/\n"
+	}
+
+	return source
+}
+
+// TestOperators checks every operator against every type it's defined on, and that the
+// TypeChecker rejects the combinations it isn't, on both engines
+func TestOperators(t *testing.T) {
+	for engineName, engine := range engines {
+		for _, c := range operatorCases {
+			t.Run(engineName+"/"+c.expression, func(t *testing.T) {
+				result := RunSource("operators.ion", []byte(c.source()), engine)
+				for _, failure := range result.Failures {
+					t.Error(failure)
+				}
+			})
+		}
+	}
+}
@@ -1,482 +1,496 @@
 package Interpreter
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"ion-go/AST"
+	"ion-go/Runtime"
 	"ion-go/TS"
 	"ion-go/Token"
-	"strings"
+	"os"
 )
 
-var globalFunctions map[string]*AST.DeclarationFunction
-var globalStructs map[string]*AST.DeclarationStruct
-var globalScope Scope
+// execution is the state of a single Run, every Run gets its own so an Interpreter can be
+// run from several goroutines at once
+type execution struct {
+	ctx       context.Context
+	functions map[string]*Runtime.ValueFunction
+	structs   map[string]*AST.DeclarationStruct
+	globals   Scope
+	natives   *Runtime.Natives
+	host      *Runtime.Host
+	stdout    io.Writer
+
+	// callStack has the innermost call last, each frame tracks the last position
+	// evaluated in it so a runtime error can report where every call was.
+	callStack []Runtime.StackFrame
+}
 
-// Returns either a struct or array and then their respective indices
-func evaluateAccessChainExpression(chain *AST.ExpressionAccessChain, scope *Scope) (AST.Expression, AST.Expression) {
-	ret := scope.get(chain.Tok)
-	for i := 0; i < len(chain.AccessKeys)-1; i++ {
-		switch ev := chain.AccessKeys[i].(type) {
-		case *AST.ExpressionArrayAccess:
-			temp := ret.(*AST.ExpressionArray)
-			index := interpretExpression(ev.Index, scope).(*AST.ExpressionInteger).Value
-			ret = interpretExpression(temp.Elements[index], scope)
+type PseudoBehavior int
 
-		case *AST.ExpressionIdentifier:
-			temp := ret.(*AST.ExpressionStruct)
-			index := ev.Tok.Lexeme
-			ret = interpretExpression(temp.MemberValues[index], scope)
-		}
+const (
+	BREAK PseudoBehavior = iota
+	RETURN
+	CONTINUE
+)
+
+// Pseudo carries break, continue and return (with its value) out of nested blocks
+type Pseudo struct {
+	Value    Runtime.Value
+	Behavior PseudoBehavior
+}
+
+// at records that the innermost call is about to evaluate tok, call it right before
+// anything that can fail so runtime errors point at the operator, index or call that failed
+func (ex *execution) at(tok Token.Token) {
+	frame := &ex.callStack[len(ex.callStack)-1]
+	frame.File = tok.File
+	frame.Line = tok.Line
+	frame.Column = tok.Column
+}
+
+// checkCancelled aborts the program once the Run's context is done, it's called on every
+// call and loop iteration so a runaway program can always be stopped
+func (ex *execution) checkCancelled() {
+	select {
+	case <-ex.ctx.Done():
+		panic(&Runtime.Error{Message: ex.ctx.Err().Error(), Cause: ex.ctx.Err()})
+	default:
 	}
+}
 
-	var index AST.Expression = nil
-	switch ev := chain.AccessKeys[len(chain.AccessKeys)-1].(type) {
+func (ex *execution) pushFrame(name string, tok Token.Token) {
+	if len(ex.callStack) >= Runtime.MAX_CALL_DEPTH {
+		Runtime.Throw("stack overflow calling %s, more than %d nested calls", name, Runtime.MAX_CALL_DEPTH)
+	}
+
+	ex.callStack = append(ex.callStack, Runtime.StackFrame{Function: name})
+	ex.at(tok)
+	ex.checkCancelled()
+}
+
+func (ex *execution) popFrame() {
+	ex.callStack = ex.callStack[:len(ex.callStack)-1]
+}
+
+// stackTrace returns the call stack innermost call first
+func (ex *execution) stackTrace() []Runtime.StackFrame {
+	trace := make([]Runtime.StackFrame, len(ex.callStack))
+	for i, frame := range ex.callStack {
+		trace[len(ex.callStack)-1-i] = frame
+	}
+
+	return trace
+}
+
+func (ex *execution) evaluateAccessKey(container Runtime.Value, key AST.Expression, scope *Scope) Runtime.Value {
+	switch ev := key.(type) {
 	case *AST.ExpressionArrayAccess:
-		index = ev.Index
+		index := ex.interpretExpression(ev.Index, scope)
+		ex.at(ev.Tok)
+		return Runtime.Index(container, index)
 
 	case *AST.ExpressionIdentifier:
-		index = ev
+		ex.at(ev.Tok)
+		return Runtime.Member(container, ev.Tok.Lexeme)
 	}
 
-	return ret, index
+	panic(fmt.Sprintf("unreachable access key: %T", key))
 }
 
-func interpretBinaryExpression(kind Token.TokenType, left, right AST.Expression) AST.Expression {
-	switch kind {
-	case Token.PLUS, Token.MINUS, Token.STAR, Token.DIVISION,
-		Token.LESS_THAN, Token.LESS_THAN_EQUALS, Token.GREATER_THAN, Token.GREATER_THAN_EQUALS,
-		Token.EQUALS_EQUALS, Token.NOT_EQUALS:
-		// Try int + int
-		if lhs, ok1 := left.(*AST.ExpressionInteger); ok1 {
-			if rhs, ok2 := right.(*AST.ExpressionInteger); ok2 {
-				return evaluateIntegers(kind, lhs.Value, rhs.Value)
-			}
-		}
+// Returns either a struct or array and then the last key of the chain
+func (ex *execution) evaluateAccessChainExpression(chain *AST.ExpressionAccessChain, scope *Scope) (Runtime.Value, AST.Expression) {
+	var ret Runtime.Value
+	if chain.Base != nil {
+		ret = ex.interpretExpression(chain.Base, scope)
+	} else {
+		ret = scope.get(chain.Tok)
+	}
 
-		// Try float + float
-		if lhs, ok1 := left.(*AST.ExpressionFloat); ok1 {
-			if rhs, ok2 := right.(*AST.ExpressionFloat); ok2 {
-				return evaluateFloats(kind, lhs.Value, rhs.Value)
-			}
-		}
+	for i := 0; i < len(chain.AccessKeys)-1; i++ {
+		ret = ex.evaluateAccessKey(ret, chain.AccessKeys[i], scope)
+	}
 
-		// Mixed int + float (promote int to float)
-		if lhs, ok1 := left.(*AST.ExpressionInteger); ok1 {
-			if rhs, ok2 := right.(*AST.ExpressionFloat); ok2 {
-				return evaluateFloats(kind, float32(lhs.Value), rhs.Value)
-			}
-		}
+	return ret, chain.AccessKeys[len(chain.AccessKeys)-1]
+}
 
-		// Mixed float + int (promote int to float)
-		if lhs, ok1 := left.(*AST.ExpressionFloat); ok1 {
-			if rhs, ok2 := right.(*AST.ExpressionInteger); ok2 {
-				return evaluateFloats(kind, lhs.Value, float32(rhs.Value))
-			}
-		}
+// address evaluates &e, variables and everything reached from them are pointed to in place
+// while literals get storage of their own
+func (ex *execution) address(e AST.Expression, scope *Scope) Runtime.Value {
+	switch v := e.(type) {
+	case *AST.ExpressionIdentifier:
+		return &Runtime.ValuePointer{Target: scope.reference(v.Tok)}
 
-		if lhs, ok1 := left.(*AST.ExpressionString); ok1 {
-			switch rhs := right.(type) {
-			case *AST.ExpressionInteger:
-				return evaluateString(kind, lhs.Value, fmt.Sprintf("%d", rhs.Value))
-			case *AST.ExpressionFloat:
-				return evaluateString(kind, lhs.Value, fmt.Sprintf("%.5g", rhs.Value))
-			case *AST.ExpressionString:
-				return evaluateString(kind, lhs.Value, rhs.Value)
-			}
+	case *AST.ExpressionAccessChain:
+		var pointer Runtime.Value
+		if v.Base != nil {
+			pointer = ex.address(v.Base, scope)
+		} else {
+			pointer = &Runtime.ValuePointer{Target: scope.reference(v.Tok)}
 		}
 
-		if rhs, ok1 := right.(*AST.ExpressionString); ok1 {
-			switch lhs := left.(type) {
-			case *AST.ExpressionInteger:
-				return evaluateString(kind, fmt.Sprintf("%d", lhs.Value), rhs.Value)
-			case *AST.ExpressionFloat:
-				return evaluateString(kind, fmt.Sprintf("%.5g", lhs.Value), rhs.Value)
-			case *AST.ExpressionString:
-				return evaluateString(kind, lhs.Value, rhs.Value)
+		for _, key := range v.AccessKeys {
+			switch k := key.(type) {
+			case *AST.ExpressionArrayAccess:
+				index := ex.interpretExpression(k.Index, scope)
+				ex.at(k.Tok)
+				pointer = Runtime.AddressIndex(pointer, index)
+
+			case *AST.ExpressionIdentifier:
+				ex.at(k.Tok)
+				pointer = Runtime.AddressMember(pointer, k.Tok.Lexeme)
 			}
 		}
 
-		panic(fmt.Sprintf("invalid operands for %v: %T and %T", kind, left, right))
+		return pointer
+	}
 
-	case Token.LOGICAL_AND, Token.LOGICAL_OR:
-		lhs, ok1 := left.(*AST.ExpressionBoolean)
-		rhs, ok2 := right.(*AST.ExpressionBoolean)
-		if !ok1 || !ok2 {
-			panic(fmt.Sprintf("expected booleans for %v, got %T and %T", kind, left, right))
-		}
+	return Runtime.Box(ex.interpretExpression(e, scope))
+}
 
-		if kind == Token.LOGICAL_AND {
-			return &AST.ExpressionBoolean{Value: lhs.Value && rhs.Value}
-		} else {
-			return &AST.ExpressionBoolean{Value: lhs.Value || rhs.Value}
-		}
+// callValue calls the function callee evaluates to, the callee is evaluated before the arguments
+func (ex *execution) callValue(tok Token.Token, callee AST.Expression, arguments []AST.Expression, scope *Scope) Runtime.Value {
+	value := ex.interpretExpression(callee, scope)
 
-	default:
-		panic(fmt.Sprintf("unhandled operator: %v", kind))
+	values := make([]Runtime.Value, len(arguments))
+	for i, argument := range arguments {
+		values[i] = ex.interpretExpression(argument, scope)
 	}
+
+	ex.at(tok)
+	return ex.callFunction(Runtime.AsFunction(value), values)
 }
 
-func evaluateIntegers(kind Token.TokenType, lhs, rhs int) AST.Expression {
-	switch kind {
-	case Token.PLUS:
-		return &AST.ExpressionInteger{Value: lhs + rhs}
-	case Token.MINUS:
-		return &AST.ExpressionInteger{Value: lhs - rhs}
-	case Token.STAR:
-		return &AST.ExpressionInteger{Value: lhs * rhs}
-	case Token.DIVISION:
-		return &AST.ExpressionInteger{Value: lhs / rhs}
-	case Token.EQUALS_EQUALS:
-		return &AST.ExpressionBoolean{Value: lhs == rhs}
-	case Token.LESS_THAN:
-		return &AST.ExpressionBoolean{Value: lhs < rhs}
-	case Token.LESS_THAN_EQUALS:
-		return &AST.ExpressionBoolean{Value: lhs <= rhs}
-	case Token.GREATER_THAN:
-		return &AST.ExpressionBoolean{Value: lhs > rhs}
-	case Token.GREATER_THAN_EQUALS:
-		return &AST.ExpressionBoolean{Value: lhs >= rhs}
+func (ex *execution) callFunction(function *Runtime.ValueFunction, arguments []Runtime.Value) Runtime.Value {
+	params := function.Decl.DeclType.Parameters
+	if len(params) != len(arguments) {
+		panic(fmt.Sprintf("expected %d parameter(s), got %d", len(params), len(arguments)))
 	}
 
-	panic("unreachable")
-}
+	ex.pushFrame(function.Decl.Name(), function.Decl.Tok)
 
-func evaluateFloats(kind Token.TokenType, lhs, rhs float32) AST.Expression {
-	switch kind {
-	case Token.PLUS:
-		return &AST.ExpressionFloat{Value: lhs + rhs}
-	case Token.MINUS:
-		return &AST.ExpressionFloat{Value: lhs - rhs}
-	case Token.STAR:
-		return &AST.ExpressionFloat{Value: lhs * rhs}
-	case Token.DIVISION:
-		return &AST.ExpressionFloat{Value: lhs / rhs}
-	case Token.EQUALS_EQUALS:
-		return &AST.ExpressionBoolean{Value: lhs == rhs}
-	case Token.NOT_EQUALS:
-		return &AST.ExpressionBoolean{Value: lhs != rhs}
-	case Token.LESS_THAN:
-		return &AST.ExpressionBoolean{Value: lhs < rhs}
-	case Token.LESS_THAN_EQUALS:
-		return &AST.ExpressionBoolean{Value: lhs <= rhs}
-	case Token.GREATER_THAN:
-		return &AST.ExpressionBoolean{Value: lhs > rhs}
-	case Token.GREATER_THAN_EQUALS:
-		return &AST.ExpressionBoolean{Value: lhs >= rhs}
+	// anonymous functions see the scope they were created in
+	parent := &ex.globals
+	if closure, ok := function.Closure.(*Scope); ok {
+		parent = closure
 	}
-	panic("unreachable")
-}
 
-func evaluateString(kind Token.TokenType, lhs, rhs string) AST.Expression {
-	switch kind {
-	case Token.PLUS:
-		return &AST.ExpressionString{Value: lhs + rhs}
+	functionScope := CreateScope(parent)
+	for i, param := range params {
+		functionScope.declare(param.Tok, Runtime.Copy(arguments[i]))
 	}
 
-	panic("unreachable")
-}
-
-func interpretUnaryExpression(kind Token.TokenType, operand AST.Expression) AST.Expression {
-	switch kind {
-	case Token.MINUS:
-		switch v := operand.(type) {
-		case *AST.ExpressionInteger:
-			return &AST.ExpressionInteger{Value: -v.Value}
-		case *AST.ExpressionFloat:
-			return &AST.ExpressionFloat{Value: -v.Value}
-
-		default:
-			panic(fmt.Sprintf("unhandled operator: %v", kind))
-		}
-	default:
-		panic(fmt.Sprintf("unhandled operator: %v", kind))
+	var ret Runtime.Value
+	if pseudo := ex.interpretNodes(function.Decl.Block.Body, &functionScope); pseudo != nil {
+		ret = pseudo.Value
 	}
+
+	ex.popFrame()
+	return ret
 }
 
-func interpretExpression(e AST.Expression, scope *Scope) AST.Expression {
+func (ex *execution) interpretExpression(e AST.Expression, scope *Scope) Runtime.Value {
 	if e == nil {
 		return nil
 	}
 
 	switch v := e.(type) {
-	case *AST.ExpressionInteger, *AST.ExpressionFloat, *AST.ExpressionBoolean, *AST.ExpressionString:
-		return v
+	case *AST.ExpressionInteger:
+		return Runtime.NewInteger(v.Kind, v.Value)
+
+	case *AST.ExpressionFloat:
+		return Runtime.NewFloat(v.Kind, v.Value)
+
+	case *AST.ExpressionBoolean:
+		return &Runtime.ValueBoolean{Value: v.Value}
+
+	case *AST.ExpressionString:
+		return &Runtime.ValueString{Value: v.Value}
+
+	case *AST.ExpressionChar:
+		return &Runtime.ValueChar{Value: v.Value}
+
 	case *AST.ExpressionIdentifier:
+		if function, ok := ex.functions[v.Tok.Lexeme]; ok && !scope.has(v.Tok) {
+			return function
+		}
+
 		return scope.get(v.Tok)
+
+	case *AST.ExpressionFunction:
+		return &Runtime.ValueFunction{Decl: v.Decl, Closure: scope}
+
+	case *AST.ExpressionEnumValue:
+		return &Runtime.ValueEnum{DeclType: v.DeclType, Index: v.Index}
+
+	case *AST.ExpressionUnion:
+		return &Runtime.ValueUnion{
+			DeclType: v.DeclType,
+			Index:    v.Index,
+			Payload:  Runtime.Copy(ex.interpretExpression(v.Payload, scope)),
+		}
+
 	case *AST.SE_FunctionCall:
-		functionDeclaration := globalFunctions[v.Tok.Lexeme]
-		argCount := len(v.Arguments)
-		paramCount := len(functionDeclaration.DeclType.Parameters)
+		if v.Callee != nil {
+			return ex.callValue(v.Tok, v.Callee, v.Arguments, scope)
+		}
+
+		arguments := make([]Runtime.Value, len(v.Arguments))
+		for i, argument := range v.Arguments {
+			arguments[i] = ex.interpretExpression(argument, scope)
+		}
+
+		ex.at(v.Tok)
+		if function, ok := ex.functions[v.Tok.Lexeme]; ok {
+			return ex.callFunction(function, arguments)
+		}
+
+		native, _ := ex.natives.Lookup(v.Tok.Lexeme)
+		ex.checkCancelled()
+		return Runtime.CallNative(native, ex.host, arguments)
 
-		if paramCount != argCount {
-			panic(fmt.Sprintf("expected %d parameter(s), got %d", argCount, paramCount))
+	case *AST.SE_MethodCall:
+		if v.Callee != nil {
+			return ex.callValue(v.Tok, v.Callee, v.Arguments, scope)
 		}
 
-		functionScope := CreateScope(&globalScope)
-		for i := 0; i < argCount; i++ {
-			param := functionDeclaration.DeclType.Parameters[i]
-			arg := v.Arguments[i]
-			functionScope.set(param.Tok, interpretExpression(arg, scope))
+		arguments := []Runtime.Value{ex.interpretExpression(v.ReceiverArgument, scope)}
+		for _, argument := range v.Arguments {
+			arguments = append(arguments, ex.interpretExpression(argument, scope))
 		}
 
-		return interpretExpression(interpretNodes(functionDeclaration.Block.Body, &functionScope), &functionScope)
+		ex.at(v.Tok)
+		return ex.callFunction(ex.functions[v.Method.Name()], arguments)
 
 	case *AST.ExpressionLen:
-		v.Iterable = interpretExpression(v.Iterable, scope)
-		switch ve := v.Iterable.(type) {
-		case *AST.ExpressionArray:
-			return &AST.ExpressionInteger{Value: len(ve.Elements)}
+		iterable := ex.interpretExpression(v.Iterable, scope)
+		ex.at(v.Tok)
+		return Runtime.Len(iterable)
 
-		case *AST.ExpressionString:
-			return &AST.ExpressionInteger{Value: len(ve.Value)}
+	case *AST.ExpressionGrouping:
+		return ex.interpretExpression(v.Expr, scope)
 
-		default:
-			panic(fmt.Sprintf("unhandled operator: %T", v.Iterable))
+	case *AST.ExpressionNullptr:
+		return &Runtime.ValuePointer{}
+
+	case *AST.ExpressionTuple:
+		elements := make([]Runtime.Value, len(v.Elements))
+		for i, element := range v.Elements {
+			elements[i] = Runtime.Copy(ex.interpretExpression(element, scope))
 		}
 
-	case *AST.ExpressionGrouping:
-		return interpretExpression(v.Expr, scope)
+		return &Runtime.ValueTuple{Elements: elements}
+
+	case *AST.ExpressionAddressOf:
+		return ex.address(v.Operand, scope)
+
+	case *AST.ExpressionDereference:
+		pointer := ex.interpretExpression(v.Operand, scope)
+		ex.at(v.Tok)
+		return Runtime.Dereference(pointer)
 
 	case *AST.ExpressionBinary:
-		leftExpression := interpretExpression(v.Left, scope)
-		if v.Operator.Kind == Token.LOGICAL_OR && leftExpression.(*AST.ExpressionBoolean).Value {
-			return &AST.ExpressionBoolean{Value: true}
-		} else if v.Operator.Kind == Token.LOGICAL_AND && !leftExpression.(*AST.ExpressionBoolean).Value {
-			return &AST.ExpressionBoolean{Value: false}
+		leftValue := ex.interpretExpression(v.Left, scope)
+		if v.Operator.Kind == Token.LOGICAL_OR && leftValue.(*Runtime.ValueBoolean).Value {
+			return &Runtime.ValueBoolean{Value: true}
+		} else if v.Operator.Kind == Token.LOGICAL_AND && !leftValue.(*Runtime.ValueBoolean).Value {
+			return &Runtime.ValueBoolean{Value: false}
 		}
 
-		rightExpression := interpretExpression(v.Right, scope)
-		return interpretBinaryExpression(v.Operator.Kind, leftExpression, rightExpression)
+		rightValue := ex.interpretExpression(v.Right, scope)
+		ex.at(v.Operator)
+		return Runtime.BinaryOperation(v.Operator.Kind, leftValue, rightValue)
 
 	case *AST.ExpressionArray:
+		elements := make([]Runtime.Value, len(v.Elements))
 		for i, element := range v.Elements {
-			v.Elements[i] = interpretExpression(element, scope)
+			elements[i] = Runtime.Copy(ex.interpretExpression(element, scope))
 		}
 
-		return v
-
-	case *AST.ExpressionPseudo:
-		return interpretExpression(v.Expr, scope)
+		return &Runtime.ValueArray{
+			Elements: elements,
+			DeclType: v.DeclType,
+		}
 
 	case *AST.ExpressionUnary:
-		operand := interpretExpression(v.Operand, scope)
-		return interpretUnaryExpression(v.Operator.Kind, operand)
+		operand := ex.interpretExpression(v.Operand, scope)
+		ex.at(v.Operator)
+		return Runtime.UnaryOperation(v.Operator.Kind, operand)
 
 	case *AST.ExpressionStruct:
-		for i, element := range v.MemberValues {
-			v.MemberValues[i] = interpretExpression(element, scope)
+		members := make(map[string]Runtime.Value, len(v.MemberValues))
+		for name, member := range v.MemberValues {
+			members[name] = Runtime.Copy(ex.interpretExpression(member, scope))
 		}
 
-		return v
-
-	case *AST.ExpressionAccessChain:
-		ret, index := evaluateAccessChainExpression(v, scope)
-		switch ev := ret.(type) {
-		case *AST.ExpressionArray:
-			return ev.Elements[interpretExpression(index, scope).(*AST.ExpressionInteger).Value]
-		case *AST.ExpressionStruct:
-			return ev.MemberValues[index.(*AST.ExpressionIdentifier).Tok.Lexeme]
-
-		default:
-			panic("unreachable")
+		decl := ex.structs[v.Tok.Lexeme]
+		if v.Decl != nil {
+			decl = v.Decl // an instantiation of a generic struct
 		}
 
-	case *AST.ExpressionTypeCast:
-		v.Expr = interpretExpression(v.Expr, scope)
-		switch ev := v.Expr.(type) {
-		case *AST.ExpressionInteger:
-			if v.CastType.Kind == TS.STRING {
-				v.Expr = &AST.ExpressionString{
-					Value: fmt.Sprintf("%d", ev.Value),
-				}
-			}
-
-			if v.CastType.Kind == TS.FLOAT {
-				v.Expr = &AST.ExpressionFloat{
-					Value: float32(ev.Value),
-				}
-			}
-
-		case *AST.ExpressionFloat:
-			if v.CastType.Kind == TS.STRING {
-				v.Expr = &AST.ExpressionString{
-					Value: fmt.Sprintf("%.5g", ev.Value),
-				}
-			}
-
-			if v.CastType.Kind == TS.INTEGER {
-				v.Expr = &AST.ExpressionInteger{
-					Value: int(ev.Value),
-				}
-			}
-
-		default:
-			panic(fmt.Sprintf("undefined expression %T", v.Expr))
+		return &Runtime.ValueStruct{
+			Decl:    decl,
+			Members: members,
 		}
 
-		return v.Expr
+	case *AST.ExpressionAccessChain:
+		container, key := ex.evaluateAccessChainExpression(v, scope)
+		return ex.evaluateAccessKey(container, key, scope)
+
+	case *AST.ExpressionTypeCast:
+		value := ex.interpretExpression(v.Expr, scope)
+		ex.at(v.Tok)
+		return Runtime.Cast(v.CastType, value)
 
 	default:
-		fmt.Printf("Type: %T\n", e)
-		panic("unreachable")
+		panic(fmt.Sprintf("unreachable expression: %T", e))
 	}
 }
 
-func interpretDeclaration(decl AST.Declaration, scope *Scope) {
+func (ex *execution) interpretDeclaration(decl AST.Declaration, scope *Scope) {
 	switch v := decl.(type) {
 	case *AST.DeclarationVariable:
-		if scope.has(v.Tok) {
-			panic("Attempting to redeclare: " + v.Tok.Lexeme)
-		}
-
-		temp := interpretExpression(v.RHS, scope)
+		temp := ex.interpretExpression(v.RHS, scope)
 		if temp == nil {
-			panic("Attempting to assign void to variable: " + v.Tok.Lexeme)
+			ex.at(v.Tok)
+			Runtime.Throw("Attempting to assign void to variable: %s", v.Tok.Lexeme)
 		}
 
-		scope.set(v.Tok, temp)
+		scope.declare(v.Tok, Runtime.Copy(temp))
+
+	case *AST.DeclarationDestructure:
+		tuple := ex.interpretExpression(v.RHS, scope).(*Runtime.ValueTuple)
+		for i, variable := range v.Variables {
+			scope.declare(variable.Tok, Runtime.Copy(tuple.Elements[i]))
+		}
 
 	case *AST.DeclarationFunction:
-		globalFunctions[v.Tok.Lexeme] = v
+		ex.functions[v.Name()] = &Runtime.ValueFunction{Decl: v}
 
 	case *AST.DeclarationStruct:
-		globalStructs[v.Tok.Lexeme] = v
+		ex.structs[v.Tok.Lexeme] = v
+
+	case *AST.DeclarationEnum, *AST.DeclarationUnion:
+		// enum and union values carry their type, there's nothing to declare
 
 	default:
 		panic(fmt.Sprintf("unhandled declaration: %T", decl))
 	}
 }
 
-func fixNewLineCode(s string) string {
-	var ret []byte
-
-	for i := 0; i < len(s); i++ {
-		if s[i] == '\\' && s[i+1] == 'n' {
-			ret = append(ret, '\n')
-			i += 1
-		} else {
-			ret = append(ret, s[i])
-		}
+func (ex *execution) interpretLoopBody(block *AST.StatementBlock, scope *Scope) (*Pseudo, bool) {
+	pseudo := ex.interpretStatement(block, scope)
+	if pseudo == nil {
+		return nil, false
 	}
 
-	return string(ret)
-}
-
-func generateIndent(level int) string {
-	if level <= 0 {
-		return ""
+	switch pseudo.Behavior {
+	case BREAK:
+		return nil, true
+	case RETURN:
+		return pseudo, true
+	case CONTINUE:
+		return nil, false
 	}
 
-	return strings.Repeat(" ", level*4)
+	panic("unreachable")
 }
 
-func printExpression(expr AST.Expression, scope *Scope, indentLevel int, newLine bool) {
-	nl := ""
-	indentForMembers := ""
-	indentForCloser := generateIndent(indentLevel)
-
-	if newLine {
-		nl = "\n"
-		indentForMembers = generateIndent(indentLevel + 1)
-	} else {
-		indentForCloser = ""
+// interpretCompoundAssignment evaluates the location of LHS once, reads it, applies the
+// operator with RHS and stores the result back
+func (ex *execution) interpretCompoundAssignment(v *AST.StatementAssignment, scope *Scope) {
+	apply := func(current Runtime.Value) Runtime.Value {
+		rhs := ex.interpretExpression(v.RHS, scope)
+		ex.at(*v.Operator)
+		return Runtime.BinaryOperation(v.Operator.Kind, current, rhs)
 	}
 
-	switch v := expr.(type) {
-	case *AST.ExpressionInteger:
-		fmt.Print(v.Value)
-
-	case *AST.ExpressionFloat:
-		fmt.Printf("%.5g", v.Value)
-
-	case *AST.ExpressionBoolean:
-		fmt.Print(v.Value)
-
-	case *AST.ExpressionString:
-		fmt.Print(fixNewLineCode(v.Value))
-
+	switch ev := v.LHS.(type) {
 	case *AST.ExpressionIdentifier:
-		printExpression(scope.get(v.Tok), scope, indentLevel, newLine)
-
-	case *AST.ExpressionArray:
-		fmt.Printf("[")
-
-		nextLevel := indentLevel + 1
-		for i, elem := range v.Elements {
-			printExpression(interpretExpression(elem, scope), scope, nextLevel, false)
-
-			if i < len(v.Elements)-1 {
-				fmt.Printf(", ")
-			}
-		}
-
-		fmt.Printf("%s]", indentForCloser)
-
-	case *AST.ExpressionStruct:
-		structDecl := globalStructs[v.Tok.Lexeme]
-
-		fmt.Printf("{")
-
-		nextLevel := indentLevel + 1
-
-		for i := 0; i < len(structDecl.Members); i++ {
-			name := structDecl.Members[i]
-			value := v.MemberValues[name.Tok.Lexeme]
-
-			fmt.Printf("%s%s", nl, indentForMembers)
+		scope.set(ev.Tok, apply(scope.get(ev.Tok)))
 
-			fmt.Printf("%s: %s = ", name.Tok.Lexeme, name.DeclType.String())
-
-			printExpression(interpretExpression(value, scope), scope, nextLevel, false)
+	case *AST.ExpressionAccessChain:
+		container, key := ex.evaluateAccessChainExpression(ev, scope)
+		switch k := key.(type) {
+		case *AST.ExpressionArrayAccess:
+			index := ex.interpretExpression(k.Index, scope)
+			ex.at(k.Tok)
+			result := apply(Runtime.Index(container, index))
+			ex.at(k.Tok)
+			Runtime.SetIndex(container, index, result)
 
-			if i < len(structDecl.Members)-1 {
-				fmt.Printf(", ")
-			}
+		case *AST.ExpressionIdentifier:
+			ex.at(k.Tok)
+			result := apply(Runtime.Member(container, k.Tok.Lexeme))
+			ex.at(k.Tok)
+			Runtime.SetMember(container, k.Tok.Lexeme, result)
 		}
 
-		fmt.Printf("%s%s}", nl, indentForCloser)
+	case *AST.ExpressionDereference:
+		pointer := ex.interpretExpression(ev.Operand, scope)
+		ex.at(ev.Tok)
+		result := apply(Runtime.Dereference(pointer))
+		ex.at(ev.Tok)
+		Runtime.Store(pointer, result)
 
 	default:
-		panic(fmt.Sprintf("unprintable type: %T", v))
+		panic("unreachable")
 	}
 }
 
-func interpretStatement(s AST.Statement, scope *Scope) AST.Expression {
+func (ex *execution) interpretStatement(s AST.Statement, scope *Scope) *Pseudo {
 	switch v := s.(type) {
 	case *AST.StatementPrint:
-		printExpression(interpretExpression(v.Expr, scope), scope, 0, true)
+		value := ex.interpretExpression(v.Expr, scope)
+		ex.at(v.Tok)
+		fmt.Fprint(ex.stdout, Runtime.Format(value))
 		if v.IsNewLine {
-			fmt.Println("")
+			fmt.Fprintln(ex.stdout)
 		}
 
 		return nil
 
 	case *AST.StatementAssignment:
-		if !scope.has(v.Tok) {
+		if _, ok := v.LHS.(*AST.ExpressionDereference); !ok && !scope.has(v.Tok) {
 			panic(fmt.Sprintf("Line %d | Attempting to assign to undeclared identifier: %s", v.Tok.Line, v.Tok.Lexeme))
 		}
 
-		rhs := interpretExpression(v.RHS, scope)
+		if v.Operator != nil {
+			ex.interpretCompoundAssignment(v, scope)
+			return nil
+		}
+
+		rhs := ex.interpretExpression(v.RHS, scope)
 		if rhs == nil {
-			panic(fmt.Sprintf("Line %d | Attempting to assign void to variable: %s", v.Tok.Line, v.Tok.Lexeme))
+			ex.at(v.Tok)
+			Runtime.Throw("Attempting to assign void to variable: %s", v.Tok.Lexeme)
 		}
 
+		rhs = Runtime.Copy(rhs)
+
 		switch ev := v.LHS.(type) {
 		case *AST.ExpressionIdentifier:
 			scope.set(ev.Tok, rhs)
 
 		case *AST.ExpressionAccessChain:
-			ret, index := evaluateAccessChainExpression(ev, scope)
-			switch lv := ret.(type) {
-			case *AST.ExpressionArray:
-				lv.Elements[interpretExpression(index, scope).(*AST.ExpressionInteger).Value] = rhs
-			case *AST.ExpressionStruct:
-				lv.MemberValues[index.(*AST.ExpressionIdentifier).Tok.Lexeme] = rhs
-
-			default:
-				panic("unreachable")
+			container, key := ex.evaluateAccessChainExpression(ev, scope)
+			switch k := key.(type) {
+			case *AST.ExpressionArrayAccess:
+				index := ex.interpretExpression(k.Index, scope)
+				ex.at(k.Tok)
+				Runtime.SetIndex(container, index, rhs)
+
+			case *AST.ExpressionIdentifier:
+				ex.at(k.Tok)
+				Runtime.SetMember(container, k.Tok.Lexeme, rhs)
 			}
 
+		case *AST.ExpressionDereference:
+			pointer := ex.interpretExpression(ev.Operand, scope)
+			ex.at(ev.Tok)
+			Runtime.Store(pointer, rhs)
+
 		default:
 			panic("unreachable")
 		}
@@ -485,50 +499,38 @@ func interpretStatement(s AST.Statement, scope *Scope) AST.Expression {
 
 	case *AST.StatementBlock:
 		blockScope := CreateScope(scope)
-		return interpretNodes(v.Body, &blockScope)
+		return ex.interpretNodes(v.Body, &blockScope)
 
 	case *AST.StatementFor:
 		forScope := CreateScope(scope)
-		interpretDeclaration(v.Initializer, &forScope)
-		for interpretExpression(v.Condition, &forScope).(*AST.ExpressionBoolean).Value {
-			blockRet := interpretStatement(v.Block, &forScope)
-			if pseudo, ok := blockRet.(*AST.ExpressionPseudo); ok {
-				if pseudo.Behavior == AST.BREAK {
-					break
-				} else if pseudo.Behavior == AST.RETURN {
-					return pseudo.Expr
-				} else if pseudo.Behavior == AST.CONTINUE {
-				} else {
-					panic("unreachable")
-				}
+		ex.interpretDeclaration(v.Initializer, &forScope)
+		for ex.interpretExpression(v.Condition, &forScope).(*Runtime.ValueBoolean).Value {
+			ex.at(v.Tok)
+			ex.checkCancelled()
+			if pseudo, done := ex.interpretLoopBody(v.Block, &forScope); done {
+				return pseudo
 			}
 
-			interpretStatement(v.Increment, &forScope)
+			ex.interpretStatement(v.Increment, &forScope)
 		}
 
 		return nil
 
 	case *AST.StatementWhile:
-		for interpretExpression(v.Condition, scope).(*AST.ExpressionBoolean).Value {
-			blockRet := interpretStatement(v.Block, scope)
-			if pseudo, ok := blockRet.(*AST.ExpressionPseudo); ok {
-				if pseudo.Behavior == AST.BREAK {
-					break
-				} else if pseudo.Behavior == AST.RETURN {
-					return pseudo.Expr
-				} else if pseudo.Behavior == AST.CONTINUE {
-				} else {
-					panic("unreachable")
-				}
+		for ex.interpretExpression(v.Condition, scope).(*Runtime.ValueBoolean).Value {
+			ex.at(v.Tok)
+			ex.checkCancelled()
+			if pseudo, done := ex.interpretLoopBody(v.Block, scope); done {
+				return pseudo
 			}
 		}
 
 		return nil
 
 	case *AST.StatementReturn:
-		return &AST.ExpressionPseudo{
-			Expr:     interpretExpression(v.Expr, scope),
-			Behavior: AST.RETURN,
+		return &Pseudo{
+			Value:    ex.interpretExpression(v.Expr, scope),
+			Behavior: RETURN,
 		}
 
 	case *AST.StatementDefer:
@@ -536,97 +538,221 @@ func interpretStatement(s AST.Statement, scope *Scope) AST.Expression {
 		return nil
 
 	case *AST.StatementBreak:
-		return &AST.ExpressionPseudo{
-			Expr:     nil,
-			Behavior: AST.BREAK,
+		return &Pseudo{
+			Value:    nil,
+			Behavior: BREAK,
 		}
 
 	case *AST.StatementContinue:
-		return &AST.ExpressionPseudo{
-			Expr:     nil,
-			Behavior: AST.CONTINUE,
+		return &Pseudo{
+			Value:    nil,
+			Behavior: CONTINUE,
 		}
 
 	case *AST.StatementIfElse:
-		cond := interpretExpression(v.Condition, scope).(*AST.ExpressionBoolean)
+		cond := ex.interpretExpression(v.Condition, scope).(*Runtime.ValueBoolean)
 		if cond.Value {
-			return interpretStatement(v.IfBlock, scope)
-		} else {
-			if v.ElseBlock != nil {
-				return interpretStatement(v.ElseBlock, scope)
+			return ex.interpretStatement(v.IfBlock, scope)
+		} else if v.ElseBlock != nil {
+			return ex.interpretStatement(v.ElseBlock, scope)
+		}
+
+		return nil
+
+	case *AST.StatementSwitch:
+		value := ex.interpretExpression(v.Value, scope)
+		for _, switchCase := range v.Cases {
+			for _, caseValue := range switchCase.Values {
+				if Runtime.Equal(value, ex.interpretExpression(caseValue, scope)) {
+					return ex.interpretStatement(switchCase.Body, scope)
+				}
 			}
 		}
 
-	case *AST.SE_FunctionCall:
-		functionDeclaration := globalFunctions[v.Tok.Lexeme]
-		argCount := len(v.Arguments)
-		paramCount := len(functionDeclaration.DeclType.Parameters)
+		if v.Default != nil {
+			return ex.interpretStatement(v.Default, scope)
+		}
 
-		if paramCount != argCount {
-			panic(fmt.Sprintf("expected %d parameter(s), got %d", argCount, paramCount))
+		return nil
+
+	case *AST.StatementMatch:
+		value := ex.interpretExpression(v.Value, scope)
+		ex.at(v.Tok)
+		variant := Runtime.Variant(value)
+		for _, matchCase := range v.Cases {
+			if matchCase.Index != variant {
+				continue
+			}
+
+			caseScope := CreateScope(scope)
+			if matchCase.Binding != nil {
+				caseScope.declare(*matchCase.Binding, Runtime.Payload(value))
+			}
+
+			return ex.interpretStatement(matchCase.Body, &caseScope)
 		}
 
-		functionScope := CreateScope(&globalScope)
-		for i := 0; i < argCount; i++ {
-			param := functionDeclaration.DeclType.Parameters[i]
-			arg := v.Arguments[i]
-			functionScope.set(param.Tok, interpretExpression(arg, scope))
+		if v.Default != nil {
+			return ex.interpretStatement(v.Default, scope)
 		}
 
-		return interpretExpression(interpretNodes(functionDeclaration.Block.Body, &functionScope), &functionScope)
+		return nil
+
+	case *AST.SE_FunctionCall, *AST.SE_MethodCall:
+		ex.interpretExpression(v.(AST.Expression), scope)
+		return nil
 
 	default:
-		fmt.Printf("Type: %T\n", v)
-		panic("unreachable")
+		panic(fmt.Sprintf("unreachable statement: %T", v))
 	}
-
-	return nil
 }
 
-func interpretNode(node AST.Node, scope *Scope) AST.Expression {
+func (ex *execution) interpretNode(node AST.Node, scope *Scope) *Pseudo {
 	switch v := node.(type) {
 	case AST.Statement:
-		ret := interpretStatement(v, scope)
-		if pseudo, ok := ret.(*AST.ExpressionPseudo); ok {
-			return pseudo
-		}
+		return ex.interpretStatement(v, scope)
 
 	case AST.Declaration:
-		interpretDeclaration(v, scope)
+		ex.interpretDeclaration(v, scope)
 	}
 
 	return nil
 }
 
-func interpretNodes(nodes []AST.Node, scope *Scope) AST.Expression {
-	defer scope.ResolveDeferStack()
+// interpretNodes runs the block's defers on the way out, but not when a runtime error
+// unwinds through it, the program is aborted at the point of the error
+func (ex *execution) interpretNodes(nodes []AST.Node, scope *Scope) *Pseudo {
 	for _, node := range nodes {
-		ret := interpretNode(node, scope)
-		if pseudo, ok := ret.(*AST.ExpressionPseudo); ok {
+		if pseudo := ex.interpretNode(node, scope); pseudo != nil {
+			scope.resolveDeferStack(ex)
 			return pseudo
 		}
 	}
 
+	scope.resolveDeferStack(ex)
 	return nil
 }
 
-func InterpretProgram(program AST.Program) {
-	globalScope = CreateScope(nil)
-	globalFunctions = make(map[string]*AST.DeclarationFunction)
-	globalStructs = make(map[string]*AST.DeclarationStruct)
+// Options configures an Interpreter. Nil streams default to the process' own and an empty
+// Entry to "main".
+type Options struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// Entry is the function Run calls, it takes either no parameters or a []string
+	// which receives Args.
+	Entry string
+	Args  []string
+
+	// Natives are the Go functions the program may call, the same ones it was type checked with
+	Natives *Runtime.Natives
+}
+
+// Interpreter runs a type checked program. It holds no state between runs, so Run can be
+// called any number of times, from any number of goroutines.
+type Interpreter struct {
+	program AST.Program
+	options Options
+}
+
+// Result is what the entry function returned, Value is nil for void functions and
+// ExitCode is the returned int, 0 for anything else.
+type Result struct {
+	Value    Runtime.Value
+	ExitCode int
+}
+
+func New(program AST.Program, options Options) *Interpreter {
+	if options.Stdout == nil {
+		options.Stdout = os.Stdout
+	}
+
+	if options.Stderr == nil {
+		options.Stderr = os.Stderr
+	}
+
+	if options.Stdin == nil {
+		options.Stdin = os.Stdin
+	}
 
-	for _, decl := range program.Declarations {
-		interpretDeclaration(decl, &globalScope)
+	if options.Entry == "" {
+		options.Entry = "main"
 	}
 
-	if mainDecl, ok := globalFunctions["main"]; ok {
-		mainCall := &AST.SE_FunctionCall{
-			Tok:       mainDecl.Tok,
-			Arguments: nil,
+	return &Interpreter{
+		program: program,
+		options: options,
+	}
+}
+
+// Run evaluates the global declarations and then calls the entry function. A runtime error,
+// including ctx being cancelled while the program runs, is returned as a *Runtime.Error.
+func (i *Interpreter) Run(ctx context.Context) (result Result, err error) {
+	ex := &execution{
+		ctx:       ctx,
+		functions: make(map[string]*Runtime.ValueFunction),
+		structs:   make(map[string]*AST.DeclarationStruct),
+		globals:   CreateScope(nil),
+		natives:   i.options.Natives,
+		host: &Runtime.Host{
+			Context: ctx,
+			Stdout:  i.options.Stdout,
+			Stderr:  i.options.Stderr,
+			Stdin:   i.options.Stdin,
+		},
+		stdout: i.options.Stdout,
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = Result{}, Runtime.Recovered(r, ex.stackTrace())
 		}
+	}()
 
-		interpretStatement(mainCall, &globalScope)
-	} else {
-		panic("main function not found")
+	ex.pushFrame("<init>", Token.Token{})
+	for _, decl := range i.program.Declarations {
+		ex.interpretDeclaration(decl, &ex.globals)
 	}
+	ex.popFrame()
+
+	entry, ok := ex.functions[i.options.Entry]
+	if !ok {
+		return Result{}, &Runtime.Error{Message: i.options.Entry + " function not found"}
+	}
+
+	params := entry.Decl.DeclType.Parameters
+	var arguments []Runtime.Value
+	switch {
+	case len(params) == 0:
+
+	case len(params) == 1 && params[0].DeclType.IsArray() && params[0].DeclType.Next.Kind == TS.STRING:
+		elements := make([]Runtime.Value, len(i.options.Args))
+		for i, arg := range i.options.Args {
+			elements[i] = &Runtime.ValueString{Value: arg}
+		}
+
+		arguments = append(arguments, &Runtime.ValueArray{
+			Elements: elements,
+			DeclType: params[0].DeclType,
+		})
+
+	default:
+		return Result{}, &Runtime.Error{Message: i.options.Entry + " function has to take no parameters or a []string"}
+	}
+
+	result.Value = ex.callFunction(entry, arguments)
+	if ret, ok := result.Value.(*Runtime.ValueInteger); ok {
+		result.ExitCode = ret.Value
+	}
+
+	return result, nil
+}
+
+// InterpretProgram runs main, passing args through when main takes a []string, and
+// writes everything the program prints to stdout. The returned value is main's int
+// result or 0 when main returns void, a runtime error is returned as a *Runtime.Error.
+func InterpretProgram(program AST.Program, args []string, stdout io.Writer) (int, error) {
+	result, err := New(program, Options{Stdout: stdout, Args: args}).Run(context.Background())
+	return result.ExitCode, err
 }
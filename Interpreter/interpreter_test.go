@@ -0,0 +1,271 @@
+package Interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"ion-go/AST"
+	"ion-go/Diagnostic"
+	"ion-go/Lexer"
+	"ion-go/Parser"
+	"ion-go/Runtime"
+	"ion-go/TS"
+	"ion-go/TypeChecker"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func compile(t *testing.T, source string, natives *Runtime.Natives) AST.Program {
+	t.Helper()
+
+	tokenStream, diagnostics := Lexer.GenerateTokenStreamFromSource("test.ion", []byte(source))
+	program, parseDiagnostics := Parser.ParseProgram(tokenStream)
+	diagnostics = append(diagnostics, parseDiagnostics...)
+	if !Diagnostic.HasErrors(diagnostics) {
+		diagnostics = TypeChecker.TypeCheckProgram(program, natives)
+	}
+
+	if Diagnostic.HasErrors(diagnostics) {
+		t.Fatalf("program doesn't compile: %v", diagnostics)
+	}
+
+	return program
+}
+
+// lockedBuffer lets concurrent runs share a stdout
+type lockedBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func TestRunConcurrently(t *testing.T) {
+	var stdout lockedBuffer
+	interpreter := New(compile(t, `
+var calls := 0;
+
+fn main(args: []string) -> int {
+    for (var i := 0; i < 100; i = i + 1) {
+        calls = calls + 1;
+    }
+
+    println(args[0] + " " + calls);
+    return len(args[0]);
+}
+`, nil), Options{Stdout: &stdout, Args: []string{"run"}})
+
+	const runs = 8
+
+	var wg sync.WaitGroup
+	for n := 0; n < runs; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result, err := interpreter.Run(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if result.ExitCode != 3 {
+				t.Errorf("expected exit code 3, got %d", result.ExitCode)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if expected := strings.Repeat("run 100\n", runs); stdout.buffer.String() != expected {
+		t.Errorf("every run should have its own globals, expected %q, got %q", expected, stdout.buffer.String())
+	}
+}
+
+func TestRunEntry(t *testing.T) {
+	program := compile(t, `
+fn main() -> void {
+    println("main");
+}
+
+fn greet() -> string {
+    println("greet");
+    return "hello";
+}
+`, nil)
+
+	var stdout bytes.Buffer
+	result, err := New(program, Options{Stdout: &stdout, Entry: "greet"}).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, ok := result.Value.(*Runtime.ValueString); !ok || s.Value != "hello" {
+		t.Errorf("expected \"hello\", got %v", result.Value)
+	}
+
+	if stdout.String() != "greet\n" {
+		t.Errorf("expected only greet to run, got %q", stdout.String())
+	}
+
+	if _, err := New(program, Options{Stdout: &stdout, Entry: "missing"}).Run(context.Background()); err == nil {
+		t.Error("expected an error for a missing entry function")
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	program := compile(t, `
+fn main() -> void {
+    while (true) {
+    }
+}
+`, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := New(program, Options{}).Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to stop the program, got %v", err)
+	}
+
+	var runtimeError *Runtime.Error
+	if !errors.As(err, &runtimeError) || runtimeError.Line() != 3 {
+		t.Errorf("expected a runtime error on line 3, got %v", err)
+	}
+}
+
+func testNatives(t *testing.T) *Runtime.Natives {
+	t.Helper()
+
+	intType := TS.NewType(TS.INTEGER, nil, nil)
+	stringType := TS.NewType(TS.STRING, nil, nil)
+
+	natives := Runtime.NewNatives()
+	err := natives.Register("repeat", TS.NewType(TS.FUNCTION, stringType, []TS.Parameter{{DeclType: stringType}, {DeclType: intType}}),
+		func(host *Runtime.Host, args []Runtime.Value) (Runtime.Value, error) {
+			count := args[1].(*Runtime.ValueInteger).Value
+			if count < 0 {
+				return nil, errors.New("negative count")
+			}
+
+			return &Runtime.ValueString{Value: strings.Repeat(args[0].(*Runtime.ValueString).Value, count)}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = natives.Register("log", TS.NewType(TS.FUNCTION, TS.NewType(TS.VOID, nil, nil), []TS.Parameter{{DeclType: stringType}}),
+		func(host *Runtime.Host, args []Runtime.Value) (Runtime.Value, error) {
+			_, err := fmt.Fprintln(host.Stderr, "log: "+args[0].(*Runtime.ValueString).Value)
+			return nil, err
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := natives.Register("log", TS.NewType(TS.STRING, nil, nil), nil); err == nil {
+		t.Error("expected registering log twice to fail")
+	}
+
+	return natives
+}
+
+func TestNatives(t *testing.T) {
+	natives := testNatives(t)
+	program := compile(t, `
+fn main(args: []string) -> void {
+    log("start");
+    println(repeat("ab", 3));
+    println(repeat("x", len(args) - 2));
+}
+`, natives)
+
+	var stdout, stderr bytes.Buffer
+	_, err := New(program, Options{Stdout: &stdout, Stderr: &stderr, Args: []string{"a"}, Natives: natives}).Run(context.Background())
+
+	var runtimeError *Runtime.Error
+	if !errors.As(err, &runtimeError) || runtimeError.Message != "repeat: negative count" || runtimeError.Line() != 5 {
+		t.Errorf("expected repeat to fail on line 5, got %v", err)
+	}
+
+	if stdout.String() != "ababab\n" || stderr.String() != "log: start\n" {
+		t.Errorf("unexpected output, stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestNativesTypeCheck(t *testing.T) {
+	tokenStream, _ := Lexer.GenerateTokenStreamFromSource("test.ion", []byte(`
+fn log(message: string) -> void {
+}
+
+fn main() -> void {
+    var s: int = repeat("ab", "3");
+}
+`))
+	program, _ := Parser.ParseProgram(tokenStream)
+	diagnostics := TypeChecker.TypeCheckProgram(program, testNatives(t))
+
+	expected := []string{
+		"Attempting to redeclare native function log",
+		"repeat() argument 1: expected int, got string",
+		"Can't assign type string to type int",
+	}
+
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+
+	for i, diagnostic := range diagnostics {
+		if !strings.Contains(diagnostic.Message, expected[i]) {
+			t.Errorf("expected %q, got %q", expected[i], diagnostic.Message)
+		}
+	}
+}
+
+func TestTypeCheckConcurrently(t *testing.T) {
+	sources := []string{`
+struct Pair[A, B] { first: A, second: B }
+
+fn main() -> void {
+    var p := Pair.{1, "one"};
+    println(p.second);
+}
+`, `
+fn main() -> void {
+    var s: int = repeat("ab", 3);
+}
+`}
+	expected := []string{"", "Can't assign type string to type int"}
+
+	const checks = 8
+
+	var wg sync.WaitGroup
+	for n := 0; n < checks; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			i := n % len(sources)
+			tokenStream, _ := Lexer.GenerateTokenStreamFromSource("test.ion", []byte(sources[i]))
+			program, _ := Parser.ParseProgram(tokenStream)
+			diagnostics := TypeChecker.TypeCheckProgram(program, testNatives(t))
+
+			if expected[i] == "" && len(diagnostics) != 0 {
+				t.Errorf("expected no diagnostics, got %v", diagnostics)
+			} else if expected[i] != "" && (len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, expected[i])) {
+				t.Errorf("every check should have its own diagnostics, expected %q, got %v", expected[i], diagnostics)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -3,26 +3,27 @@ package Interpreter
 import (
 	"fmt"
 	"ion-go/AST"
+	"ion-go/Runtime"
 	"ion-go/Token"
 )
 
 type Scope struct {
 	parent     *Scope
-	variables  map[string]AST.Expression
+	variables  map[string]Runtime.Value
 	deferStack []*AST.StatementDefer
 }
 
 func CreateScope(parent *Scope) Scope {
 	return Scope{
 		parent:     parent,
-		variables:  make(map[string]AST.Expression),
+		variables:  make(map[string]Runtime.Value),
 		deferStack: make([]*AST.StatementDefer, 0),
 	}
 }
 
-func (s *Scope) ResolveDeferStack() {
+func (s *Scope) resolveDeferStack(ex *execution) {
 	for i := len(s.deferStack) - 1; i >= 0; i-- {
-		interpretNode(s.deferStack[i].DeferredNode, s)
+		ex.interpretNode(s.deferStack[i].DeferredNode, s)
 	}
 
 	s.deferStack = nil
@@ -45,7 +46,7 @@ func (s *Scope) has(key Token.Token) bool {
 	return false
 }
 
-func (s *Scope) get(key Token.Token) AST.Expression {
+func (s *Scope) get(key Token.Token) Runtime.Value {
 	current := s
 	for current != nil {
 		value, ok := current.variables[key.Lexeme]
@@ -56,10 +57,14 @@ func (s *Scope) get(key Token.Token) AST.Expression {
 	}
 
 	panic(fmt.Sprintf("Line: %d | Undeclared Identifier: %s", key.Line, key.Lexeme))
-	return nil
 }
 
-func (s *Scope) set(key Token.Token, value AST.Expression) {
+// declare always binds in this scope, set assigns to the nearest scope that already has the name
+func (s *Scope) declare(key Token.Token, value Runtime.Value) {
+	s.variables[key.Lexeme] = value
+}
+
+func (s *Scope) set(key Token.Token, value Runtime.Value) {
 	current := s
 	for current != nil {
 		_, ok := current.variables[key.Lexeme]
@@ -72,3 +77,29 @@ func (s *Scope) set(key Token.Token, value AST.Expression) {
 
 	s.variables[key.Lexeme] = value
 }
+
+// variableReference is what &variable points to, the scope lives on for as long as it's referenced
+type variableReference struct {
+	scope *Scope
+	name  string
+}
+
+func (r variableReference) Load() Runtime.Value {
+	return r.scope.variables[r.name]
+}
+
+func (r variableReference) Store(value Runtime.Value) {
+	r.scope.variables[r.name] = value
+}
+
+func (s *Scope) reference(key Token.Token) Runtime.Reference {
+	current := s
+	for current != nil {
+		if _, ok := current.variables[key.Lexeme]; ok {
+			return variableReference{scope: current, name: key.Lexeme}
+		}
+		current = current.parent
+	}
+
+	panic(fmt.Sprintf("Line: %d | Undeclared Identifier: %s", key.Line, key.Lexeme))
+}
@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"ion-go/AST"
+	"ion-go/TS"
+	"os"
+	"sort"
+	"strings"
 )
 
 func expressionToJson(e AST.Expression) any {
@@ -14,19 +19,44 @@ func expressionToJson(e AST.Expression) any {
 
 	switch v := e.(type) {
 	case *AST.ExpressionInteger:
+		if v.Kind != "" {
+			return map[string]any{"Integer": v.Value, "Type": v.Kind}
+		}
+
 		return v.Value
 	case *AST.ExpressionFloat:
+		if v.Kind != "" {
+			return map[string]any{"Float": v.Value, "Type": v.Kind}
+		}
+
 		return v.Value
 	case *AST.ExpressionBoolean:
 		return v.Value
 	case *AST.ExpressionString:
 		return v.Value
+	case *AST.ExpressionChar:
+		return map[string]any{
+			"Char": string(v.Value),
+		}
 
 	case *AST.ExpressionIdentifier:
 		return map[string]any{
 			"Identifier": v.Tok.Lexeme,
 		}
 
+	case *AST.ExpressionUnion:
+		return map[string]any{
+			"Union": map[string]any{
+				"Variant": v.Tok.Lexeme + "." + v.Variant.Lexeme,
+				"Payload": expressionToJson(v.Payload),
+			},
+		}
+
+	case *AST.ExpressionEnumValue:
+		return map[string]any{
+			"EnumValue": v.Tok.Lexeme + "." + v.Variant.Lexeme,
+		}
+
 	case *AST.ExpressionBinary:
 		return map[string]any{
 			"BinaryOp": map[string]any{
@@ -36,9 +66,33 @@ func expressionToJson(e AST.Expression) any {
 			},
 		}
 	case *AST.ExpressionArray:
+		var elements []any
+		for _, element := range v.Elements {
+			elements = append(elements, expressionToJson(element))
+		}
+
+		return map[string]any{
+			"ExpressionArray": map[string]any{
+				"Elements": elements,
+				"DeclType": v.DeclType.String(),
+			},
+		}
+
+	case *AST.ExpressionStruct:
+		values := make(map[string]any)
+		for name, value := range v.MemberValues {
+			values[name] = expressionToJson(value)
+		}
+
+		desc := map[string]any{
+			"Type":    v.Tok.Lexeme,
+			"Members": values,
+		}
+		if v.TypeArguments != nil {
+			desc["TypeArguments"] = typesToJson(v.TypeArguments)
+		}
 		return map[string]any{
-			"Elements": v.Elements,
-			"DeclType": v.DeclType,
+			"ExpressionStruct": desc,
 		}
 
 	case *AST.ExpressionLen:
@@ -48,7 +102,33 @@ func expressionToJson(e AST.Expression) any {
 
 	case *AST.ExpressionUnary:
 		return map[string]any{
-			"ExpressionUnary": expressionToJson(v.Operand),
+			"ExpressionUnary": map[string]any{
+				"Op":      v.Operator.Lexeme,
+				"Operand": expressionToJson(v.Operand),
+			},
+		}
+
+	case *AST.ExpressionAddressOf:
+		return map[string]any{
+			"ExpressionAddressOf": expressionToJson(v.Operand),
+		}
+
+	case *AST.ExpressionDereference:
+		return map[string]any{
+			"ExpressionDereference": expressionToJson(v.Operand),
+		}
+
+	case *AST.ExpressionNullptr:
+		return "nullptr"
+
+	case *AST.ExpressionTuple:
+		var elements []any
+		for _, element := range v.Elements {
+			elements = append(elements, expressionToJson(element))
+		}
+
+		return map[string]any{
+			"ExpressionTuple": elements,
 		}
 
 	case *AST.ExpressionGrouping:
@@ -57,35 +137,102 @@ func expressionToJson(e AST.Expression) any {
 		}
 
 	case *AST.SE_FunctionCall:
+		var arguments []any
+		for _, argument := range v.Arguments {
+			arguments = append(arguments, expressionToJson(argument))
+		}
+
+		call := map[string]any{
+			"Arguments": arguments,
+		}
+		if v.Callee != nil {
+			call["Callee"] = expressionToJson(v.Callee)
+		} else {
+			call["Name"] = v.Tok.Lexeme
+		}
+
+		return map[string]any{
+			"FunctionCall": call,
+		}
+
+	case *AST.ExpressionFunction:
+		var body []any
+		for _, node := range v.Decl.Block.Body {
+			body = append(body, nodeToJson(node))
+		}
+
+		return map[string]any{
+			"Function": map[string]any{
+				"DeclType": v.Decl.DeclType.String(),
+				"Body":     body,
+			},
+		}
+
+	case *AST.SE_MethodCall:
+		var arguments []any
+		for _, argument := range v.Arguments {
+			arguments = append(arguments, expressionToJson(argument))
+		}
+
 		return map[string]any{
-			"FunctionCall": nil,
+			"MethodCall": map[string]any{
+				"Receiver":  expressionToJson(v.Receiver),
+				"Name":      v.Tok.Lexeme,
+				"Arguments": arguments,
+			},
 		}
 
 	case *AST.ExpressionAccessChain:
+		var keys []any
+		for _, key := range v.AccessKeys {
+			switch ev := key.(type) {
+			case *AST.ExpressionIdentifier:
+				keys = append(keys, map[string]any{"Member": ev.Tok.Lexeme})
+			case *AST.ExpressionArrayAccess:
+				keys = append(keys, map[string]any{"Index": expressionToJson(ev.Index)})
+			}
+		}
+
+		var base any = v.Tok.Lexeme
+		if v.Base != nil {
+			base = expressionToJson(v.Base)
+		}
+
 		return map[string]any{
-			"ExpressionAccessChain": nil,
+			"ExpressionAccessChain": map[string]any{
+				"Base": base,
+				"Keys": keys,
+			},
 		}
 
 	case *AST.ExpressionTypeCast:
 		return map[string]any{
-			"ExpressionTypeCast": nil,
+			"ExpressionTypeCast": map[string]any{
+				"CastType": v.CastType.String(),
+				"Expr":     expressionToJson(v.Expr),
+			},
 		}
 
 	default:
 		panic(fmt.Sprintf("%T", v))
 	}
-
-	return nil
 }
 
 func statementToJson(s AST.Statement) any {
 	switch v := s.(type) {
 	case *AST.StatementAssignment:
+		assignment := map[string]any{
+			"lhs": expressionToJson(v.LHS),
+			"rhs": expressionToJson(v.RHS),
+		}
+		if v.Increment {
+			assignment["operator"] = v.Operator.Lexeme + v.Operator.Lexeme
+		} else if v.Operator != nil {
+			assignment["operator"] = v.Operator.Lexeme + "="
+		}
+
 		return map[string]any{
-			"AssignmentStatement": map[string]any{
-				"lhs": expressionToJson(v.LHS),
-				"rhs": expressionToJson(v.RHS),
-			},
+			"AssignmentStatement": assignment,
 		}
 
 	case *AST.StatementReturn:
@@ -101,6 +248,11 @@ func statementToJson(s AST.Statement) any {
 	case *AST.StatementContinue:
 		return "ContinueStatement"
 
+	case *AST.StatementError:
+		return map[string]any{
+			"ErrorStatement": v.Tok.Line,
+		}
+
 	case *AST.StatementBreak:
 		return "BreakStatement"
 
@@ -137,6 +289,61 @@ func statementToJson(s AST.Statement) any {
 			},
 		}
 
+	case *AST.StatementSwitch:
+		cases := make([]any, len(v.Cases))
+		for i, switchCase := range v.Cases {
+			values := make([]any, len(switchCase.Values))
+			for j, value := range switchCase.Values {
+				values[j] = expressionToJson(value)
+			}
+
+			cases[i] = map[string]any{
+				"Values": values,
+				"Body":   statementToJson(switchCase.Body),
+			}
+		}
+
+		var defaultJson any = nil
+		if v.Default != nil {
+			defaultJson = statementToJson(v.Default)
+		}
+
+		return map[string]any{
+			"SwitchStatement": map[string]any{
+				"Value":   expressionToJson(v.Value),
+				"Cases":   cases,
+				"Default": defaultJson,
+			},
+		}
+
+	case *AST.StatementMatch:
+		cases := make([]any, len(v.Cases))
+		for i, matchCase := range v.Cases {
+			var bindingJson any = nil
+			if matchCase.Binding != nil {
+				bindingJson = matchCase.Binding.Lexeme
+			}
+
+			cases[i] = map[string]any{
+				"Variant": matchCase.Variant.Lexeme,
+				"Binding": bindingJson,
+				"Body":    statementToJson(matchCase.Body),
+			}
+		}
+
+		var defaultJson any = nil
+		if v.Default != nil {
+			defaultJson = statementToJson(v.Default)
+		}
+
+		return map[string]any{
+			"MatchStatement": map[string]any{
+				"Value":   expressionToJson(v.Value),
+				"Cases":   cases,
+				"Default": defaultJson,
+			},
+		}
+
 	case *AST.StatementIfElse:
 		var elseBlockJson any = nil
 		if v.ElseBlock != nil {
@@ -154,8 +361,6 @@ func statementToJson(s AST.Statement) any {
 	default:
 		panic(fmt.Sprintf("%T", v))
 	}
-
-	return nil
 }
 
 func declarationToJson(decl AST.Declaration) map[string]any {
@@ -164,11 +369,28 @@ func declarationToJson(decl AST.Declaration) map[string]any {
 		desc := map[string]any{
 			"Name":     v.Tok.Lexeme,
 			"DeclType": v.DeclType.String(),
+			"RHS":      expressionToJson(v.RHS),
 		}
 		return map[string]any{
 			"VariableDeclaration": desc,
 		}
 
+	case *AST.DeclarationDestructure:
+		var variables []any
+		for _, variable := range v.Variables {
+			variables = append(variables, map[string]any{
+				"Name":     variable.Tok.Lexeme,
+				"DeclType": variable.DeclType.String(),
+			})
+		}
+
+		return map[string]any{
+			"DestructureDeclaration": map[string]any{
+				"Variables": variables,
+				"RHS":       expressionToJson(v.RHS),
+			},
+		}
+
 	case *AST.DeclarationFunction:
 		var body []any
 		for _, node := range v.Block.Body {
@@ -179,23 +401,89 @@ func declarationToJson(decl AST.Declaration) map[string]any {
 			"DeclType": v.DeclType.String(),
 			"Body":     body,
 		}
+		if v.Receiver != nil {
+			desc["Receiver"] = v.Receiver.String()
+		}
+		if v.TypeParameters != nil {
+			desc["TypeParameters"] = typesToJson(v.TypeParameters)
+		}
 		return map[string]any{
 			"FunctionDeclaration": desc,
 		}
 
 	case *AST.DeclarationStruct:
+		var members []any
+		for _, member := range v.Members {
+			members = append(members, map[string]any{
+				"Name":     member.Tok.Lexeme,
+				"DeclType": member.DeclType.String(),
+			})
+		}
+
 		desc := map[string]any{
-			"Name": v.Tok.Lexeme,
+			"Name":    v.Tok.Lexeme,
+			"Members": members,
+		}
+		if v.TypeParameters != nil {
+			desc["TypeParameters"] = typesToJson(v.TypeParameters)
 		}
 		return map[string]any{
 			"StructDeclaration": desc,
 		}
 
+	case *AST.DeclarationEnum:
+		var variants []any
+		for _, variant := range v.Variants {
+			variants = append(variants, variant.Lexeme)
+		}
+
+		desc := map[string]any{
+			"Name":     v.Tok.Lexeme,
+			"Variants": variants,
+		}
+		return map[string]any{
+			"EnumDeclaration": desc,
+		}
+
+	case *AST.DeclarationUnion:
+		var variants []any
+		for _, variant := range v.Variants {
+			variants = append(variants, map[string]any{
+				"Name":     variant.Tok.Lexeme,
+				"DeclType": variant.DeclType.String(),
+			})
+		}
+
+		desc := map[string]any{
+			"Name":     v.Tok.Lexeme,
+			"Variants": variants,
+		}
+		return map[string]any{
+			"UnionDeclaration": desc,
+		}
+
+	case *AST.DeclarationError:
+		return map[string]any{
+			"ErrorDeclaration": v.Tok.Line,
+		}
+
 	default:
 		panic(fmt.Sprintf("%T", v))
 	}
+}
 
-	return nil
+// typesToJson is a list of types, a type parameter reads with its constraint e.g. T: Ordered
+func typesToJson(types []*TS.Type) []any {
+	var ret []any
+	for _, t := range types {
+		if t.IsTypeParameter() && t.Constraint != "" {
+			ret = append(ret, t.String()+": "+t.Constraint)
+		} else {
+			ret = append(ret, t.String())
+		}
+	}
+
+	return ret
 }
 
 func nodeToJson(node AST.Node) any {
@@ -212,7 +500,6 @@ func nodeToJson(node AST.Node) any {
 	default:
 		panic(fmt.Sprintf("%T", v))
 	}
-	return nil
 }
 
 func MarshalIndentNoEscape(v any, prefix, indent string) ([]byte, error) {
@@ -231,16 +518,65 @@ func MarshalIndentNoEscape(v any, prefix, indent string) ([]byte, error) {
 	return out, nil
 }
 
-func PrettyPrint(program AST.Program) {
+func programToJson(program AST.Program) map[string]any {
 	var declarations []any
 	for _, decl := range program.Declarations {
 		declarations = append(declarations, declarationToJson(decl))
 	}
 
-	indent, err := MarshalIndentNoEscape(map[string]any{"Declarations": declarations}, "", "    ")
+	return map[string]any{"Declarations": declarations}
+}
+
+func Fprint(w io.Writer, program AST.Program) error {
+	indent, err := MarshalIndentNoEscape(programToJson(program), "", "    ")
 	if err != nil {
-		return
+		return err
 	}
 
-	fmt.Println(string(indent))
+	_, err = fmt.Fprintln(w, string(indent))
+	return err
+}
+
+func PrettyPrint(program AST.Program) {
+	Fprint(os.Stdout, program)
+}
+
+func writeTree(w io.Writer, label string, value any, depth int) {
+	indent := strings.Repeat("    ", depth)
+
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		// A single keyed map is just a node kind wrapping its payload, fold it into one line
+		if len(keys) == 1 && label == "-" {
+			writeTree(w, "- "+keys[0], v[keys[0]], depth)
+			return
+		}
+
+		fmt.Fprintf(w, "%s%s\n", indent, label)
+		for _, key := range keys {
+			writeTree(w, key, v[key], depth+1)
+		}
+
+	case []any:
+		fmt.Fprintf(w, "%s%s\n", indent, label)
+		for _, element := range v {
+			writeTree(w, "-", element, depth+1)
+		}
+
+	default:
+		fmt.Fprintf(w, "%s%s: %v\n", indent, label, v)
+	}
+}
+
+// PrintTree writes the same information as Fprint as an indented outline meant for humans
+func PrintTree(w io.Writer, program AST.Program) {
+	for _, decl := range programToJson(program)["Declarations"].([]any) {
+		writeTree(w, "-", decl, 0)
+	}
 }
@@ -2,29 +2,43 @@ package Lexer
 
 import (
 	"fmt"
+	"ion-go/Diagnostic"
+	"ion-go/TS"
 	"ion-go/Token"
 	"os"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
-	leftPos  int
-	rightPos int
-	line     int
-	c        byte
-	source   []byte
-	tokens   []Token.Token
+	leftPos     int
+	rightPos    int
+	line        int
+	lineStart   int // offset of the first byte of the current line
+	startLine   int // line of leftPos
+	startColumn int // column of leftPos
+	c           byte
+	file        string
+	source      []byte
+	tokens      []Token.Token
+	diagnostics []Diagnostic.Diagnostic
 }
 
-func createLexer() Lexer {
+func createLexer(file string, source []byte) Lexer {
 	return Lexer{
-		leftPos:  0,
-		rightPos: 0,
-		line:     1,
-		c:        0,
-		source:   []byte{},
-		tokens:   []Token.Token{},
+		leftPos:     0,
+		rightPos:    0,
+		line:        1,
+		lineStart:   0,
+		startLine:   1,
+		startColumn: 1,
+		c:           0,
+		file:        file,
+		source:      source,
+		tokens:      []Token.Token{},
+		diagnostics: []Diagnostic.Diagnostic{},
 	}
 }
 
@@ -43,19 +57,46 @@ func (lexer *Lexer) consumeUntilNewLine() {
 }
 
 func (lexer *Lexer) reportError(format string, args ...interface{}) {
-	fmt.Println("String:", string(lexer.getScratchBuffer()))
-	fmt.Println("Data:", []byte(lexer.getScratchBuffer()))
-	fmt.Println("Error Line:", lexer.line, "|", fmt.Sprintf(format, args...))
+	span := lexer.rightPos - lexer.leftPos
+	if lexer.line != lexer.startLine {
+		span = 1
+	}
+
+	lexer.diagnostics = append(lexer.diagnostics, Diagnostic.Diagnostic{
+		Severity: Diagnostic.ERROR,
+		File:     lexer.file,
+		Line:     lexer.startLine,
+		Column:   lexer.startColumn,
+		Span:     max(span, 1),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
 
-	panic("")
+// reportErrorAt points at span bytes from line and column rather than at the whole
+// token, e.g. at one bad escape sequence in a string
+func (lexer *Lexer) reportErrorAt(line int, column int, span int, format string, args ...interface{}) {
+	lexer.diagnostics = append(lexer.diagnostics, Diagnostic.Diagnostic{
+		Severity: Diagnostic.ERROR,
+		File:     lexer.file,
+		Line:     line,
+		Column:   column,
+		Span:     max(span, 1),
+		Message:  fmt.Sprintf(format, args...),
+	})
 }
 
 func (lexer *Lexer) consumeNextChar() {
+	if lexer.isEOF() {
+		lexer.c = 0
+		return
+	}
+
 	lexer.c = lexer.source[lexer.rightPos]
 	lexer.rightPos += 1
 
 	if lexer.c == '\n' {
 		lexer.line++
+		lexer.lineStart = lexer.rightPos
 	}
 }
 
@@ -89,6 +130,8 @@ func (lexer *Lexer) consumeWord() bool {
 
 func (lexer *Lexer) consumeNextToken() {
 	lexer.leftPos = lexer.rightPos
+	lexer.startLine = lexer.line
+	lexer.startColumn = lexer.leftPos - lexer.lineStart + 1
 	lexer.consumeNextChar()
 
 	if isWhitespace(lexer.c) {
@@ -96,7 +139,7 @@ func (lexer *Lexer) consumeNextToken() {
 	} else if lexer.consumeIdentifier() {
 	} else if lexer.consumeSyntax() {
 	} else {
-		lexer.reportError("Illegal token found")
+		lexer.reportError("Illegal token found: %q", lexer.getScratchBuffer())
 	}
 }
 
@@ -113,10 +156,14 @@ func (lexer *Lexer) consumeSyntax() bool {
 	switch lexer.c {
 	case '&':
 		if lexer.consumeOnMatch('&') {
+		} else {
+			lexer.consumeOnMatch('=')
 		}
 
 	case '|':
 		if lexer.consumeOnMatch('|') {
+		} else {
+			lexer.consumeOnMatch('=')
 		}
 
 	case '[':
@@ -158,7 +205,8 @@ func (lexer *Lexer) consumeSyntax() bool {
 		} else if lexer.consumeOnMatch('*') {
 			for !(lexer.peekNthChar(0) == '*' && lexer.peekNthChar(1) == '/') {
 				if lexer.isEOF() {
-					panic("Multiline comment doesn't terminate\n")
+					lexer.reportError("Multiline comment doesn't terminate")
+					return true
 				}
 
 				lexer.consumeNextChar()
@@ -170,7 +218,7 @@ func (lexer *Lexer) consumeSyntax() bool {
 			return true
 		}
 
-	case '!', '*', '=':
+	case '!', '*', '=', '^', '%':
 		lexer.consumeOnMatch('=')
 	}
 
@@ -206,49 +254,318 @@ func (lexer *Lexer) consumeIdentifier() bool {
 }
 
 func (lexer *Lexer) addToken(kind Token.TokenType) {
-	lexer.tokens = append(lexer.tokens, Token.CreateToken(kind, lexer.getScratchBuffer(), lexer.line))
+	lexer.tokens = append(lexer.tokens, Token.CreateToken(kind, lexer.getScratchBuffer(), lexer.file, lexer.startLine, lexer.startColumn))
+}
+
+func (lexer *Lexer) addTokenWithValue(kind Token.TokenType, value string) {
+	lexer.addToken(kind)
+	lexer.tokens[len(lexer.tokens)-1].Value = value
 }
 
+// tryConsumeStringLiteral decodes escape sequences as it goes, the token's Value is what the
+// string holds at runtime
 func (lexer *Lexer) tryConsumeStringLiteral() {
+	var value strings.Builder
 	for !lexer.consumeOnMatch('"') {
 		if lexer.isEOF() {
 			lexer.reportError("String literal doesn't have a closing double quote!")
+			return
+		}
+
+		lexer.consumeNextChar()
+		if lexer.c == '\\' {
+			lexer.consumeEscape(&value)
+		} else {
+			value.WriteByte(lexer.c)
+		}
+	}
+
+	lexer.addTokenWithValue(Token.STRING_LITERAL, value.String())
+}
+
+// tryConsumeRawStringLiteral reads a `...` string, it can span lines and has no escape sequences
+func (lexer *Lexer) tryConsumeRawStringLiteral() {
+	var value strings.Builder
+	for !lexer.consumeOnMatch('`') {
+		if lexer.isEOF() {
+			lexer.reportError("Raw string literal doesn't have a closing backtick!")
+			return
 		}
 
 		lexer.consumeNextChar()
+		if lexer.c != '\r' {
+			value.WriteByte(lexer.c)
+		}
 	}
 
-	lexer.addToken(Token.STRING_LITERAL)
+	lexer.addTokenWithValue(Token.STRING_LITERAL, value.String())
 }
 
+func (lexer *Lexer) consumeHexDigits(limit int) string {
+	start := lexer.rightPos
+	for lexer.rightPos-start < limit && strings.IndexByte("0123456789abcdefABCDEF", lexer.peekNthChar(0)) >= 0 && !lexer.isEOF() {
+		lexer.consumeNextChar()
+	}
+
+	return string(lexer.source[start:lexer.rightPos])
+}
+
+// consumeEscape decodes the escape sequence following a backslash into value:
+// \n \t \r \0 \\ \" \' \xHH (a single byte) and \u{H...} (a code point, up to six hex digits)
+func (lexer *Lexer) consumeEscape(value *strings.Builder) {
+	start := lexer.rightPos - 1
+	line, column := lexer.line, start-lexer.lineStart+1
+	reportError := func(format string, args ...interface{}) {
+		lexer.reportErrorAt(line, column, lexer.rightPos-start, format, args...)
+	}
+
+	if lexer.isEOF() {
+		reportError("Escape sequence is missing its character")
+		return
+	}
+
+	lexer.consumeNextChar()
+	switch lexer.c {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case '0':
+		value.WriteByte(0)
+	case '\\', '"', '\'':
+		value.WriteByte(lexer.c)
+
+	case 'x':
+		digits := lexer.consumeHexDigits(2)
+		if len(digits) != 2 {
+			reportError("Escape sequence \\x expects two hex digits")
+			return
+		}
+
+		b, _ := strconv.ParseUint(digits, 16, 8)
+		value.WriteByte(byte(b))
+
+	case 'u':
+		open := lexer.consumeOnMatch('{')
+		digits := lexer.consumeHexDigits(6)
+		if !open || len(digits) == 0 || !lexer.consumeOnMatch('}') {
+			reportError("Escape sequence \\u expects up to six hex digits in braces, like \\u{1F600}")
+			return
+		}
+
+		r, _ := strconv.ParseUint(digits, 16, 32)
+		if !utf8.ValidRune(rune(r)) {
+			reportError("Escape sequence \\u{%s} isn't a valid code point", digits)
+			return
+		}
+
+		value.WriteRune(rune(r))
+
+	case '\n':
+		lexer.reportErrorAt(line, column, 1, "Backslash at the end of a line, use a raw string for multi-line text")
+
+	default:
+		reportError("Unknown escape sequence \\%c", lexer.c)
+	}
+}
+
+// tryConsumeCharacterLiteral reads a single code point like 'a', 'é' or '\n', the token's Value
+// is its UTF-8 encoding. A lone '\xHH' byte is the code point U+00HH.
 func (lexer *Lexer) tryConsumeCharacterLiteral() {
 	if lexer.consumeOnMatch('\'') {
-		lexer.reportError("Character literal doesn't have any ascii data in between")
+		lexer.reportError("Character literal doesn't have any data in between")
+		return
 	}
 
+	var value strings.Builder
 	for !lexer.consumeOnMatch('\'') {
 		if lexer.isEOF() {
 			lexer.reportError("character literal doesn't have a closing quote!")
+			return
 		}
 
 		lexer.consumeNextChar()
+		if lexer.c == '\\' {
+			lexer.consumeEscape(&value)
+		} else {
+			value.WriteByte(lexer.c)
+		}
+	}
+
+	decoded := value.String()
+	r, size := utf8.DecodeRuneInString(decoded)
+	if len(decoded) == 1 {
+		r = rune(decoded[0])
+	} else if size != len(decoded) || r == utf8.RuneError {
+		// keep the token so the parser doesn't report the same literal again
+		lexer.reportError("Character literal has to hold exactly one character, got %q", decoded)
+	}
+
+	lexer.addTokenWithValue(Token.CHARACTER_LITERAL, string(r))
+}
+
+var baseNames = map[int]string{2: "binary", 8: "octal", 10: "decimal", 16: "hex"}
+
+func isDigitOf(c byte, base int) bool {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c-'0') < base
+	case c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+		return base == 16
 	}
 
-	lexer.addToken(Token.CHARACTER_LITERAL)
+	return false
 }
 
+func isNumberChar(c byte) bool {
+	return c == '_' || c == '.' || unicode.IsDigit(rune(c)) || unicode.IsLetter(rune(c))
+}
+
+// tryConsumeDigitLiteral reads ints in decimal, hex (0xFF), binary (0b1010) and octal (0o17) and
+// decimal floats with an optional exponent (1.5e-3), '_' can separate digits and a sized kind can
+// follow as a suffix (255u8, 2f64). The token's Value is the number in plain decimal without the
+// suffix, a malformed literal is reported and reads as 0.
 func (lexer *Lexer) tryConsumeDigitLiteral() {
 	var kind Token.TokenType = Token.INTEGER_LITERAL
+	consumeDigits := func() {
+		for unicode.IsDigit(rune(lexer.peekNthChar(0))) || lexer.peekNthChar(0) == '_' {
+			lexer.consumeNextChar()
+		}
+	}
 
-	for unicode.IsDigit(rune(lexer.peekNthChar(0))) || lexer.peekNthChar(0) == '.' {
-		if lexer.c == '.' {
-			kind = Token.FLOAT_LITERAL
+	numberEnd := 0
+	base := 10
+	if lexer.c == '0' {
+		switch lexer.peekNthChar(0) {
+		case 'x', 'X':
+			base = 16
+		case 'b', 'B':
+			base = 2
+		case 'o', 'O':
+			base = 8
 		}
+	}
 
+	if base != 10 {
 		lexer.consumeNextChar()
+		for isNumberChar(lexer.peekNthChar(0)) {
+			lexer.consumeNextChar()
+		}
+	} else {
+		consumeDigits()
+		if lexer.peekNthChar(0) == '.' && unicode.IsDigit(rune(lexer.peekNthChar(1))) {
+			kind = Token.FLOAT_LITERAL
+			lexer.consumeNextChar()
+			consumeDigits()
+		}
+
+		if exponent := lexer.peekNthChar(0); exponent == 'e' || exponent == 'E' {
+			digits := 1
+			if sign := lexer.peekNthChar(1); sign == '+' || sign == '-' {
+				digits = 2
+			}
+
+			if unicode.IsDigit(rune(lexer.peekNthChar(digits))) {
+				kind = Token.FLOAT_LITERAL
+				for ; digits > 0; digits-- {
+					lexer.consumeNextChar()
+				}
+				consumeDigits()
+			}
+		}
+
+		numberEnd = len(lexer.getScratchBuffer())
+		if unicode.IsLetter(rune(lexer.peekNthChar(0))) {
+			for isNumberChar(lexer.peekNthChar(0)) {
+				lexer.consumeNextChar()
+			}
+		}
+
+		// anything else still attached to the number, like in 1.2.3, makes it malformed
+		if isNumberChar(lexer.peekNthChar(0)) {
+			for isNumberChar(lexer.peekNthChar(0)) {
+				lexer.consumeNextChar()
+			}
+
+			lexer.reportError("Malformed number literal %s", lexer.getScratchBuffer())
+			lexer.addTokenWithValue(kind, "0")
+			return
+		}
 	}
 
-	lexer.addToken(kind)
+	literal := lexer.getScratchBuffer()
+	digits, suffix := literal[:numberEnd], literal[numberEnd:]
+	if base != 10 {
+		// i and u aren't hex digits, so an integer suffix is where they start
+		digits, suffix = literal[2:], ""
+		if i := strings.IndexAny(digits, "iu"); i >= 0 {
+			digits, suffix = digits[:i], digits[i:]
+		}
+	}
+
+	if suffix != "" {
+		sized := TS.TypeKind(suffix)
+		if !TS.IsSized(sized) {
+			lexer.reportError("Unknown suffix %s on number literal %s, expected a sized type like u8 or f64", suffix, literal)
+			lexer.addTokenWithValue(kind, "0")
+			return
+		}
+
+		if kind == Token.FLOAT_LITERAL && !TS.IsFloat(sized) {
+			lexer.reportError("Float literal %s can't have the integer suffix %s", literal, suffix)
+			lexer.addTokenWithValue(kind, "0")
+			return
+		}
+
+		if TS.IsFloat(sized) {
+			kind = Token.FLOAT_LITERAL
+		}
+	}
+
+	value, err := numberValue(kind, base, literal, digits)
+	if err != nil {
+		lexer.reportError("%s", err)
+		value = "0"
+	}
+
+	lexer.addTokenWithValue(kind, value)
+}
+
+// numberValue checks the digits of a literal written in base and returns its value in decimal
+func numberValue(kind Token.TokenType, base int, literal string, digits string) (string, error) {
+	if digits == "" {
+		return "", fmt.Errorf("Number literal %s has no %s digits", literal, baseNames[base])
+	}
+
+	for i := 0; i < len(digits); i++ {
+		c := digits[i]
+		if c == '_' {
+			if i == 0 || i == len(digits)-1 || !isDigitOf(digits[i-1], base) || !isDigitOf(digits[i+1], base) {
+				return "", fmt.Errorf("Misplaced '_' in number literal %s, it can only separate digits", literal)
+			}
+		} else if kind == Token.INTEGER_LITERAL && !isDigitOf(c, base) {
+			return "", fmt.Errorf("Invalid digit %q in %s literal %s", c, baseNames[base], literal)
+		}
+	}
+
+	digits = strings.ReplaceAll(digits, "_", "")
+	if kind == Token.FLOAT_LITERAL {
+		if _, err := strconv.ParseFloat(digits, 64); err != nil {
+			return "", fmt.Errorf("Float literal %s is out of range for f64", literal)
+		}
+
+		return digits, nil
+	}
+
+	// the TypeChecker checks the range of the type the literal ends up with
+	value, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		return "", fmt.Errorf("Integer literal %s is out of range for u64", literal)
+	}
+
+	return strconv.FormatUint(value, 10), nil
 }
 
 func (lexer *Lexer) consumeLiteral() bool {
@@ -258,6 +575,9 @@ func (lexer *Lexer) consumeLiteral() bool {
 	} else if lexer.c == '"' {
 		lexer.tryConsumeStringLiteral()
 		return true
+	} else if lexer.c == '`' {
+		lexer.tryConsumeRawStringLiteral()
+		return true
 	} else if lexer.c == '\'' {
 		lexer.tryConsumeCharacterLiteral()
 		return true
@@ -266,21 +586,32 @@ func (lexer *Lexer) consumeLiteral() bool {
 	}
 }
 
-func GenerateTokenStream(filePath string) []Token.Token {
-	lexer := createLexer()
-
+// GenerateTokenStream always terminates the stream with an EOF token, even when
+// diagnostics were reported, so the parser can run on whatever was recognised.
+func GenerateTokenStream(filePath string) ([]Token.Token, []Diagnostic.Diagnostic) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		panic("File not found")
+		lexer := createLexer(filePath, nil)
+		lexer.reportError("Unable to read file: %s", err)
+		lexer.tokens = append(lexer.tokens, Token.CreateToken(Token.EOF, "", filePath, 1, 1))
+
+		return lexer.tokens, lexer.diagnostics
 	}
 
-	lexer.source = data
+	return GenerateTokenStreamFromSource(filePath, data)
+}
+
+func GenerateTokenStreamFromSource(filePath string, source []byte) ([]Token.Token, []Diagnostic.Diagnostic) {
+	lexer := createLexer(filePath, source)
 
 	for !lexer.isEOF() {
 		lexer.consumeNextToken()
 	}
 
-	lexer.tokens = append(lexer.tokens, Token.CreateToken(Token.EOF, "", lexer.line))
+	lexer.leftPos = lexer.rightPos
+	lexer.startLine = lexer.line
+	lexer.startColumn = lexer.rightPos - lexer.lineStart + 1
+	lexer.tokens = append(lexer.tokens, Token.CreateToken(Token.EOF, "", lexer.file, lexer.startLine, lexer.startColumn))
 
-	return lexer.tokens
+	return lexer.tokens, lexer.diagnostics
 }
@@ -51,8 +51,12 @@ func (parser *Parser) parseMembers() []AST.Member {
 }
 
 func (parser *Parser) parseVariableDeclaration() AST.Declaration {
-	parser.expect(Token.VAR)
+	tok := parser.expect(Token.VAR)
 	ident := parser.expect(Token.IDENTIFIER)
+	if parser.peekNthToken(0).Kind == Token.COMMA {
+		return parser.parseDestructureDeclaration(tok, ident)
+	}
+
 	parser.expect(Token.COLON)
 	var dataType *TS.Type
 	if parser.peekNthToken(0).Kind == Token.EQUALS {
@@ -62,7 +66,7 @@ func (parser *Parser) parseVariableDeclaration() AST.Declaration {
 		parser.expect(Token.EQUALS)
 	}
 
-	rhs := parser.parseExpression()
+	rhs := parser.expectExpression()
 	parser.expect(Token.SEMI_COLON)
 
 	return &AST.DeclarationVariable{
@@ -72,40 +76,167 @@ func (parser *Parser) parseVariableDeclaration() AST.Declaration {
 	}
 }
 
+// <destructure> ::= "var" <identifier> ("," <identifier>)+ ":" "=" <expression> ";"
+func (parser *Parser) parseDestructureDeclaration(tok Token.Token, first Token.Token) AST.Declaration {
+	variables := []*AST.DeclarationVariable{{Tok: first}}
+	for parser.consumeOnMatch(Token.COMMA) {
+		variables = append(variables, &AST.DeclarationVariable{Tok: parser.expect(Token.IDENTIFIER)})
+	}
+
+	parser.expect(Token.COLON)
+	parser.expect(Token.EQUALS)
+	rhs := parser.expectExpression()
+	parser.expect(Token.SEMI_COLON)
+
+	return &AST.DeclarationDestructure{
+		Tok:       tok,
+		Variables: variables,
+		RHS:       rhs,
+	}
+}
+
+// <return_type> ::= <type> | "(" <type> ("," <type>)* ")"
+func (parser *Parser) parseReturnType() *TS.Type {
+	if !parser.consumeOnMatch(Token.LEFT_PAREN) {
+		return parser.parseType()
+	}
+
+	var elements []*TS.Type
+	for {
+		elements = append(elements, parser.parseType())
+		if !parser.consumeOnMatch(Token.COMMA) {
+			break
+		}
+	}
+	parser.expect(Token.RIGHT_PAREN)
+
+	if len(elements) == 1 {
+		return elements[0]
+	}
+
+	return TS.NewTupleType(elements)
+}
+
+// <function> ::= "fn" ("(" <identifier> ":" <type> ")")? <identifier> <type_parameters>? <parameters> "->" <return_type> <block>
 func (parser *Parser) parseFunctionDeclaration() AST.Declaration {
 	parser.expect(Token.FN)
+
+	// a method's receiver is its first parameter
+	var receiver []TS.Parameter
+	if parser.consumeOnMatch(Token.LEFT_PAREN) {
+		param := parser.expect(Token.IDENTIFIER)
+		parser.expect(Token.COLON)
+		receiver = append(receiver, TS.Parameter{
+			Tok:      param,
+			DeclType: parser.parseType(),
+		})
+		parser.expect(Token.RIGHT_PAREN)
+	}
+
 	ident := parser.expect(Token.IDENTIFIER)
-	params := parser.parseParameters()
+	if len(receiver) > 0 && parser.peekNthToken(0).Kind == Token.LEFT_BRACKET {
+		parser.reportError("Methods can't have type parameters")
+	}
+
+	typeParams := parser.parseTypeParameters()
+	defer parser.enterTypeParameters(typeParams)()
+
+	params := append(receiver, parser.parseParameters()...)
 	parser.expect(Token.RIGHT_ARROW)
-	returnType := parser.parseType()
+	returnType := parser.parseReturnType()
 	block := parser.parseStatementBlock().(*AST.StatementBlock)
 
 	declType := TS.NewType(TS.FUNCTION, returnType, params)
 
-	return &AST.DeclarationFunction{
-		Tok:      ident,
-		DeclType: declType,
-		Block:    block,
+	decl := &AST.DeclarationFunction{
+		Tok:            ident,
+		DeclType:       declType,
+		Block:          block,
+		TypeParameters: typeParams,
+	}
+
+	if len(receiver) > 0 {
+		decl.Receiver = receiver[0].DeclType
 	}
+
+	return decl
 }
 
 func (parser *Parser) parseStructDeclaration() AST.Declaration {
 	parser.expect(Token.STRUCT)
 	typeName := parser.expect(Token.IDENTIFIER)
-	members := parser.parseMembers()
 
-	memberLookup := make(map[string]AST.Member)
-	for _, member := range members {
-		memberLookup[member.Tok.Lexeme] = member
+	// Declared before its members so they can point to it, e.g. next: *Node
+	decl := &AST.DeclarationStruct{
+		Tok:            typeName,
+		MemberLookup:   make(map[string]AST.Member),
+		TypeParameters: parser.parseTypeParameters(),
+	}
+	parser.ctx.ParsedStructDeclaration[typeName.Lexeme] = decl
+
+	defer parser.enterTypeParameters(decl.TypeParameters)()
+	decl.Members = parser.parseMembers()
+	for _, member := range decl.Members {
+		decl.MemberLookup[member.Tok.Lexeme] = member
+	}
+
+	return decl
+}
+
+// <enum> ::= "enum" <identifier> "{" (<identifier> ",")* "}"
+func (parser *Parser) parseEnumDeclaration() AST.Declaration {
+	parser.expect(Token.ENUM)
+	typeName := parser.expect(Token.IDENTIFIER)
+
+	decl := &AST.DeclarationEnum{
+		Tok: typeName,
 	}
 
-	parser.ctx.ParsedStructDeclaration[typeName.Lexeme] = &AST.DeclarationStruct{
-		Tok:          typeName,
-		Members:      members,
-		MemberLookup: memberLookup,
+	parser.expect(Token.LEFT_CURLY)
+	for !parser.consumeOnMatch(Token.RIGHT_CURLY) {
+		decl.Variants = append(decl.Variants, parser.expect(Token.IDENTIFIER))
+
+		if parser.peekNthToken(0).Kind != Token.RIGHT_CURLY {
+			parser.expect(Token.COMMA)
+		}
+	}
+	parser.ctx.ParsedEnumDeclaration[typeName.Lexeme] = decl
+
+	return decl
+}
+
+func enumType(decl *AST.DeclarationEnum) *TS.Type {
+	variants := make([]string, len(decl.Variants))
+	for i, variant := range decl.Variants {
+		variants[i] = variant.Lexeme
+	}
+
+	return TS.NewEnumType(decl.Tok.Lexeme, variants)
+}
+
+// <union> ::= "union" <identifier> "{" (<identifier> ":" <type> ",")* "}"
+func (parser *Parser) parseUnionDeclaration() AST.Declaration {
+	parser.expect(Token.UNION)
+	typeName := parser.expect(Token.IDENTIFIER)
+
+	// Declared before its variants so they can point to it, e.g. Node: *Tree
+	decl := &AST.DeclarationUnion{
+		Tok: typeName,
+	}
+	parser.ctx.ParsedUnionDeclaration[typeName.Lexeme] = decl
+
+	decl.Variants = parser.parseMembers()
+
+	return decl
+}
+
+func unionType(decl *AST.DeclarationUnion) *TS.Type {
+	variants := make([]string, len(decl.Variants))
+	for i, variant := range decl.Variants {
+		variants[i] = variant.Tok.Lexeme
 	}
 
-	return parser.ctx.ParsedStructDeclaration[typeName.Lexeme]
+	return TS.NewUnionType(decl.Tok.Lexeme, variants)
 }
 
 func (parser *Parser) parseDeclaration() AST.Declaration {
@@ -117,6 +248,10 @@ func (parser *Parser) parseDeclaration() AST.Declaration {
 		return parser.parseFunctionDeclaration()
 	} else if current.Kind == Token.STRUCT {
 		return parser.parseStructDeclaration()
+	} else if current.Kind == Token.ENUM {
+		return parser.parseEnumDeclaration()
+	} else if current.Kind == Token.UNION {
+		return parser.parseUnionDeclaration()
 	}
 
 	return nil
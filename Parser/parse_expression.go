@@ -6,15 +6,29 @@ import (
 	"ion-go/TS"
 	"ion-go/Token"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
+// literalSuffix is the sized kind a number literal ends with, like u8 in 255u8. Integer suffixes
+// start with i or u, which aren't hex digits, and float suffixes only follow decimal literals.
+func literalSuffix(lexeme string, float bool) TS.TypeKind {
+	for _, kind := range TS.SizedKinds {
+		if TS.IsFloat(kind) == float && strings.HasSuffix(lexeme, string(kind)) {
+			return kind
+		}
+	}
+
+	return ""
+}
+
 // call site
 func (parser *Parser) parseArguments() []AST.Expression {
 	var ret []AST.Expression
 
 	parser.expect(Token.LEFT_PAREN)
 	for !parser.consumeOnMatch(Token.RIGHT_PAREN) {
-		expression := parser.parseExpression()
+		expression := parser.expectExpression()
 
 		ret = append(ret, expression)
 
@@ -26,7 +40,73 @@ func (parser *Parser) parseArguments() []AST.Expression {
 	return ret
 }
 
-func (parser *Parser) parseAccessChainExpression(token Token.Token) *AST.ExpressionAccessChain {
+// parseCallSuffix parses calls of the function value callee evaluates to, e.g. ops[0](1, 2) or make()(1),
+// and the members, elements and methods of a call's result, e.g. c.self().bump() or unwrap(x).v
+func (parser *Parser) parseCallSuffix(callee AST.Expression) AST.Expression {
+	for {
+		next := parser.peekNthToken(0)
+		if next.Kind == Token.LEFT_PAREN {
+			callee = &AST.SE_FunctionCall{
+				Tok:       next,
+				Callee:    callee,
+				Arguments: parser.parseArguments(),
+			}
+			continue
+		}
+
+		call, ok := callee.(AST.StatementExpression)
+		if !ok || (next.Kind != Token.DOT && next.Kind != Token.LEFT_BRACKET) {
+			return callee
+		}
+
+		callee = parser.parseAccessChain(callTok(call), call)
+	}
+}
+
+// callTok is the token a call is reported at, the function or method name for a call by name
+func callTok(call AST.StatementExpression) Token.Token {
+	switch v := call.(type) {
+	case *AST.SE_FunctionCall:
+		return v.Tok
+	case *AST.SE_MethodCall:
+		return v.Tok
+	}
+
+	panic(fmt.Sprintf("unreachable call: %T", call))
+}
+
+// <function_literal> ::= "fn" <parameters> "->" <return_type> <block>
+func (parser *Parser) parseFunctionExpression() AST.Expression {
+	tok := parser.expect(Token.FN)
+	params := parser.parseParameters()
+	parser.expect(Token.RIGHT_ARROW)
+	returnType := parser.parseReturnType()
+
+	// the body doesn't continue the array literal or for loop the function is written in
+	ctx := parser.ctx
+	parser.ctx.ParsingArrayLiteral = 0
+	parser.ctx.ParsingForIncrement = false
+	block := parser.parseStatementBlock().(*AST.StatementBlock)
+	parser.ctx.ParsingArrayLiteral = ctx.ParsingArrayLiteral
+	parser.ctx.ParsingForIncrement = ctx.ParsingForIncrement
+
+	return &AST.ExpressionFunction{
+		Tok: tok,
+		Decl: &AST.DeclarationFunction{
+			Tok:      tok,
+			DeclType: TS.NewType(TS.FUNCTION, returnType, params),
+			Block:    block,
+		},
+	}
+}
+
+// parseAccessChainExpression also parses method calls, a call ends the chain: <receiver>.<identifier>(<arguments>)
+func (parser *Parser) parseAccessChainExpression(token Token.Token) AST.Expression {
+	return parser.parseAccessChain(token, nil)
+}
+
+// parseAccessChain parses the keys that follow the variable token, or the value of base when it's set
+func (parser *Parser) parseAccessChain(token Token.Token, base AST.Expression) AST.Expression {
 	var keys []AST.Expression
 
 	inital_token := token
@@ -37,15 +117,31 @@ func (parser *Parser) parseAccessChainExpression(token Token.Token) *AST.Express
 
 		if parser.consumeOnMatch(Token.DOT) {
 			token = parser.expect(Token.IDENTIFIER)
+			if parser.peekNthToken(0).Kind == Token.LEFT_PAREN {
+				var receiver AST.Expression = &AST.ExpressionIdentifier{Tok: inital_token}
+				if len(keys) > 0 {
+					receiver = &AST.ExpressionAccessChain{Tok: inital_token, Base: base, AccessKeys: keys}
+				} else if base != nil {
+					receiver = base
+				}
+
+				return &AST.SE_MethodCall{
+					Tok:       token,
+					Receiver:  receiver,
+					Arguments: parser.parseArguments(),
+				}
+			}
+
 			keys = append(keys, &AST.ExpressionIdentifier{
 				Tok: token,
 			})
 		}
 
+		bracket := parser.peekNthToken(0)
 		if parser.consumeOnMatch(Token.LEFT_BRACKET) {
 			keys = append(keys, &AST.ExpressionArrayAccess{
-				Tok:   token,
-				Index: parser.parseExpression(),
+				Tok:   bracket,
+				Index: parser.expectExpression(),
 			})
 			parser.expect(Token.RIGHT_BRACKET)
 		}
@@ -53,69 +149,114 @@ func (parser *Parser) parseAccessChainExpression(token Token.Token) *AST.Express
 
 	return &AST.ExpressionAccessChain{
 		Tok:        inital_token,
+		Base:       base,
 		AccessKeys: keys,
 	}
 }
 
-// <Primary>    ::= <integer> | <float> | <boolean> | <string> | '(' <Expression> ')'
+// <Primary>    ::= <integer> | <float> | <boolean> | <string> | 'nullptr' | <array> | <struct> | <union> | <enum_value> | '(' <Expression> (',' <Expression>)* ')'
 func (parser *Parser) parsePrimary() AST.Expression {
 	current := parser.peekNthToken(0)
+	if current.Kind == Token.LEFT_BRACKET {
+		return parser.parseArrayExpression()
+	} else if _, ok := parser.ctx.ParsedUnionDeclaration[current.Lexeme]; current.Kind == Token.IDENTIFIER && ok && parser.peekNthToken(1).Kind == Token.DOT {
+		return parser.parseUnionExpression()
+	} else if current.Kind == Token.IDENTIFIER && parser.peekNthToken(1).Kind == Token.DOT && parser.peekNthToken(2).Kind == Token.LEFT_CURLY {
+		return parser.parseStructExpression()
+	} else if structDecl, ok := parser.ctx.ParsedStructDeclaration[current.Lexeme]; current.Kind == Token.IDENTIFIER && ok && len(structDecl.TypeParameters) > 0 && parser.peekNthToken(1).Kind == Token.LEFT_BRACKET {
+		return parser.parseStructExpression()
+	} else if _, ok := parser.ctx.ParsedEnumDeclaration[current.Lexeme]; current.Kind == Token.IDENTIFIER && ok && parser.peekNthToken(1).Kind == Token.DOT {
+		return parser.parseEnumValueExpression()
+	}
+
 	if parser.consumeOnMatch(Token.INTEGER_LITERAL) {
-		num, _ := strconv.Atoi(current.Lexeme)
-		return &AST.ExpressionInteger{Value: num}
+		num, _ := strconv.ParseUint(current.Value, 10, 64)
+		return &AST.ExpressionInteger{Tok: current, Value: num, Kind: literalSuffix(current.Lexeme, false)}
 	} else if parser.consumeOnMatch(Token.BOOLEAN_LITERAL) {
 		b := current.Lexeme == "true"
 		return &AST.ExpressionBoolean{Value: b}
 	} else if parser.consumeOnMatch(Token.FLOAT_LITERAL) {
-		num, _ := strconv.ParseFloat(current.Lexeme, 32)
-		return &AST.ExpressionFloat{Value: float32(num)}
+		num, _ := strconv.ParseFloat(current.Value, 64)
+		return &AST.ExpressionFloat{Tok: current, Value: num, Kind: literalSuffix(current.Lexeme, true)}
 	} else if parser.consumeOnMatch(Token.STRING_LITERAL) {
-		return &AST.ExpressionString{Value: current.Lexeme[1 : len(current.Lexeme)-1]}
+		return &AST.ExpressionString{Value: current.Value}
+	} else if parser.consumeOnMatch(Token.CHARACTER_LITERAL) {
+		r, _ := utf8.DecodeRuneInString(current.Value)
+		return &AST.ExpressionChar{Value: r}
+	} else if current.Kind == Token.FN {
+		return parser.parseCallSuffix(parser.parseFunctionExpression())
+	} else if parser.consumeOnMatch(Token.NULLPTR) {
+		return &AST.ExpressionNullptr{Tok: current}
 	} else if parser.consumeOnMatch(Token.BUILTIN_LEN) {
 		parser.expect(Token.LEFT_PAREN)
-		iterable := parser.parseExpression()
+		iterable := parser.expectExpression()
 		parser.expect(Token.RIGHT_PAREN)
 
 		return &AST.ExpressionLen{
+			Tok:      current,
 			Iterable: iterable,
 		}
 	} else if parser.consumeOnMatch(Token.IDENTIFIER) {
 		next := parser.peekNthToken(0)
 		if next.Kind == Token.DOT || next.Kind == Token.LEFT_BRACKET {
-			return parser.parseAccessChainExpression(current)
+			return parser.parseCallSuffix(parser.parseAccessChainExpression(current))
 		}
 
 		if next.Kind == Token.LEFT_PAREN {
 			arguments := parser.parseArguments()
-			return &AST.SE_FunctionCall{
+			return parser.parseCallSuffix(&AST.SE_FunctionCall{
 				Tok:       current,
 				Arguments: arguments,
-			}
+			})
 		}
 
 		return &AST.ExpressionIdentifier{
 			Tok: current,
 		}
 	} else if parser.consumeOnMatch(Token.LEFT_PAREN) {
-		expr := parser.parseExpression()
-		if expr != nil {
+		expr := parser.expectExpression()
+		if parser.peekNthToken(0).Kind == Token.COMMA {
+			elements := []AST.Expression{expr}
+			for parser.consumeOnMatch(Token.COMMA) {
+				elements = append(elements, parser.expectExpression())
+			}
 			parser.expect(Token.RIGHT_PAREN)
-			return &AST.ExpressionGrouping{
-				Expr: expr,
+
+			return &AST.ExpressionTuple{
+				Tok:      current,
+				Elements: elements,
 			}
 		}
+
+		parser.expect(Token.RIGHT_PAREN)
+		return parser.parseCallSuffix(&AST.ExpressionGrouping{
+			Expr: expr,
+		})
 	}
 
 	return nil
 }
 
-// <Unary>      ::= ('+'|'-'|'!') <unary> | <Primary>
+// <Unary>      ::= ('+'|'-'|'!'|'~'|'&'|'*') <unary> | <Primary>
 func (parser *Parser) parseUnaryExpression() AST.Expression {
 	ret := &AST.ExpressionUnary{}
 
-	if parser.consumeOnMatch(Token.NOT) || parser.consumeOnMatch(Token.MINUS) || parser.consumeOnMatch(Token.PLUS) {
+	tok := parser.peekNthToken(0)
+	if parser.consumeOnMatch(Token.AMPERSAND) {
+		return &AST.ExpressionAddressOf{
+			Tok:     tok,
+			Operand: parser.expectOperand(parser.parseUnaryExpression),
+		}
+	} else if parser.consumeOnMatch(Token.STAR) {
+		return &AST.ExpressionDereference{
+			Tok:     tok,
+			Operand: parser.expectOperand(parser.parseUnaryExpression),
+		}
+	}
+
+	if parser.consumeOnMatch(Token.NOT) || parser.consumeOnMatch(Token.MINUS) || parser.consumeOnMatch(Token.PLUS) || parser.consumeOnMatch(Token.TILDE) {
 		ret.Operator = parser.previousToken()
-		ret.Operand = parser.parseUnaryExpression()
+		ret.Operand = parser.expectOperand(parser.parseUnaryExpression)
 
 		return ret
 	}
@@ -123,13 +264,18 @@ func (parser *Parser) parseUnaryExpression() AST.Expression {
 	return parser.parsePrimary()
 }
 
-// <multiplicative>     ::= <Unary> (('*'|'/') <Unary>)*
+// <multiplicative>     ::= <Unary> (('*'|'/'|'%'|'&'|'<<'|'>>') <Unary>)*
 func (parser *Parser) parseMultiplicativeExpression() AST.Expression {
 	expr := parser.parseUnaryExpression()
 
-	for parser.consumeOnMatch(Token.STAR) || parser.consumeOnMatch(Token.DIVISION) {
+	for parser.consumeOnMatch(Token.STAR) ||
+		parser.consumeOnMatch(Token.DIVISION) ||
+		parser.consumeOnMatch(Token.MODULUS) ||
+		parser.consumeOnMatch(Token.AMPERSAND) ||
+		parser.consumeOnMatch(Token.LEFT_SHIFT) ||
+		parser.consumeOnMatch(Token.RIGHT_SHIFT) {
 		op := parser.previousToken()
-		right := parser.parseUnaryExpression()
+		right := parser.expectOperand(parser.parseUnaryExpression)
 		expr = &AST.ExpressionBinary{
 			Operator: op,
 			Left:     expr,
@@ -140,13 +286,16 @@ func (parser *Parser) parseMultiplicativeExpression() AST.Expression {
 	return expr
 }
 
-// <additive>       ::= <Factor> (('+'|'-') <Factor>)*
+// <additive>       ::= <Factor> (('+'|'-'|'|'|'^') <Factor>)*
 func (parser *Parser) parseAdditiveExpression() AST.Expression {
 	expr := parser.parseMultiplicativeExpression()
 
-	for parser.consumeOnMatch(Token.PLUS) || parser.consumeOnMatch(Token.MINUS) {
+	for parser.consumeOnMatch(Token.PLUS) ||
+		parser.consumeOnMatch(Token.MINUS) ||
+		parser.consumeOnMatch(Token.PIPE) ||
+		parser.consumeOnMatch(Token.CARET) {
 		op := parser.previousToken()
-		right := parser.parseMultiplicativeExpression()
+		right := parser.expectOperand(parser.parseMultiplicativeExpression)
 		expr = &AST.ExpressionBinary{
 			Operator: op,
 			Left:     expr,
@@ -168,7 +317,7 @@ func (parser *Parser) parseComparisonExpression() AST.Expression {
 		parser.consumeOnMatch(Token.GREATER_THAN_EQUALS) ||
 		parser.consumeOnMatch(Token.GREATER_THAN) {
 		op := parser.previousToken()
-		right := parser.parseAdditiveExpression()
+		right := parser.expectOperand(parser.parseAdditiveExpression)
 		expr = &AST.ExpressionBinary{
 			Operator: op,
 			Left:     expr,
@@ -185,7 +334,7 @@ func (parser *Parser) parseLogicalExpression() AST.Expression {
 
 	for parser.consumeOnMatch(Token.LOGICAL_AND) || parser.consumeOnMatch(Token.LOGICAL_OR) {
 		op := parser.previousToken()
-		right := parser.parseComparisonExpression()
+		right := parser.expectOperand(parser.parseComparisonExpression)
 		expr = &AST.ExpressionBinary{
 			Operator: op,
 			Left:     expr,
@@ -200,6 +349,7 @@ func (parser *Parser) parseLogicalExpression() AST.Expression {
 func (parser *Parser) parseArrayExpression() AST.Expression {
 	var elements []AST.Expression
 
+	tok := parser.peekNthToken(0)
 	declType := TS.NewType(TS.ARRAY, nil, nil)
 	if parser.ctx.ParsingArrayLiteral == 0 {
 		declType = parser.parseType()
@@ -209,7 +359,7 @@ func (parser *Parser) parseArrayExpression() AST.Expression {
 	parser.ctx.ParsingArrayLiteral += 1
 	parser.expect(Token.LEFT_BRACKET)
 	for !parser.consumeOnMatch(Token.RIGHT_BRACKET) {
-		expr := parser.parseExpression()
+		expr := parser.expectExpression()
 		elements = append(elements, expr)
 
 		if parser.peekNthToken(0).Kind != Token.RIGHT_BRACKET {
@@ -219,6 +369,7 @@ func (parser *Parser) parseArrayExpression() AST.Expression {
 	parser.ctx.ParsingArrayLiteral -= 1
 
 	return &AST.ExpressionArray{
+		Tok:      tok,
 		Elements: elements,
 		DeclType: declType,
 	}
@@ -231,7 +382,12 @@ func (parser *Parser) parseStructExpression() AST.Expression {
 	typeName := parser.expect(Token.IDENTIFIER)
 	structDecl, ok := parser.ctx.ParsedStructDeclaration[typeName.Lexeme]
 	if !ok {
-		panic(fmt.Sprintf("Line %d | Type %s is not defined", typeName.Line, typeName.Lexeme))
+		parser.reportErrorAt(typeName, "Type %s is not defined", typeName.Lexeme)
+	}
+
+	var typeArguments []*TS.Type
+	if parser.peekNthToken(0).Kind == Token.LEFT_BRACKET {
+		typeArguments = parser.parseTypeArguments()
 	}
 
 	parser.expect(Token.DOT)
@@ -240,7 +396,11 @@ func (parser *Parser) parseStructExpression() AST.Expression {
 	memberCount := 0
 
 	for !parser.consumeOnMatch(Token.RIGHT_CURLY) {
-		expr := parser.parseExpression()
+		if memberCount >= len(structDecl.Members) {
+			parser.reportError("Too many members for %s, expected %d", typeName.Lexeme, len(structDecl.Members))
+		}
+
+		expr := parser.expectExpression()
 		member := structDecl.Members[memberCount]
 		values[member.Tok.Lexeme] = expr
 
@@ -252,26 +412,87 @@ func (parser *Parser) parseStructExpression() AST.Expression {
 	}
 
 	if memberCount != len(structDecl.Members) {
-		panic(fmt.Sprintf("Line: %d | Expected members count to be: %d | Got: %d", typeName.Line, len(structDecl.Members), memberCount))
+		parser.reportErrorAt(typeName, "Expected members count to be: %d | Got: %d", len(structDecl.Members), memberCount)
 	}
 
 	return &AST.ExpressionStruct{
-		Tok:          typeName,
-		MemberValues: values,
+		Tok:           typeName,
+		MemberValues:  values,
+		TypeArguments: typeArguments,
+	}
+}
+
+// <enum_value> ::= <type>.<identifier>
+func (parser *Parser) parseEnumValueExpression() AST.Expression {
+	typeName := parser.expect(Token.IDENTIFIER)
+	enumDecl := parser.ctx.ParsedEnumDeclaration[typeName.Lexeme]
+
+	parser.expect(Token.DOT)
+	variant := parser.expect(Token.IDENTIFIER)
+
+	for i, declared := range enumDecl.Variants {
+		if declared.Lexeme == variant.Lexeme {
+			return &AST.ExpressionEnumValue{
+				Tok:      typeName,
+				Variant:  variant,
+				DeclType: enumType(enumDecl),
+				Index:    i,
+			}
+		}
+	}
+
+	parser.reportErrorAt(variant, "%s has no variant named %s", typeName.Lexeme, variant.Lexeme)
+	return nil
+}
+
+// <union> ::= <type>.{<identifier>: <expression>}
+func (parser *Parser) parseUnionExpression() AST.Expression {
+	typeName := parser.expect(Token.IDENTIFIER)
+	unionDecl := parser.ctx.ParsedUnionDeclaration[typeName.Lexeme]
+
+	parser.expect(Token.DOT)
+	parser.expect(Token.LEFT_CURLY)
+	variant := parser.expect(Token.IDENTIFIER)
+	parser.expect(Token.COLON)
+	payload := parser.expectExpression()
+	parser.expect(Token.RIGHT_CURLY)
+
+	for i, declared := range unionDecl.Variants {
+		if declared.Tok.Lexeme == variant.Lexeme {
+			return &AST.ExpressionUnion{
+				Tok:      typeName,
+				Variant:  variant,
+				Index:    i,
+				Payload:  payload,
+				DeclType: unionType(unionDecl),
+			}
+		}
 	}
+
+	parser.reportErrorAt(variant, "%s has no variant named %s", typeName.Lexeme, variant.Lexeme)
+	return nil
+}
+
+// expectExpression is parseExpression for the places where an expression is mandatory
+func (parser *Parser) expectExpression() AST.Expression {
+	return parser.expectOperand(parser.parseExpression)
+}
+
+func (parser *Parser) expectOperand(parse func() AST.Expression) AST.Expression {
+	tok := parser.peekNthToken(0)
+	expr := parse()
+	if expr == nil {
+		parser.reportErrorAt(tok, "Expected an expression, got %s", describeToken(tok))
+	}
+
+	return expr
 }
 
 // <Expression> ::= <additive>
 func (parser *Parser) parseExpression() AST.Expression {
 	current := parser.peekNthToken(0)
-	next := parser.peekNthToken(1)
-	next2 := parser.peekNthToken(2)
 
-	if current.Kind == Token.LEFT_BRACKET {
-		return parser.parseArrayExpression()
-	} else if current.Kind == Token.IDENTIFIER && next.Kind == Token.DOT && next2.Kind == Token.LEFT_CURLY {
-		return parser.parseStructExpression()
-	} else if current.Kind == Token.CAST {
+	if current.Kind == Token.CAST {
 		cast := parser.expect(Token.CAST)
 		parser.expect(Token.LEFT_PAREN)
 		castType := parser.parseType()
@@ -280,7 +501,7 @@ func (parser *Parser) parseExpression() AST.Expression {
 		return &AST.ExpressionTypeCast{
 			Tok:      cast,
 			CastType: castType,
-			Expr:     parser.parseExpression(),
+			Expr:     parser.expectExpression(),
 		}
 	} else {
 		return parser.parseLogicalExpression()
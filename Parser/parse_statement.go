@@ -1,24 +1,34 @@
 package Parser
 
 import (
-	"fmt"
 	"ion-go/AST"
+	"ion-go/Diagnostic"
 	"ion-go/Token"
 )
 
+func (parser *Parser) parseBlockNode() (node AST.Node) {
+	defer parser.recoverNode(parser.current, parser.ctx, func(tok Token.Token) {
+		node = &AST.StatementError{Tok: tok}
+	})
+
+	if decl := parser.parseDeclaration(); decl != nil {
+		return decl
+	}
+
+	return parser.parseStatement()
+}
+
 func (parser *Parser) parseStatementBlock() AST.Statement {
 	var body []AST.Node
-	parser.expect(Token.LEFT_CURLY)
+	open := parser.expect(Token.LEFT_CURLY)
 	for !parser.consumeOnMatch(Token.RIGHT_CURLY) {
-		if decl := parser.parseDeclaration(); decl != nil {
-			body = append(body, decl)
-			continue
+		if parser.peekNthToken(0).Kind == Token.EOF {
+			parser.addDiagnostic(Diagnostic.Error(parser.peekNthToken(0), "Expected: RIGHT_CURLY before end of file").
+				WithNote("block opened at line %d", open.Line))
+			break
 		}
 
-		if stmt := parser.parseStatement(); stmt != nil {
-			body = append(body, stmt)
-			continue
-		}
+		body = append(body, parser.parseBlockNode())
 	}
 
 	return &AST.StatementBlock{
@@ -28,24 +38,57 @@ func (parser *Parser) parseStatementBlock() AST.Statement {
 
 func (parser *Parser) parseAssignmentStatement() AST.Statement {
 	tok := parser.peekNthToken(0)
-	lhs := parser.parseExpression()
-	parser.expect(Token.EQUALS)
-	rhs := parser.parseExpression()
-	if !parser.ctx.ParsingForIncrement {
+	lhs := parser.expectExpression()
+	if call, ok := lhs.(AST.StatementExpression); ok && !parser.ctx.ParsingForIncrement {
 		parser.expect(Token.SEMI_COLON)
+		return call
 	}
 
-	return &AST.StatementAssignment{
+	assignment := &AST.StatementAssignment{
 		Tok: tok,
 		LHS: lhs,
-		RHS: rhs,
 	}
+
+	if next := parser.peekNthToken(0); next.Kind == Token.PLUS_PLUS || next.Kind == Token.MINUS_MINUS {
+		// x++ is x += 1
+		operator := parser.consumeNextToken()
+		operator.Kind = Token.PLUS
+		if next.Kind == Token.MINUS_MINUS {
+			operator.Kind = Token.MINUS
+		}
+		operator.Lexeme = operator.Lexeme[:1]
+
+		assignment.Operator = &operator
+		assignment.RHS = &AST.ExpressionInteger{Value: 1}
+		assignment.Increment = true
+	} else {
+		operator, compound := Token.GetCompoundOperator(next)
+		if compound {
+			parser.consumeNextToken()
+			assignment.Operator = &operator
+		} else {
+			parser.expect(Token.EQUALS)
+		}
+
+		assignment.RHS = parser.expectExpression()
+	}
+
+	if !parser.ctx.ParsingForIncrement {
+		parser.expect(Token.SEMI_COLON)
+	}
+
+	return assignment
 }
+
 func (parser *Parser) parseForStatement() AST.Statement {
-	parser.expect(Token.FOR)
+	tok := parser.expect(Token.FOR)
 	parser.expect(Token.LEFT_PAREN)
-	initializer := parser.parseVariableDeclaration()
-	condition := parser.parseExpression()
+	initializer, ok := parser.parseVariableDeclaration().(*AST.DeclarationVariable)
+	if !ok {
+		parser.reportErrorAt(tok, "For loop initializer can only declare a single variable")
+	}
+
+	condition := parser.expectExpression()
 	parser.expect(Token.SEMI_COLON)
 	parser.ctx.ParsingForIncrement = true
 	increment := parser.parseAssignmentStatement()
@@ -54,70 +97,178 @@ func (parser *Parser) parseForStatement() AST.Statement {
 	block := parser.parseStatementBlock()
 
 	return &AST.StatementFor{
-		Initializer: initializer.(*AST.DeclarationVariable),
+		Tok:         tok,
+		Initializer: initializer,
 		Condition:   condition,
 		Increment:   increment.(*AST.StatementAssignment),
 		Block:       block.(*AST.StatementBlock),
 	}
 }
 func (parser *Parser) parseWhileStatement() AST.Statement {
-	parser.expect(Token.WHILE)
+	tok := parser.expect(Token.WHILE)
 	parser.expect(Token.LEFT_PAREN)
-	condition := parser.parseExpression()
+	condition := parser.expectExpression()
 	parser.expect(Token.RIGHT_PAREN)
 	block := parser.parseStatementBlock()
 
 	return &AST.StatementWhile{
+		Tok:       tok,
 		Condition: condition,
 		Block:     block.(*AST.StatementBlock),
 	}
 }
 
 func (parser *Parser) parseIfElseStatement() AST.Statement {
-	parser.expect(Token.IF)
+	tok := parser.expect(Token.IF)
 	parser.expect(Token.LEFT_PAREN)
-	condition := parser.parseExpression()
+	condition := parser.expectExpression()
 	parser.expect(Token.RIGHT_PAREN)
 	ifBlock := parser.parseStatementBlock()
 
 	var elseBlock *AST.StatementBlock = nil
 	if parser.consumeOnMatch(Token.ELSE) {
-		elseBlock = parser.parseStatementBlock().(*AST.StatementBlock)
+		if parser.peekNthToken(0).Kind == Token.IF {
+			// else if (...) {...} is else { if (...) {...} }
+			elseBlock = &AST.StatementBlock{Body: []AST.Node{parser.parseIfElseStatement()}}
+		} else {
+			elseBlock = parser.parseStatementBlock().(*AST.StatementBlock)
+		}
 	}
 
 	return &AST.StatementIfElse{
+		Tok:       tok,
 		Condition: condition,
 		IfBlock:   ifBlock.(*AST.StatementBlock),
 		ElseBlock: elseBlock,
 	}
 }
 
+// parseCaseBody reads the statements of a case up to the next case, default or the end of the switch
+func (parser *Parser) parseCaseBody() *AST.StatementBlock {
+	var body []AST.Node
+	for {
+		switch parser.peekNthToken(0).Kind {
+		case Token.CASE, Token.DEFAULT, Token.RIGHT_CURLY, Token.EOF:
+			return &AST.StatementBlock{Body: body}
+		}
+
+		body = append(body, parser.parseBlockNode())
+	}
+}
+
+func (parser *Parser) parseSwitchStatement() AST.Statement {
+	tok := parser.expect(Token.SWITCH)
+	parser.expect(Token.LEFT_PAREN)
+	value := parser.expectExpression()
+	parser.expect(Token.RIGHT_PAREN)
+	open := parser.expect(Token.LEFT_CURLY)
+
+	switchStatement := &AST.StatementSwitch{
+		Tok:   tok,
+		Value: value,
+	}
+
+	for !parser.consumeOnMatch(Token.RIGHT_CURLY) {
+		current := parser.peekNthToken(0)
+		if current.Kind == Token.EOF {
+			parser.addDiagnostic(Diagnostic.Error(current, "Expected: RIGHT_CURLY before end of file").
+				WithNote("switch opened at line %d", open.Line))
+			break
+		}
+
+		if parser.consumeOnMatch(Token.CASE) {
+			switchCase := &AST.SwitchCase{Tok: current}
+			switchCase.Values = append(switchCase.Values, parser.expectExpression())
+			for parser.consumeOnMatch(Token.COMMA) {
+				switchCase.Values = append(switchCase.Values, parser.expectExpression())
+			}
+
+			parser.expect(Token.COLON)
+			switchCase.Body = parser.parseCaseBody()
+			switchStatement.Cases = append(switchStatement.Cases, switchCase)
+		} else if parser.consumeOnMatch(Token.DEFAULT) {
+			if switchStatement.Default != nil {
+				parser.addDiagnostic(Diagnostic.Error(current, "Switch already has a default case"))
+			}
+
+			parser.expect(Token.COLON)
+			switchStatement.Default = parser.parseCaseBody()
+		} else {
+			parser.reportError("Expected case or default, got %s", describeToken(current))
+		}
+	}
+
+	return switchStatement
+}
+
+// <match> ::= "match" "(" <expression> ")" "{" ("case" <identifier> ("(" <identifier> ")")? ":" <node>* | "default" ":" <node>*)* "}"
+func (parser *Parser) parseMatchStatement() AST.Statement {
+	tok := parser.expect(Token.MATCH)
+	parser.expect(Token.LEFT_PAREN)
+	value := parser.expectExpression()
+	parser.expect(Token.RIGHT_PAREN)
+	open := parser.expect(Token.LEFT_CURLY)
+
+	matchStatement := &AST.StatementMatch{
+		Tok:   tok,
+		Value: value,
+	}
+
+	for !parser.consumeOnMatch(Token.RIGHT_CURLY) {
+		current := parser.peekNthToken(0)
+		if current.Kind == Token.EOF {
+			parser.addDiagnostic(Diagnostic.Error(current, "Expected: RIGHT_CURLY before end of file").
+				WithNote("match opened at line %d", open.Line))
+			break
+		}
+
+		if parser.consumeOnMatch(Token.CASE) {
+			matchCase := &AST.MatchCase{
+				Tok:     current,
+				Variant: parser.expect(Token.IDENTIFIER),
+			}
+
+			if parser.consumeOnMatch(Token.LEFT_PAREN) {
+				binding := parser.expect(Token.IDENTIFIER)
+				matchCase.Binding = &binding
+				parser.expect(Token.RIGHT_PAREN)
+			}
+
+			parser.expect(Token.COLON)
+			matchCase.Body = parser.parseCaseBody()
+			matchStatement.Cases = append(matchStatement.Cases, matchCase)
+		} else if parser.consumeOnMatch(Token.DEFAULT) {
+			if matchStatement.Default != nil {
+				parser.addDiagnostic(Diagnostic.Error(current, "Match already has a default case"))
+			}
+
+			parser.expect(Token.COLON)
+			matchStatement.Default = parser.parseCaseBody()
+		} else {
+			parser.reportError("Expected case or default, got %s", describeToken(current))
+		}
+	}
+
+	return matchStatement
+}
+
 func (parser *Parser) parseStatement() AST.Statement {
 	current := parser.peekNthToken(0)
 
 	if current.Kind == Token.LEFT_CURLY {
 		return parser.parseStatementBlock()
-	} else if current.Kind == Token.IDENTIFIER {
-		next := parser.peekNthToken(1)
-		if next.Kind == Token.LEFT_PAREN {
-			ident := parser.expect(Token.IDENTIFIER)
-			arguments := parser.parseArguments()
-			parser.expect(Token.SEMI_COLON)
-			return &AST.SE_FunctionCall{
-				Tok:       ident,
-				Arguments: arguments,
-			}
-		}
-
+	} else if current.Kind == Token.IDENTIFIER || current.Kind == Token.STAR || current.Kind == Token.LEFT_PAREN {
+		// calls are parsed as the left hand side of an assignment, which hands them back on their own
 		return parser.parseAssignmentStatement()
 	} else if current.Kind == Token.PRINT || current.Kind == Token.PRINTLN {
 		parser.expect(current.Kind)
 		parser.expect(Token.LEFT_PAREN)
-		expr := parser.parseExpression()
+		expr := parser.expectExpression()
 		parser.expect(Token.RIGHT_PAREN)
 		parser.expect(Token.SEMI_COLON)
 
 		return &AST.StatementPrint{
+			Tok:       current,
 			IsNewLine: current.Kind == Token.PRINTLN,
 			Expr:      expr,
 		}
@@ -131,32 +282,37 @@ func (parser *Parser) parseStatement() AST.Statement {
 			Expr: expr,
 		}
 	} else if current.Kind == Token.BREAK {
-		parser.expect(Token.BREAK)
+		tok := parser.expect(Token.BREAK)
 		parser.expect(Token.SEMI_COLON)
 
-		return &AST.StatementBreak{}
+		return &AST.StatementBreak{Tok: tok}
 	} else if current.Kind == Token.CONTINUE {
-		parser.expect(Token.CONTINUE)
+		tok := parser.expect(Token.CONTINUE)
 		parser.expect(Token.SEMI_COLON)
 
-		return &AST.StatementContinue{}
+		return &AST.StatementContinue{Tok: tok}
 	} else if current.Kind == Token.FOR {
 		return parser.parseForStatement()
 	} else if current.Kind == Token.WHILE {
 		return parser.parseWhileStatement()
 	} else if current.Kind == Token.IF {
 		return parser.parseIfElseStatement()
+	} else if current.Kind == Token.SWITCH {
+		return parser.parseSwitchStatement()
+	} else if current.Kind == Token.MATCH {
+		return parser.parseMatchStatement()
 	} else if current.Kind == Token.DEFER {
 		tok := parser.expect(Token.DEFER)
 
-		if decl := parser.parseDeclaration(); decl != nil {
-			panic(fmt.Sprintf("Line %d | Declaration are not deferrable", current.Line))
+		if next := parser.peekNthToken(0); next.Kind == Token.VAR || next.Kind == Token.FN || next.Kind == Token.STRUCT || next.Kind == Token.ENUM || next.Kind == Token.UNION {
+			parser.reportErrorAt(next, "Declaration are not deferrable")
 		}
 
 		if expr := parser.parseExpression(); expr != nil {
 			if _, ok := expr.(AST.Deferrable); !ok {
-				panic(fmt.Sprintf("Line %d | This Expression is not deferrable", current.Line))
+				parser.reportErrorAt(current, "This Expression is not deferrable")
 			}
+			parser.expect(Token.SEMI_COLON)
 
 			return &AST.StatementDefer{
 				Tok:          tok,
@@ -166,7 +322,7 @@ func (parser *Parser) parseStatement() AST.Statement {
 
 		if stmt := parser.parseStatement(); stmt != nil {
 			if _, ok := stmt.(AST.Deferrable); !ok {
-				panic(fmt.Sprintf("Line %d | This Statement is not deferrable", current.Line))
+				parser.reportErrorAt(current, "This Statement is not deferrable")
 			}
 
 			return &AST.StatementDefer{
@@ -176,6 +332,6 @@ func (parser *Parser) parseStatement() AST.Statement {
 		}
 	}
 
-	panic("INVALID STATEMENT!")
+	parser.reportError("Invalid statement, got %s", describeToken(current))
 	return nil
 }
@@ -3,6 +3,7 @@ package Parser
 import (
 	"fmt"
 	"ion-go/AST"
+	"ion-go/Diagnostic"
 	"ion-go/TS"
 	"ion-go/Token"
 )
@@ -11,15 +12,23 @@ type Context struct {
 	ParsingForIncrement     bool
 	ParsingArrayLiteral     int
 	ParsedStructDeclaration map[string]*AST.DeclarationStruct
+	ParsedEnumDeclaration   map[string]*AST.DeclarationEnum
+	ParsedUnionDeclaration  map[string]*AST.DeclarationUnion
+	TypeParameters          map[string]*TS.Type // of the generic function or struct being parsed
 }
 
 type Parser struct {
-	tokens  []Token.Token
-	current int
+	tokens      []Token.Token
+	current     int
+	diagnostics []Diagnostic.Diagnostic
 
 	ctx Context
 }
 
+// parseError is the panic payload used to unwind out of a failed production,
+// the diagnostic itself has already been recorded by the time it is raised.
+type parseError struct{}
+
 func (parser *Parser) consumeNextToken() Token.Token {
 	ret := parser.tokens[parser.current]
 	parser.current += 1
@@ -28,21 +37,120 @@ func (parser *Parser) consumeNextToken() Token.Token {
 }
 
 func (parser *Parser) peekNthToken(n int) Token.Token {
+	if parser.current+n >= len(parser.tokens) {
+		return parser.tokens[len(parser.tokens)-1]
+	}
+
 	return parser.tokens[parser.current+n]
 }
 
-func (parser *Parser) reportError(msg string) {
-	nextToken := parser.peekNthToken(0)
-	fmt.Printf("Parser Error: %s | Line: %d\n", nextToken.Lexeme, nextToken.Line)
-	fmt.Printf("Msg: %s\n", msg)
+func (parser *Parser) reportErrorAt(tok Token.Token, format string, args ...interface{}) {
+	parser.addDiagnostic(Diagnostic.Error(tok, format, args...))
 
-	panic("")
+	panic(parseError{})
+}
+
+// addDiagnostic drops a diagnostic that lands on the same spot as the previous one,
+// recovery can fail on the same token more than once (e.g. several unterminated blocks at EOF)
+func (parser *Parser) addDiagnostic(d Diagnostic.Diagnostic) {
+	if count := len(parser.diagnostics); count > 0 {
+		last := parser.diagnostics[count-1]
+		if last.File == d.File && last.Line == d.Line && last.Column == d.Column {
+			return
+		}
+	}
+
+	parser.diagnostics = append(parser.diagnostics, d)
+}
+
+// synchronize discards tokens until parsing can resume, which is right after a ';',
+// right before a '}' or a fn/struct/var keyword, or right after a brace block
+// that was opened while skipping. Braces the failed node opened before the error
+// (e.g. of a struct literal) are closed first, so their '}' isn't mistaken for
+// the end of the enclosing block. At least one token is always consumed so the
+// caller is guaranteed to make progress.
+func (parser *Parser) synchronize(start int) {
+	open := 0
+	for _, tok := range parser.tokens[start:min(parser.current, len(parser.tokens))] {
+		if tok.Kind == Token.LEFT_CURLY {
+			open += 1
+		} else if tok.Kind == Token.RIGHT_CURLY && open > 0 {
+			open -= 1
+		}
+	}
+
+	depth := 0
+	for {
+		current := parser.peekNthToken(0)
+		if current.Kind == Token.EOF {
+			return
+		}
+
+		if depth == 0 && open == 0 && parser.current > start {
+			if parser.previousToken().Kind == Token.SEMI_COLON {
+				return
+			}
+
+			switch current.Kind {
+			case Token.RIGHT_CURLY, Token.FN, Token.STRUCT, Token.ENUM, Token.UNION, Token.VAR:
+				return
+			}
+		}
+
+		parser.consumeNextToken()
+
+		if current.Kind == Token.LEFT_CURLY {
+			depth += 1
+		} else if current.Kind == Token.RIGHT_CURLY {
+			if depth == 0 {
+				if open == 0 {
+					return
+				}
+
+				open -= 1
+				continue
+			}
+
+			depth -= 1
+			if depth == 0 && open == 0 {
+				return
+			}
+		}
+	}
+}
+
+// recoverNode is deferred by productions that can resynchronize, on a parse error it
+// skips ahead and hands back the token the failed node started at.
+func (parser *Parser) recoverNode(start int, ctx Context, onError func(tok Token.Token)) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	if _, ok := r.(parseError); !ok {
+		panic(r)
+	}
+
+	parser.ctx = ctx
+	parser.synchronize(start)
+	onError(parser.tokens[min(start, len(parser.tokens)-1)])
+}
+
+func (parser *Parser) reportError(format string, args ...interface{}) {
+	parser.reportErrorAt(parser.peekNthToken(0), format, args...)
+}
+
+func describeToken(tok Token.Token) string {
+	if tok.Kind == Token.EOF {
+		return "end of file"
+	}
+
+	return fmt.Sprintf("'%s'", tok.Lexeme)
 }
 
 func (parser *Parser) expect(expectedType Token.TokenType) Token.Token {
 	if parser.peekNthToken(0).Kind != expectedType {
-		msg := fmt.Sprintf("Expected: %s | Got: %s", string(expectedType), parser.peekNthToken(0).Lexeme)
-		parser.reportError(msg)
+		parser.reportError("Expected: %s | Got: %s", string(expectedType), describeToken(parser.peekNthToken(0)))
 	}
 
 	return parser.consumeNextToken()
@@ -61,50 +169,182 @@ func (parser *Parser) previousToken() Token.Token {
 	return parser.tokens[parser.current-1]
 }
 
+// <type> ::= ("[" "]" | "*")* (<identifier> | <function_type>)
 func (parser *Parser) parseType() *TS.Type {
-	arrayCount := 0
+	var modifiers []Token.TokenType
 
-	for parser.peekNthToken(0).Kind == Token.LEFT_BRACKET {
-		parser.consumeOnMatch(Token.LEFT_BRACKET)
-		parser.parseExpression()
-		parser.consumeOnMatch(Token.RIGHT_BRACKET)
-		arrayCount += 1
+	for {
+		if parser.peekNthToken(0).Kind == Token.LEFT_BRACKET {
+			parser.consumeOnMatch(Token.LEFT_BRACKET)
+			parser.parseExpression()
+			parser.consumeOnMatch(Token.RIGHT_BRACKET)
+			modifiers = append(modifiers, Token.LEFT_BRACKET)
+		} else if parser.consumeOnMatch(Token.STAR) {
+			modifiers = append(modifiers, Token.STAR)
+		} else {
+			break
+		}
 	}
 
 	next := parser.peekNthToken(0)
-	if next.Kind != Token.IDENTIFIER {
-		return nil
+	if next.Kind != Token.IDENTIFIER && next.Kind != Token.FN {
+		parser.reportError("Expected a type, got %s", describeToken(next))
 	}
 
-	dataTypeToken := parser.expect(Token.IDENTIFIER)
-	retType := TS.NewType(TS.TypeKind(dataTypeToken.Lexeme), nil, nil)
+	var retType *TS.Type
+	if next.Kind == Token.FN {
+		retType = parser.parseFunctionType()
+	} else {
+		retType = parser.namedType(parser.expect(Token.IDENTIFIER))
+	}
+
+	for i := len(modifiers) - 1; i >= 0; i-- {
+		if modifiers[i] == Token.STAR {
+			retType = retType.AddPointerModifier()
+		} else {
+			retType = retType.AddArrayModifier()
+		}
+	}
 
-	if _, ok := parser.ctx.ParsedStructDeclaration[dataTypeToken.Lexeme]; ok {
-		retType = retType.AddStructModifier()
+	return retType
+}
+
+// <function_type> ::= "fn" "(" (<type> ("," <type>)*)? ")" "->" <return_type>
+func (parser *Parser) parseFunctionType() *TS.Type {
+	parser.expect(Token.FN)
+	parser.expect(Token.LEFT_PAREN)
+
+	var params []TS.Parameter
+	for !parser.consumeOnMatch(Token.RIGHT_PAREN) {
+		params = append(params, TS.Parameter{DeclType: parser.parseType()})
+
+		if parser.peekNthToken(0).Kind != Token.RIGHT_PAREN {
+			parser.expect(Token.COMMA)
+		}
 	}
 
-	for i := 0; i < arrayCount; i++ {
-		retType = retType.AddArrayModifier()
+	parser.expect(Token.RIGHT_ARROW)
+	return TS.NewType(TS.FUNCTION, parser.parseReturnType(), params)
+}
+
+// namedType is a primitive, a type parameter or the struct, enum or union called dataTypeToken,
+// a generic struct is followed by its type arguments e.g. Pair[int, string]
+func (parser *Parser) namedType(dataTypeToken Token.Token) *TS.Type {
+	retType := TS.NewType(TS.TypeKind(dataTypeToken.Lexeme), nil, nil)
+
+	if param, ok := parser.ctx.TypeParameters[dataTypeToken.Lexeme]; ok {
+		retType = TS.NewTypeParameter(dataTypeToken.Lexeme, param.Constraint)
+	} else if structDecl, ok := parser.ctx.ParsedStructDeclaration[dataTypeToken.Lexeme]; ok {
+		var arguments []*TS.Type
+		if len(structDecl.TypeParameters) > 0 && parser.peekNthToken(0).Kind == Token.LEFT_BRACKET {
+			arguments = parser.parseTypeArguments()
+		}
+		retType = TS.NewStructType(dataTypeToken.Lexeme, arguments)
+	} else if enumDecl, ok := parser.ctx.ParsedEnumDeclaration[dataTypeToken.Lexeme]; ok {
+		retType = enumType(enumDecl)
+	} else if unionDecl, ok := parser.ctx.ParsedUnionDeclaration[dataTypeToken.Lexeme]; ok {
+		retType = unionType(unionDecl)
 	}
 
 	return retType
 }
 
-func ParseProgram(tokens []Token.Token) AST.Program {
+// <type_arguments> ::= "[" <type> ("," <type>)* "]"
+func (parser *Parser) parseTypeArguments() []*TS.Type {
+	parser.expect(Token.LEFT_BRACKET)
+
+	var arguments []*TS.Type
+	for {
+		arguments = append(arguments, parser.parseType())
+		if !parser.consumeOnMatch(Token.COMMA) {
+			break
+		}
+	}
+	parser.expect(Token.RIGHT_BRACKET)
+
+	return arguments
+}
+
+// <type_parameters> ::= "[" <type_parameter> ("," <type_parameter>)* "]"
+// <type_parameter>  ::= <identifier> (":" ("Comparable" | "Ordered" | "Number"))?
+func (parser *Parser) parseTypeParameters() []*TS.Type {
+	if !parser.consumeOnMatch(Token.LEFT_BRACKET) {
+		return nil
+	}
+
+	var params []*TS.Type
+	declared := make(map[string]bool)
+	for {
+		name := parser.expect(Token.IDENTIFIER)
+		if declared[name.Lexeme] {
+			parser.reportErrorAt(name, "Duplicate type parameter %s", name.Lexeme)
+		}
+		declared[name.Lexeme] = true
+
+		constraint := ""
+		if parser.consumeOnMatch(Token.COLON) {
+			tok := parser.expect(Token.IDENTIFIER)
+			if !TS.IsConstraint(tok.Lexeme) {
+				parser.reportErrorAt(tok, "Unknown constraint %s, use Comparable, Ordered or Number", tok.Lexeme)
+			}
+			constraint = tok.Lexeme
+		}
+
+		params = append(params, TS.NewTypeParameter(name.Lexeme, constraint))
+		if !parser.consumeOnMatch(Token.COMMA) {
+			break
+		}
+	}
+	parser.expect(Token.RIGHT_BRACKET)
+
+	return params
+}
+
+// enterTypeParameters makes params usable as types, until the returned function restores the outer ones
+func (parser *Parser) enterTypeParameters(params []*TS.Type) func() {
+	outer := parser.ctx.TypeParameters
+	parser.ctx.TypeParameters = make(map[string]*TS.Type, len(outer)+len(params))
+	for name, param := range outer {
+		parser.ctx.TypeParameters[name] = param
+	}
+
+	for _, param := range params {
+		parser.ctx.TypeParameters[param.String()] = param
+	}
+
+	return func() {
+		parser.ctx.TypeParameters = outer
+	}
+}
+
+func (parser *Parser) parseTopLevelDeclaration() (decl AST.Declaration) {
+	defer parser.recoverNode(parser.current, parser.ctx, func(tok Token.Token) {
+		decl = &AST.DeclarationError{Tok: tok}
+	})
+
+	decl = parser.parseDeclaration()
+	if decl == nil {
+		parser.reportError("Unable to parse declaration, got %s", describeToken(parser.peekNthToken(0)))
+	}
+
+	return decl
+}
+
+// ParseProgram never gives up on the first syntax error, declarations that fail to parse
+// are kept in the program as AST.DeclarationError (or AST.StatementError inside blocks)
+// so the returned program is still walkable by later passes.
+func ParseProgram(tokens []Token.Token) (AST.Program, []Diagnostic.Diagnostic) {
 	parser := Parser{}
 	parser.current = 0
 	parser.tokens = tokens
 	parser.ctx.ParsedStructDeclaration = make(map[string]*AST.DeclarationStruct)
+	parser.ctx.ParsedEnumDeclaration = make(map[string]*AST.DeclarationEnum)
+	parser.ctx.ParsedUnionDeclaration = make(map[string]*AST.DeclarationUnion)
 
 	var program AST.Program
-	for parser.current < (len(parser.tokens) - 1) {
-		decl := parser.parseDeclaration()
-		if decl == nil {
-			parser.reportError("Unable to parse declaration")
-		}
-
-		program.Declarations = append(program.Declarations, decl)
+	for parser.peekNthToken(0).Kind != Token.EOF {
+		program.Declarations = append(program.Declarations, parser.parseTopLevelDeclaration())
 	}
 
-	return program
+	return program, parser.diagnostics
 }
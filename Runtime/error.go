@@ -0,0 +1,99 @@
+package Runtime
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MAX_CALL_DEPTH bounds recursion so a runaway Ion program fails with a stack overflow
+// error instead of exhausting the Go stack.
+const MAX_CALL_DEPTH = 4096
+
+// StackFrame is an Ion function that was executing when a runtime error happened,
+// Line and Column are where it was at that moment.
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+	Column   int
+}
+
+func (f StackFrame) String() string {
+	return fmt.Sprintf("%s (%s:%d:%d)", f.Function, f.File, f.Line, f.Column)
+}
+
+// Error is a runtime error in an Ion program. Trace is the call stack, innermost call first,
+// so Trace[0] is also where the error happened. Cause is the Go error behind it when the
+// program was stopped from the outside, e.g. context.Canceled, or a native function failed.
+type Error struct {
+	Message string
+	Trace   []StackFrame
+	Cause   error
+}
+
+// Throw aborts the running program with a runtime error, the engine running it fills in the trace
+func Throw(format string, args ...interface{}) {
+	panic(&Error{Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *Error) Error() string {
+	if len(e.Trace) == 0 {
+		return "runtime error: " + e.Message
+	}
+
+	top := e.Trace[0]
+	return fmt.Sprintf("%s:%d:%d: runtime error: %s", top.File, top.Line, top.Column, e.Message)
+}
+
+// StackTrace lists the calls that led to the error, one `at function (file:line:column)` per line,
+// runs of identical frames from deep recursion are cut short after a few lines
+func (e *Error) StackTrace() string {
+	const repeatsShown = 3
+
+	var sb strings.Builder
+	for i := 0; i < len(e.Trace); {
+		run := 1
+		for i+run < len(e.Trace) && e.Trace[i+run] == e.Trace[i] {
+			run += 1
+		}
+
+		for j := 0; j < min(run, repeatsShown); j++ {
+			fmt.Fprintf(&sb, "    at %s\n", e.Trace[i])
+		}
+
+		if run > repeatsShown {
+			fmt.Fprintf(&sb, "    ... %d more calls to %s\n", run-repeatsShown, e.Trace[i].Function)
+		}
+
+		i += run
+	}
+
+	return sb.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+func (e *Error) Line() int {
+	if len(e.Trace) == 0 {
+		return 0
+	}
+
+	return e.Trace[0].Line
+}
+
+// Recovered turns whatever a running program panicked with into an *Error carrying trace,
+// Go runtime errors from bugs in an engine included so embedders never see a raw panic.
+func Recovered(r interface{}, trace []StackFrame) *Error {
+	err, ok := r.(*Error)
+	if !ok {
+		err = &Error{Message: fmt.Sprint(r)}
+	}
+
+	if err.Trace == nil {
+		err.Trace = trace
+	}
+
+	return err
+}
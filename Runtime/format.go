@@ -0,0 +1,140 @@
+package Runtime
+
+import (
+	"fmt"
+	"strings"
+)
+
+func generateIndent(level int) string {
+	if level <= 0 {
+		return ""
+	}
+
+	return strings.Repeat(" ", level*4)
+}
+
+func formatValue(sb *strings.Builder, value Value, indentLevel int, newLine bool) {
+	nl := ""
+	indentForMembers := ""
+	indentForCloser := generateIndent(indentLevel)
+
+	if newLine {
+		nl = "\n"
+		indentForMembers = generateIndent(indentLevel + 1)
+	} else {
+		indentForCloser = ""
+	}
+
+	switch v := value.(type) {
+	case *ValueInteger:
+		fmt.Fprint(sb, v.Value)
+
+	case *ValueFloat:
+		fmt.Fprintf(sb, "%.5g", v.Value)
+
+	case *ValueSized:
+		sb.WriteString(formatSized(v))
+
+	case *ValueFloat64:
+		sb.WriteString(formatFloat64(v.Value))
+
+	case *ValueBoolean:
+		fmt.Fprint(sb, v.Value)
+
+	case *ValueString:
+		sb.WriteString(v.Value)
+
+	case *ValueChar:
+		sb.WriteRune(v.Value)
+
+	case *ValueEnum:
+		sb.WriteString(v.Name())
+
+	case *ValueUnion:
+		fmt.Fprintf(sb, "%s(", v.DeclType.Variants[v.Index])
+		formatValue(sb, v.Payload, indentLevel+1, false)
+		sb.WriteString(")")
+
+	case *ValueArray:
+		sb.WriteString("[")
+
+		nextLevel := indentLevel + 1
+		for i, elem := range v.Elements {
+			formatValue(sb, elem, nextLevel, false)
+
+			if i < len(v.Elements)-1 {
+				sb.WriteString(", ")
+			}
+		}
+
+		fmt.Fprintf(sb, "%s]", indentForCloser)
+
+	case *ValueStruct:
+		sb.WriteString("{")
+
+		nextLevel := indentLevel + 1
+
+		for i := 0; i < len(v.Decl.Members); i++ {
+			name := v.Decl.Members[i]
+			member := v.Members[name.Tok.Lexeme]
+
+			fmt.Fprintf(sb, "%s%s", nl, indentForMembers)
+
+			// a struct made in a generic function only knows its type parameters' names
+			memberType := name.DeclType.String()
+			if name.DeclType.IsTypeParameter() {
+				memberType = TypeName(member)
+			}
+
+			fmt.Fprintf(sb, "%s: %s = ", name.Tok.Lexeme, memberType)
+
+			formatValue(sb, member, nextLevel, false)
+
+			if i < len(v.Decl.Members)-1 {
+				sb.WriteString(", ")
+			}
+		}
+
+		fmt.Fprintf(sb, "%s%s}", nl, indentForCloser)
+
+	case *ValueFunction:
+		if v.Decl.Anonymous() {
+			sb.WriteString(v.Decl.DeclType.String())
+		} else {
+			fmt.Fprintf(sb, "fn %s", v.Decl.Name())
+		}
+
+	case *ValueTuple:
+		sb.WriteString("(")
+		for i, element := range v.Elements {
+			formatValue(sb, element, indentLevel+1, false)
+
+			if i < len(v.Elements)-1 {
+				sb.WriteString(", ")
+			}
+		}
+		sb.WriteString(")")
+
+	case *ValuePointer:
+		if v.Target == nil {
+			sb.WriteString("nullptr")
+		} else if indentLevel > 0 {
+			// Only the outermost pointer is followed, linked structures can be cyclic
+			fmt.Fprintf(sb, "&%s", TypeName(v.Target.Load()))
+		} else {
+			sb.WriteString("&")
+			formatValue(sb, v.Target.Load(), indentLevel, newLine)
+		}
+
+	default:
+		Throw("cannot print %s", TypeName(v))
+	}
+}
+
+// Format renders a value the way print() shows it, a top level struct gets one member per line
+func Format(value Value) string {
+	var sb strings.Builder
+	formatValue(&sb, value, 0, true)
+
+	return sb.String()
+}
@@ -0,0 +1,101 @@
+package Runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ion-go/TS"
+)
+
+// Host is what a native function can reach of the program calling it
+type Host struct {
+	Context context.Context
+	Stdout  io.Writer
+	Stderr  io.Writer
+	Stdin   io.Reader
+}
+
+// Native implements a function in Go. args already match the declared parameter types and
+// the result has to match the return type, nil for void. A returned error aborts the program
+// with a runtime error at the call.
+type Native func(host *Host, args []Value) (Value, error)
+
+type NativeFunction struct {
+	Name string
+	Type *TS.Type // FUNCTION, Next is the return type
+	Call Native
+}
+
+// Natives are the Go functions a host exposes to Ion programs, they are called like any
+// other function and can't be redeclared by the program. A nil *Natives has no functions.
+type Natives struct {
+	functions map[string]*NativeFunction
+	names     []string // registration order, so compiled programs are deterministic
+}
+
+func NewNatives() *Natives {
+	return &Natives{functions: make(map[string]*NativeFunction)}
+}
+
+// Register adds a native function, fnType has to be a FUNCTION type such as
+//
+//	TS.NewType(TS.FUNCTION, TS.NewType(TS.INTEGER, nil, nil), []TS.Parameter{{DeclType: TS.NewType(TS.STRING, nil, nil)}})
+//
+// for fn(string) -> int
+func (n *Natives) Register(name string, fnType *TS.Type, call Native) error {
+	if fnType == nil || !fnType.IsFunction() || fnType.GetReturnType() == nil {
+		return fmt.Errorf("native %s: expected a function type", name)
+	}
+
+	if _, ok := n.functions[name]; ok {
+		return fmt.Errorf("native %s: already registered", name)
+	}
+
+	n.functions[name] = &NativeFunction{
+		Name: name,
+		Type: fnType,
+		Call: call,
+	}
+	n.names = append(n.names, name)
+
+	return nil
+}
+
+func (n *Natives) Lookup(name string) (*NativeFunction, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	function, ok := n.functions[name]
+	return function, ok
+}
+
+// Functions lists the registered functions in the order they were registered
+func (n *Natives) Functions() []*NativeFunction {
+	if n == nil {
+		return nil
+	}
+
+	functions := make([]*NativeFunction, len(n.names))
+	for i, name := range n.names {
+		functions[i] = n.functions[name]
+	}
+
+	return functions
+}
+
+// CallNative runs function with copies of args, so it can't change the caller's structs,
+// and turns a returned error into a runtime error
+func CallNative(function *NativeFunction, host *Host, args []Value) Value {
+	copies := make([]Value, len(args))
+	for i, arg := range args {
+		copies[i] = Copy(arg)
+	}
+
+	result, err := function.Call(host, copies)
+	if err != nil {
+		panic(&Error{Message: fmt.Sprintf("%s: %v", function.Name, err), Cause: err})
+	}
+
+	return result
+}
@@ -0,0 +1,541 @@
+package Runtime
+
+import (
+	"fmt"
+	"ion-go/TS"
+	"ion-go/Token"
+	"unicode/utf8"
+)
+
+func BinaryOperation(kind Token.TokenType, left, right Value) Value {
+	switch kind {
+	case Token.PLUS, Token.MINUS, Token.STAR, Token.DIVISION, Token.MODULUS,
+		Token.LESS_THAN, Token.LESS_THAN_EQUALS, Token.GREATER_THAN, Token.GREATER_THAN_EQUALS,
+		Token.EQUALS_EQUALS, Token.NOT_EQUALS:
+		// Try int + int
+		if lhs, ok1 := left.(*ValueInteger); ok1 {
+			if rhs, ok2 := right.(*ValueInteger); ok2 {
+				return evaluateIntegers(kind, lhs.Value, rhs.Value)
+			}
+		}
+
+		// Sized integers, promoted to their common kind
+		if lhs, rhs, ok := sizedOperands(left, right); ok {
+			return evaluateSized(kind, lhs.Kind, lhs.Value, rhs.Value)
+		}
+
+		// f64, or f32 promoted to f64
+		if lhs, rhs, ok := float64Operands(left, right); ok {
+			return evaluateFloat64s(kind, lhs, rhs)
+		}
+
+		// Try float + float
+		if lhs, ok1 := left.(*ValueFloat); ok1 {
+			if rhs, ok2 := right.(*ValueFloat); ok2 {
+				return evaluateFloats(kind, lhs.Value, rhs.Value)
+			}
+		}
+
+		// Mixed int + float (promote int to float)
+		if lhs, ok1 := left.(*ValueInteger); ok1 {
+			if rhs, ok2 := right.(*ValueFloat); ok2 {
+				return evaluateFloats(kind, float32(lhs.Value), rhs.Value)
+			}
+		}
+
+		// Mixed float + int (promote int to float)
+		if lhs, ok1 := left.(*ValueFloat); ok1 {
+			if rhs, ok2 := right.(*ValueInteger); ok2 {
+				return evaluateFloats(kind, lhs.Value, float32(rhs.Value))
+			}
+		}
+
+		// Chars compare by code point
+		if lhs, ok1 := left.(*ValueChar); ok1 {
+			if rhs, ok2 := right.(*ValueChar); ok2 {
+				return evaluateIntegers(kind, int(lhs.Value), int(rhs.Value))
+			}
+		}
+
+		// Strings compare with strings, anything else is only concatenated
+		if lhs, ok1 := left.(*ValueString); ok1 {
+			if rhs, ok2 := concatenated(right); ok2 {
+				return evaluateString(kind, lhs.Value, rhs)
+			}
+		}
+
+		if rhs, ok1 := right.(*ValueString); ok1 {
+			if lhs, ok2 := concatenated(left); ok2 {
+				return evaluateString(kind, lhs, rhs.Value)
+			}
+		}
+
+		if kind == Token.EQUALS_EQUALS {
+			return &ValueBoolean{Value: Equal(left, right)}
+		} else if kind == Token.NOT_EQUALS {
+			return &ValueBoolean{Value: !Equal(left, right)}
+		}
+
+		Throw("invalid operands for %v: %s and %s", kind, TypeName(left), TypeName(right))
+
+	case Token.AMPERSAND, Token.PIPE, Token.CARET, Token.LEFT_SHIFT, Token.RIGHT_SHIFT:
+		shift := kind == Token.LEFT_SHIFT || kind == Token.RIGHT_SHIFT
+		if lhs, ok := left.(*ValueSized); ok && shift {
+			return shiftSized(kind, lhs, shiftCount(right))
+		}
+
+		if lhs, rhs, ok := sizedOperands(left, right); ok {
+			return evaluateSized(kind, lhs.Kind, lhs.Value, rhs.Value)
+		}
+
+		// an int shifted by a sized count
+		if lhs, ok := left.(*ValueInteger); ok && shift {
+			return evaluateIntegers(kind, lhs.Value, shiftCount(right))
+		}
+
+		lhs, ok1 := left.(*ValueInteger)
+		rhs, ok2 := right.(*ValueInteger)
+		if !ok1 || !ok2 {
+			Throw("expected integers for %v, got %s and %s", kind, TypeName(left), TypeName(right))
+		}
+
+		return evaluateIntegers(kind, lhs.Value, rhs.Value)
+
+	case Token.LOGICAL_AND, Token.LOGICAL_OR:
+		lhs, ok1 := left.(*ValueBoolean)
+		rhs, ok2 := right.(*ValueBoolean)
+		if !ok1 || !ok2 {
+			Throw("expected booleans for %v, got %s and %s", kind, TypeName(left), TypeName(right))
+		}
+
+		if kind == Token.LOGICAL_AND {
+			return &ValueBoolean{Value: lhs.Value && rhs.Value}
+		} else {
+			return &ValueBoolean{Value: lhs.Value || rhs.Value}
+		}
+
+	default:
+		panic(fmt.Sprintf("unhandled operator: %v", kind))
+	}
+
+	return nil
+}
+
+// concatenated is how a value reads when it's added to a string
+func concatenated(v Value) (string, bool) {
+	switch ev := v.(type) {
+	case *ValueInteger:
+		return fmt.Sprintf("%d", ev.Value), true
+	case *ValueFloat:
+		return fmt.Sprintf("%.5g", ev.Value), true
+	case *ValueSized:
+		return formatSized(ev), true
+	case *ValueFloat64:
+		return formatFloat64(ev.Value), true
+	case *ValueBoolean:
+		return fmt.Sprintf("%t", ev.Value), true
+	case *ValueChar:
+		return string(ev.Value), true
+	case *ValueEnum:
+		return ev.Name(), true
+	case *ValueString:
+		return ev.Value, true
+	}
+
+	return "", false
+}
+
+// Equal is == for two values of the same type, structs are equal when all their members are
+// and pointers when they point to the same place
+func Equal(left, right Value) bool {
+	switch lhs := left.(type) {
+	case *ValueInteger:
+		if rhs, ok := right.(*ValueInteger); ok {
+			return lhs.Value == rhs.Value
+		}
+	case *ValueFloat:
+		if rhs, ok := right.(*ValueFloat); ok {
+			return lhs.Value == rhs.Value
+		}
+	case *ValueSized:
+		if rhs, ok := right.(*ValueSized); ok {
+			return lhs.Value == rhs.Value
+		}
+	case *ValueFloat64:
+		if rhs, ok := right.(*ValueFloat64); ok {
+			return lhs.Value == rhs.Value
+		}
+	case *ValueBoolean:
+		if rhs, ok := right.(*ValueBoolean); ok {
+			return lhs.Value == rhs.Value
+		}
+	case *ValueString:
+		if rhs, ok := right.(*ValueString); ok {
+			return lhs.Value == rhs.Value
+		}
+	case *ValueChar:
+		if rhs, ok := right.(*ValueChar); ok {
+			return lhs.Value == rhs.Value
+		}
+	case *ValueEnum:
+		if rhs, ok := right.(*ValueEnum); ok {
+			return lhs.Index == rhs.Index
+		}
+	case *ValuePointer:
+		if rhs, ok := right.(*ValuePointer); ok {
+			return lhs.Target == rhs.Target
+		}
+	case *ValueStruct:
+		// a literal in a generic function is of an instantiation with type parameters, e.g.
+		// Pair[A, B], so values compare by the struct their instantiations are made from
+		if rhs, ok := right.(*ValueStruct); ok && lhs.Decl.Origin() == rhs.Decl.Origin() {
+			for name, member := range lhs.Members {
+				if !Equal(member, rhs.Members[name]) {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	Throw("cannot compare %s and %s", TypeName(left), TypeName(right))
+	return false
+}
+
+func evaluateIntegers(kind Token.TokenType, lhs, rhs int) Value {
+	switch kind {
+	case Token.PLUS:
+		return &ValueInteger{Value: lhs + rhs}
+	case Token.MINUS:
+		return &ValueInteger{Value: lhs - rhs}
+	case Token.STAR:
+		return &ValueInteger{Value: lhs * rhs}
+	case Token.DIVISION:
+		if rhs == 0 {
+			Throw("integer division by zero")
+		}
+
+		return &ValueInteger{Value: lhs / rhs}
+	case Token.MODULUS:
+		if rhs == 0 {
+			Throw("integer modulo by zero")
+		}
+
+		// the result has the sign of lhs
+		return &ValueInteger{Value: lhs % rhs}
+	case Token.EQUALS_EQUALS:
+		return &ValueBoolean{Value: lhs == rhs}
+	case Token.NOT_EQUALS:
+		return &ValueBoolean{Value: lhs != rhs}
+	case Token.LESS_THAN:
+		return &ValueBoolean{Value: lhs < rhs}
+	case Token.LESS_THAN_EQUALS:
+		return &ValueBoolean{Value: lhs <= rhs}
+	case Token.GREATER_THAN:
+		return &ValueBoolean{Value: lhs > rhs}
+	case Token.GREATER_THAN_EQUALS:
+		return &ValueBoolean{Value: lhs >= rhs}
+	case Token.AMPERSAND:
+		return &ValueInteger{Value: lhs & rhs}
+	case Token.PIPE:
+		return &ValueInteger{Value: lhs | rhs}
+	case Token.CARET:
+		return &ValueInteger{Value: lhs ^ rhs}
+	case Token.LEFT_SHIFT:
+		return &ValueInteger{Value: lhs << checkShift(rhs)}
+	case Token.RIGHT_SHIFT:
+		// arithmetic shift, the sign is kept
+		return &ValueInteger{Value: lhs >> checkShift(rhs)}
+	}
+
+	panic("unreachable")
+}
+
+// checkShift rejects negative shift counts, counts of 64 and more shift every bit out
+func checkShift(count int) int {
+	if count < 0 {
+		Throw("negative shift count %d", count)
+	}
+
+	return count
+}
+
+func evaluateFloats(kind Token.TokenType, lhs, rhs float32) Value {
+	switch kind {
+	case Token.PLUS:
+		return &ValueFloat{Value: lhs + rhs}
+	case Token.MINUS:
+		return &ValueFloat{Value: lhs - rhs}
+	case Token.STAR:
+		return &ValueFloat{Value: lhs * rhs}
+	case Token.DIVISION:
+		return &ValueFloat{Value: lhs / rhs}
+	case Token.EQUALS_EQUALS:
+		return &ValueBoolean{Value: lhs == rhs}
+	case Token.NOT_EQUALS:
+		return &ValueBoolean{Value: lhs != rhs}
+	case Token.LESS_THAN:
+		return &ValueBoolean{Value: lhs < rhs}
+	case Token.LESS_THAN_EQUALS:
+		return &ValueBoolean{Value: lhs <= rhs}
+	case Token.GREATER_THAN:
+		return &ValueBoolean{Value: lhs > rhs}
+	case Token.GREATER_THAN_EQUALS:
+		return &ValueBoolean{Value: lhs >= rhs}
+	}
+	panic("unreachable")
+}
+
+// evaluateString compares strings byte by byte
+func evaluateString(kind Token.TokenType, lhs, rhs string) Value {
+	switch kind {
+	case Token.PLUS:
+		return &ValueString{Value: lhs + rhs}
+	case Token.EQUALS_EQUALS:
+		return &ValueBoolean{Value: lhs == rhs}
+	case Token.NOT_EQUALS:
+		return &ValueBoolean{Value: lhs != rhs}
+	case Token.LESS_THAN:
+		return &ValueBoolean{Value: lhs < rhs}
+	case Token.LESS_THAN_EQUALS:
+		return &ValueBoolean{Value: lhs <= rhs}
+	case Token.GREATER_THAN:
+		return &ValueBoolean{Value: lhs > rhs}
+	case Token.GREATER_THAN_EQUALS:
+		return &ValueBoolean{Value: lhs >= rhs}
+	}
+
+	panic("unreachable")
+}
+
+func UnaryOperation(kind Token.TokenType, operand Value) Value {
+	switch kind {
+	case Token.PLUS:
+		switch operand.(type) {
+		case *ValueInteger, *ValueFloat, *ValueSized, *ValueFloat64:
+			return operand
+
+		default:
+			Throw("invalid operand for %v: %s", kind, TypeName(operand))
+		}
+	case Token.NOT:
+		v, ok := operand.(*ValueBoolean)
+		if !ok {
+			Throw("invalid operand for %v: %s", kind, TypeName(operand))
+		}
+
+		return &ValueBoolean{Value: !v.Value}
+	case Token.MINUS:
+		switch v := operand.(type) {
+		case *ValueInteger:
+			return &ValueInteger{Value: -v.Value}
+		case *ValueFloat:
+			return &ValueFloat{Value: -v.Value}
+		case *ValueSized:
+			return &ValueSized{Kind: v.Kind, Value: wrap(v.Kind, -v.Value)}
+		case *ValueFloat64:
+			return &ValueFloat64{Value: -v.Value}
+
+		default:
+			Throw("invalid operand for %v: %s", kind, TypeName(operand))
+		}
+	case Token.TILDE:
+		if v, ok := operand.(*ValueSized); ok {
+			return &ValueSized{Kind: v.Kind, Value: wrap(v.Kind, ^v.Value)}
+		}
+
+		v, ok := operand.(*ValueInteger)
+		if !ok {
+			Throw("invalid operand for %v: %s", kind, TypeName(operand))
+		}
+
+		return &ValueInteger{Value: ^v.Value}
+	default:
+		panic(fmt.Sprintf("unhandled operator: %v", kind))
+	}
+
+	return nil
+}
+
+func Cast(castType *TS.Type, v Value) Value {
+	switch v.(type) {
+	case *ValueSized, *ValueFloat64:
+		return castSized(castType.Kind, v)
+	}
+
+	if TS.IsSized(castType.Kind) {
+		return castSized(castType.Kind, v)
+	}
+
+	switch ev := v.(type) {
+	case *ValueInteger:
+		if castType.IsEnum() {
+			if ev.Value < 0 || ev.Value >= len(castType.Variants) {
+				Throw("cannot cast %d to %s, it only has variants 0 to %d", ev.Value, castType.String(), len(castType.Variants)-1)
+			}
+
+			return &ValueEnum{DeclType: castType, Index: ev.Value}
+		}
+
+		if castType.Kind == TS.STRING {
+			return &ValueString{Value: fmt.Sprintf("%d", ev.Value)}
+		}
+
+		if castType.Kind == TS.FLOAT {
+			return &ValueFloat{Value: float32(ev.Value)}
+		}
+
+		if castType.Kind == TS.CHAR {
+			if !utf8.ValidRune(rune(ev.Value)) || ev.Value != int(rune(ev.Value)) {
+				Throw("cannot cast %d to char, it isn't a valid code point", ev.Value)
+			}
+
+			return &ValueChar{Value: rune(ev.Value)}
+		}
+
+	case *ValueChar:
+		if castType.Kind == TS.INTEGER {
+			return &ValueInteger{Value: int(ev.Value)}
+		}
+
+		if castType.Kind == TS.STRING {
+			return &ValueString{Value: string(ev.Value)}
+		}
+
+	case *ValueString:
+		if castType.Kind == TS.CHAR {
+			r, size := utf8.DecodeRuneInString(ev.Value)
+			if size == 0 || size != len(ev.Value) {
+				Throw("cannot cast %q to char, it has to hold exactly one character", ev.Value)
+			}
+
+			return &ValueChar{Value: r}
+		}
+
+	case *ValueFloat:
+		if castType.Kind == TS.STRING {
+			return &ValueString{Value: fmt.Sprintf("%.5g", ev.Value)}
+		}
+
+		if castType.Kind == TS.INTEGER {
+			return &ValueInteger{Value: int(ev.Value)}
+		}
+
+	case *ValueEnum:
+		if castType.Kind == TS.INTEGER {
+			return &ValueInteger{Value: ev.Index}
+		}
+
+		if castType.Kind == TS.STRING {
+			return &ValueString{Value: ev.Name()}
+		}
+
+	case *ValueBoolean, *ValueArray, *ValueStruct, *ValuePointer:
+
+	default:
+		Throw("undefined cast from %s to %s", TypeName(v), castType.String())
+	}
+
+	return v
+}
+
+func Len(v Value) Value {
+	switch ev := v.(type) {
+	case *ValueArray:
+		return &ValueInteger{Value: len(ev.Elements)}
+
+	case *ValueString:
+		return &ValueInteger{Value: len(ev.Value)}
+
+	default:
+		Throw("len() of non iterable: %s", TypeName(v))
+	}
+
+	return nil
+}
+
+func checkIndex(array *ValueArray, index Value) int {
+	return checkBounds(len(array.Elements), index)
+}
+
+func checkBounds(length int, index Value) int {
+	i := index.(*ValueInteger).Value
+	if i < 0 || i >= length {
+		Throw("index %d out of range for length %d", i, length)
+	}
+
+	return i
+}
+
+func asArray(v Value) *ValueArray {
+	array, ok := v.(*ValueArray)
+	if !ok {
+		Throw("cannot index %s", TypeName(v))
+	}
+
+	return array
+}
+
+func asUnion(v Value) *ValueUnion {
+	union, ok := v.(*ValueUnion)
+	if !ok {
+		Throw("cannot match on %s", TypeName(v))
+	}
+
+	return union
+}
+
+// Variant is the position of the variant a union holds, what a match compares its cases with
+func Variant(v Value) int {
+	return asUnion(v).Index
+}
+
+// Payload is the value a union holds, a match binds a copy of it
+func Payload(v Value) Value {
+	return Copy(asUnion(v).Payload)
+}
+
+func asStruct(v Value, member string) *ValueStruct {
+	structure, ok := v.(*ValueStruct)
+	if !ok {
+		Throw("cannot access member %s of %s", member, TypeName(v))
+	}
+
+	return structure
+}
+
+// Index on a string decodes the character that starts at that byte, like len() strings are
+// indexed by byte and the middle of a multi-byte character reads as U+FFFD
+func Index(v Value, index Value) Value {
+	if s, ok := v.(*ValueString); ok {
+		i := checkBounds(len(s.Value), index)
+		r, _ := utf8.DecodeRuneInString(s.Value[i:])
+		return &ValueChar{Value: r}
+	}
+
+	array := asArray(v)
+	return array.Elements[checkIndex(array, index)]
+}
+
+func SetIndex(v Value, index Value, element Value) {
+	array := asArray(v)
+	array.Elements[checkIndex(array, index)] = element
+}
+
+// Member and SetMember reach through pointers to structs
+func Member(v Value, name string) Value {
+	if _, ok := v.(*ValuePointer); ok {
+		v = Dereference(v)
+	}
+
+	return asStruct(v, name).Members[name]
+}
+
+func SetMember(v Value, name string, member Value) {
+	if _, ok := v.(*ValuePointer); ok {
+		v = Dereference(v)
+	}
+
+	asStruct(v, name).Members[name] = member
+}
@@ -0,0 +1,102 @@
+package Runtime
+
+// Reference is somewhere a value is stored: a variable, an array element, a struct member or
+// a Cell. Implementations have to be comparable, two pointers are equal when their references are.
+type Reference interface {
+	Load() Value
+	Store(value Value)
+}
+
+// ValuePointer points to a Reference, it is nullptr when Target is nil
+type ValuePointer struct {
+	Target Reference
+}
+
+func (*ValuePointer) isValue() {}
+
+// Cell is storage of its own, used for values whose address is taken when they aren't
+// stored anywhere else, e.g. &Node.{...}
+type Cell struct {
+	Value Value
+}
+
+func (c *Cell) Load() Value {
+	return c.Value
+}
+
+func (c *Cell) Store(value Value) {
+	c.Value = value
+}
+
+// elementReference is fixed to the array it was taken from, like a slice element
+type elementReference struct {
+	array *ValueArray
+	index int
+}
+
+func (r elementReference) Load() Value {
+	return r.array.Elements[r.index]
+}
+
+func (r elementReference) Store(value Value) {
+	r.array.Elements[r.index] = value
+}
+
+// memberReference goes through the struct's own reference so it keeps pointing at the
+// member when the whole struct is assigned to
+type memberReference struct {
+	parent Reference
+	name   string
+}
+
+func (r memberReference) Load() Value {
+	return asStruct(r.parent.Load(), r.name).Members[r.name]
+}
+
+func (r memberReference) Store(value Value) {
+	asStruct(r.parent.Load(), r.name).Members[r.name] = value
+}
+
+func asPointer(v Value) *ValuePointer {
+	pointer, ok := v.(*ValuePointer)
+	if !ok {
+		Throw("cannot dereference %s", TypeName(v))
+	}
+
+	if pointer.Target == nil {
+		Throw("nullptr dereference")
+	}
+
+	return pointer
+}
+
+// Box allocates a Cell holding a copy of v and returns a pointer to it
+func Box(v Value) Value {
+	return &ValuePointer{Target: &Cell{Value: Copy(v)}}
+}
+
+func Dereference(pointer Value) Value {
+	return asPointer(pointer).Target.Load()
+}
+
+// Store assigns through pointer, value has to be copied already
+func Store(pointer Value, value Value) {
+	asPointer(pointer).Target.Store(value)
+}
+
+// AddressIndex turns a pointer to an array into a pointer to one of its elements
+func AddressIndex(pointer Value, index Value) Value {
+	array := asArray(Dereference(pointer))
+	return &ValuePointer{Target: elementReference{array: array, index: checkIndex(array, index)}}
+}
+
+// AddressMember turns a pointer to a struct, or to a pointer to a struct, into a pointer to one of its members
+func AddressMember(pointer Value, name string) Value {
+	target := asPointer(pointer).Target
+	if inner, ok := target.Load().(*ValuePointer); ok {
+		target = asPointer(inner).Target
+	}
+
+	asStruct(target.Load(), name)
+	return &ValuePointer{Target: memberReference{parent: target, name: name}}
+}
@@ -0,0 +1,285 @@
+package Runtime
+
+import (
+	"ion-go/TS"
+	"ion-go/Token"
+	"strconv"
+	"unicode/utf8"
+)
+
+// wrap truncates v to the bits of kind, which is how sized integers overflow
+func wrap(kind TS.TypeKind, v int64) int64 {
+	switch kind {
+	case TS.I8:
+		return int64(int8(v))
+	case TS.I16:
+		return int64(int16(v))
+	case TS.I32:
+		return int64(int32(v))
+	case TS.U8:
+		return int64(uint8(v))
+	case TS.U16:
+		return int64(uint16(v))
+	case TS.U32:
+		return int64(uint32(v))
+	}
+
+	return v
+}
+
+// NewInteger is the value of an integer literal of kind, which can also be a float kind when the
+// literal is used as a float. Sized integers wrap around to fit.
+func NewInteger(kind TS.TypeKind, value uint64) Value {
+	if TS.IsFloat(kind) {
+		return NewFloat(kind, float64(value))
+	}
+
+	if TS.IsSized(kind) {
+		return &ValueSized{Kind: kind, Value: wrap(kind, int64(value))}
+	}
+
+	return &ValueInteger{Value: int(value)}
+}
+
+// NewFloat is the value of a float literal of kind
+func NewFloat(kind TS.TypeKind, value float64) Value {
+	if kind == TS.F64 {
+		return &ValueFloat64{Value: value}
+	}
+
+	return &ValueFloat{Value: float32(value)}
+}
+
+func formatSized(v *ValueSized) string {
+	return formatInteger(v.Value, v.Kind == TS.U64)
+}
+
+// formatInteger prints v, which holds the bits of a u64 when unsigned is set
+func formatInteger(v int64, unsigned bool) string {
+	if unsigned {
+		return strconv.FormatUint(uint64(v), 10)
+	}
+
+	return strconv.FormatInt(v, 10)
+}
+
+// formatFloat64 prints the shortest text that reads back as the same f64
+func formatFloat64(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sizedOperands promotes two sized integers to their common kind, see TS.PromoteSized
+func sizedOperands(left, right Value) (*ValueSized, *ValueSized, bool) {
+	lhs, ok1 := left.(*ValueSized)
+	rhs, ok2 := right.(*ValueSized)
+	if !ok1 || !ok2 {
+		return nil, nil, false
+	}
+
+	kind, ok := TS.PromoteSized(lhs.Kind, rhs.Kind)
+	if !ok {
+		return nil, nil, false
+	}
+
+	// widening keeps the value, only the kind changes
+	return &ValueSized{Kind: kind, Value: lhs.Value}, &ValueSized{Kind: kind, Value: rhs.Value}, true
+}
+
+// float64Operands promotes an f32 next to an f64 to f64
+func float64Operands(left, right Value) (float64, float64, bool) {
+	_, ok1 := left.(*ValueFloat64)
+	_, ok2 := right.(*ValueFloat64)
+	if !ok1 && !ok2 {
+		return 0, 0, false
+	}
+
+	lhs, ok1 := asFloat64(left)
+	rhs, ok2 := asFloat64(right)
+	return lhs, rhs, ok1 && ok2
+}
+
+func asFloat64(v Value) (float64, bool) {
+	switch ev := v.(type) {
+	case *ValueFloat:
+		return float64(ev.Value), true
+	case *ValueFloat64:
+		return ev.Value, true
+	}
+
+	return 0, false
+}
+
+func evaluateSized(kind Token.TokenType, sized TS.TypeKind, lhs, rhs int64) Value {
+	result := func(v int64) Value {
+		return &ValueSized{Kind: sized, Value: wrap(sized, v)}
+	}
+
+	unsigned := TS.IsUnsigned(sized)
+	switch kind {
+	case Token.PLUS:
+		return result(lhs + rhs)
+	case Token.MINUS:
+		return result(lhs - rhs)
+	case Token.STAR:
+		return result(lhs * rhs)
+	case Token.DIVISION:
+		if rhs == 0 {
+			Throw("integer division by zero")
+		}
+
+		if unsigned {
+			return result(int64(uint64(lhs) / uint64(rhs)))
+		}
+
+		return result(lhs / rhs)
+	case Token.MODULUS:
+		if rhs == 0 {
+			Throw("integer modulo by zero")
+		}
+
+		if unsigned {
+			return result(int64(uint64(lhs) % uint64(rhs)))
+		}
+
+		return result(lhs % rhs)
+	case Token.AMPERSAND:
+		return result(lhs & rhs)
+	case Token.PIPE:
+		return result(lhs | rhs)
+	case Token.CARET:
+		return result(lhs ^ rhs)
+	case Token.EQUALS_EQUALS:
+		return &ValueBoolean{Value: lhs == rhs}
+	case Token.NOT_EQUALS:
+		return &ValueBoolean{Value: lhs != rhs}
+	}
+
+	// only a u64 can hold bits that read as a negative int64
+	less, equal := lhs < rhs, lhs == rhs
+	if unsigned {
+		less = uint64(lhs) < uint64(rhs)
+	}
+
+	switch kind {
+	case Token.LESS_THAN:
+		return &ValueBoolean{Value: less}
+	case Token.LESS_THAN_EQUALS:
+		return &ValueBoolean{Value: less || equal}
+	case Token.GREATER_THAN:
+		return &ValueBoolean{Value: !less && !equal}
+	case Token.GREATER_THAN_EQUALS:
+		return &ValueBoolean{Value: !less}
+	}
+
+	panic("unreachable")
+}
+
+// shiftCount is the count of a shift by an int or a sized integer, counts of 64 and more shift every bit out
+func shiftCount(v Value) int {
+	switch ev := v.(type) {
+	case *ValueInteger:
+		return checkShift(ev.Value)
+	case *ValueSized:
+		if ev.Kind == TS.U64 && ev.Value < 0 {
+			return 64
+		}
+
+		return checkShift(int(ev.Value))
+	}
+
+	Throw("expected an integer shift count, got %s", TypeName(v))
+	return 0
+}
+
+// shiftSized shifts right arithmetically for signed kinds and logically for unsigned ones
+func shiftSized(kind Token.TokenType, lhs *ValueSized, count int) Value {
+	if kind == Token.LEFT_SHIFT {
+		return &ValueSized{Kind: lhs.Kind, Value: wrap(lhs.Kind, lhs.Value<<count)}
+	}
+
+	if TS.IsUnsigned(lhs.Kind) {
+		return &ValueSized{Kind: lhs.Kind, Value: int64(uint64(lhs.Value) >> count)}
+	}
+
+	return &ValueSized{Kind: lhs.Kind, Value: lhs.Value >> count}
+}
+
+func evaluateFloat64s(kind Token.TokenType, lhs, rhs float64) Value {
+	switch kind {
+	case Token.PLUS:
+		return &ValueFloat64{Value: lhs + rhs}
+	case Token.MINUS:
+		return &ValueFloat64{Value: lhs - rhs}
+	case Token.STAR:
+		return &ValueFloat64{Value: lhs * rhs}
+	case Token.DIVISION:
+		return &ValueFloat64{Value: lhs / rhs}
+	case Token.EQUALS_EQUALS:
+		return &ValueBoolean{Value: lhs == rhs}
+	case Token.NOT_EQUALS:
+		return &ValueBoolean{Value: lhs != rhs}
+	case Token.LESS_THAN:
+		return &ValueBoolean{Value: lhs < rhs}
+	case Token.LESS_THAN_EQUALS:
+		return &ValueBoolean{Value: lhs <= rhs}
+	case Token.GREATER_THAN:
+		return &ValueBoolean{Value: lhs > rhs}
+	case Token.GREATER_THAN_EQUALS:
+		return &ValueBoolean{Value: lhs >= rhs}
+	}
+
+	panic("unreachable")
+}
+
+// castSized converts to or from a sized kind, integers wrap around to fit and floats are
+// truncated toward zero
+func castSized(kind TS.TypeKind, v Value) Value {
+	switch ev := v.(type) {
+	case *ValueInteger:
+		return convertInteger(kind, int64(ev.Value), false)
+	case *ValueChar:
+		return convertInteger(kind, int64(ev.Value), false)
+	case *ValueSized:
+		return convertInteger(kind, ev.Value, ev.Kind == TS.U64)
+	case *ValueFloat:
+		return convertFloat(kind, float64(ev.Value))
+	case *ValueFloat64:
+		return convertFloat(kind, ev.Value)
+	}
+
+	Throw("undefined cast from %s to %s", TypeName(v), kind)
+	return nil
+}
+
+// convertInteger converts v, which holds the bits of a u64 when unsigned is set
+func convertInteger(kind TS.TypeKind, v int64, unsigned bool) Value {
+	switch {
+	case kind == TS.STRING:
+		return &ValueString{Value: formatInteger(v, unsigned)}
+	case kind == TS.CHAR:
+		if (unsigned && v < 0) || v != int64(rune(v)) || !utf8.ValidRune(rune(v)) {
+			Throw("cannot cast %s to char, it isn't a valid code point", formatInteger(v, unsigned))
+		}
+
+		return &ValueChar{Value: rune(v)}
+	case TS.IsFloat(kind) && unsigned:
+		return NewFloat(kind, float64(uint64(v)))
+	case TS.IsFloat(kind):
+		return NewFloat(kind, float64(v))
+	}
+
+	return NewInteger(kind, uint64(v))
+}
+
+func convertFloat(kind TS.TypeKind, v float64) Value {
+	switch {
+	case kind == TS.STRING:
+		return &ValueString{Value: formatFloat64(v)}
+	case TS.IsFloat(kind):
+		return NewFloat(kind, v)
+	case kind == TS.U64 && v >= 1<<63:
+		return NewInteger(kind, uint64(v))
+	}
+
+	return NewInteger(kind, uint64(int64(v)))
+}
@@ -0,0 +1,192 @@
+package Runtime
+
+import (
+	"fmt"
+	"ion-go/AST"
+	"ion-go/TS"
+	"strings"
+)
+
+// Value is what expressions evaluate to at runtime, it never aliases the AST so the
+// same program can be evaluated any number of times.
+//
+// Arrays behave like slices and are shared when copied, structs are values and are
+// copied whenever they are bound to a variable, parameter, element or member (see Copy).
+// Pointers are copied as is and keep pointing to the same place.
+type Value interface {
+	isValue()
+}
+
+type ValueInteger struct {
+	Value int
+}
+
+type ValueFloat struct {
+	Value float32
+}
+
+// ValueSized is an integer of one of the sized kinds i8 to u64. Value is always in the range of
+// Kind, a u64 above the range of int64 keeps its bits as they are
+type ValueSized struct {
+	Kind  TS.TypeKind
+	Value int64
+}
+
+// ValueFloat64 is an f64, f32 and float are both a ValueFloat
+type ValueFloat64 struct {
+	Value float64
+}
+
+type ValueBoolean struct {
+	Value bool
+}
+
+type ValueString struct {
+	Value string
+}
+
+type ValueChar struct {
+	Value rune
+}
+
+// ValueEnum is a variant of the enum DeclType, Index is its position in DeclType.Variants
+type ValueEnum struct {
+	DeclType *TS.Type
+	Index    int
+}
+
+// ValueUnion is a variant of the union DeclType holding Payload, it's copied like a struct
+type ValueUnion struct {
+	DeclType *TS.Type
+	Index    int
+	Payload  Value
+}
+
+type ValueArray struct {
+	Elements []Value
+	DeclType *TS.Type
+}
+
+type ValueStruct struct {
+	Decl    *AST.DeclarationStruct
+	Members map[string]Value
+}
+
+// ValueFunction is a function used as a value. Closure is whatever the engine running it
+// needs to call it, e.g. the variables an anonymous function captured.
+type ValueFunction struct {
+	Decl    *AST.DeclarationFunction
+	Closure any
+}
+
+// ValueTuple is what a function with several return values returns, it only lives until
+// it's destructured
+type ValueTuple struct {
+	Elements []Value
+}
+
+func (*ValueInteger) isValue()  {}
+func (*ValueFloat) isValue()    {}
+func (*ValueSized) isValue()    {}
+func (*ValueFloat64) isValue()  {}
+func (*ValueBoolean) isValue()  {}
+func (*ValueString) isValue()   {}
+func (*ValueChar) isValue()     {}
+func (*ValueEnum) isValue()     {}
+func (*ValueUnion) isValue()    {}
+func (*ValueArray) isValue()    {}
+func (*ValueStruct) isValue()   {}
+func (*ValueFunction) isValue() {}
+func (*ValueTuple) isValue()    {}
+
+// Copy gives structs and unions their value semantics, everything else is either immutable or shared
+func Copy(v Value) Value {
+	if u, ok := v.(*ValueUnion); ok {
+		return &ValueUnion{
+			DeclType: u.DeclType,
+			Index:    u.Index,
+			Payload:  Copy(u.Payload),
+		}
+	}
+
+	s, ok := v.(*ValueStruct)
+	if !ok {
+		return v
+	}
+
+	members := make(map[string]Value, len(s.Members))
+	for name, member := range s.Members {
+		members[name] = Copy(member)
+	}
+
+	return &ValueStruct{
+		Decl:    s.Decl,
+		Members: members,
+	}
+}
+
+// TypeName describes a value in runtime error messages
+func TypeName(v Value) string {
+	switch ev := v.(type) {
+	case nil:
+		return "void"
+	case *ValueInteger:
+		return "int"
+	case *ValueFloat:
+		return "float"
+	case *ValueSized:
+		return string(ev.Kind)
+	case *ValueFloat64:
+		return "f64"
+	case *ValueBoolean:
+		return "bool"
+	case *ValueString:
+		return "string"
+	case *ValueChar:
+		return "char"
+	case *ValueEnum:
+		return ev.DeclType.String()
+	case *ValueUnion:
+		return ev.DeclType.String()
+	case *ValueArray:
+		if ev.DeclType != nil {
+			return ev.DeclType.String()
+		}
+
+		return "[]"
+	case *ValueStruct:
+		return ev.Decl.Tok.Lexeme
+	case *ValueFunction:
+		return ev.Decl.DeclType.String()
+	case *ValuePointer:
+		if ev.Target == nil {
+			return "nullptr"
+		}
+
+		return "*" + TypeName(ev.Target.Load())
+	case *ValueTuple:
+		elements := make([]string, len(ev.Elements))
+		for i, element := range ev.Elements {
+			elements[i] = TypeName(element)
+		}
+
+		return "(" + strings.Join(elements, ", ") + ")"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+// AsFunction is the function a call through a value calls
+func AsFunction(v Value) *ValueFunction {
+	function, ok := v.(*ValueFunction)
+	if !ok {
+		Throw("cannot call %s", TypeName(v))
+	}
+
+	return function
+}
+
+// Name is how an enum value prints, the name of its variant
+func (v *ValueEnum) Name() string {
+	return v.DeclType.Variants[v.Index]
+}
@@ -2,23 +2,141 @@ package TS
 
 import (
 	"ion-go/Token"
+	"strings"
 )
 
 type TypeKind string
 
 const (
 	INVALID_TYPE TypeKind = "INVALID_TYPE"
+	ERROR                 = "<error>" // poison type given to expressions that already failed to typecheck
 	VOID                  = "void"
 	INTEGER               = "int"
 	FLOAT                 = "float"
 	BOOL                  = "bool"
 	STRING                = "string"
+	CHAR                  = "char" // a Unicode code point
+	I8                    = "i8"
+	I16                   = "i16"
+	I32                   = "i32"
+	I64                   = "i64"
+	U8                    = "u8"
+	U16                   = "u16"
+	U32                   = "u32"
+	U64                   = "u64"
+	F32                   = "f32"
+	F64                   = "f64"
 	ARRAY                 = "[]"
 	STRUCT                = ""
+	ENUM                  = "enum "  // Next is the enum's name, it isn't part of how the type reads
+	UNION                 = "union " // Next is the union's name, like ENUM
 	POINTER               = "*"
 	FUNCTION              = "fn(...) -> "
+	TUPLE                 = "(...)"  // several return values, Parameters are the element types
+	PARAMETER             = "param " // a type parameter of a generic function or struct, Next is its name like ENUM
 )
 
+// Constraints a type parameter can be given, each one allows the operators of the ones before it
+const (
+	COMPARABLE = "Comparable" // == !=
+	ORDERED    = "Ordered"    // < <= > >=
+	NUMBER     = "Number"     // + - * /
+)
+
+var constraintRanks = map[string]int{COMPARABLE: 1, ORDERED: 2, NUMBER: 3}
+
+// IsConstraint reports whether name is one of the constraints
+func IsConstraint(name string) bool {
+	_, ok := constraintRanks[name]
+	return ok
+}
+
+// OperatorConstraint is the weakest constraint that allows the binary operator op, "" when none does
+func OperatorConstraint(op string) string {
+	switch op {
+	case "==", "!=":
+		return COMPARABLE
+	case "<", "<=", ">", ">=":
+		return ORDERED
+	case "+", "-", "*", "/":
+		return NUMBER
+	}
+
+	return ""
+}
+
+// ConstraintImplies reports whether the constraint has allows every operator the constraint wants allows
+func ConstraintImplies(has, wants string) bool {
+	return constraintRanks[has] >= constraintRanks[wants]
+}
+
+// sizedBits are the sized numeric kinds, int (64 bits) and float (32 bits) are kinds of their own
+// that only mix with each other
+var sizedBits = map[TypeKind]int{
+	I8: 8, I16: 16, I32: 32, I64: 64,
+	U8: 8, U16: 16, U32: 32, U64: 64,
+	F32: 32, F64: 64,
+}
+
+// SizedKinds lists the sized numeric kinds, which are also the suffixes of number literals
+var SizedKinds = []TypeKind{I8, I16, I32, I64, U8, U16, U32, U64, F32, F64}
+
+func IsSized(kind TypeKind) bool {
+	_, ok := sizedBits[kind]
+	return ok
+}
+
+func IsUnsigned(kind TypeKind) bool {
+	return kind == U8 || kind == U16 || kind == U32 || kind == U64
+}
+
+func IsFloat(kind TypeKind) bool {
+	return kind == FLOAT || kind == F32 || kind == F64
+}
+
+func IsInteger(kind TypeKind) bool {
+	return kind == INTEGER || (IsSized(kind) && !IsFloat(kind))
+}
+
+func IsNumeric(kind TypeKind) bool {
+	return IsInteger(kind) || IsFloat(kind)
+}
+
+// Bits is the size of a sized kind
+func Bits(kind TypeKind) int {
+	return sizedBits[kind]
+}
+
+// widens reports whether every value of from is also a value of to
+func widens(from, to TypeKind) bool {
+	if IsFloat(from) != IsFloat(to) {
+		return false
+	}
+
+	if IsFloat(from) || IsUnsigned(from) == IsUnsigned(to) {
+		return Bits(to) >= Bits(from)
+	}
+
+	return IsUnsigned(from) && Bits(to) > Bits(from)
+}
+
+// PromoteSized is the kind both sides of an operation on sized kinds are converted to. Only
+// conversions that can't lose a value are implicit: to a wider integer of the same signedness,
+// from unsigned to a wider signed integer and from f32 to f64, everything else needs a cast.
+func PromoteSized(left, right TypeKind) (TypeKind, bool) {
+	if !IsSized(left) || !IsSized(right) {
+		return INVALID_TYPE, false
+	}
+
+	if widens(left, right) {
+		return right, true
+	} else if widens(right, left) {
+		return left, true
+	}
+
+	return INVALID_TYPE, false
+}
+
 type Parameter struct {
 	Tok      Token.Token
 	DeclType *Type
@@ -28,10 +146,13 @@ type Type struct {
 	Kind       TypeKind
 	Next       *Type // For Functions the return type is the last node in the next chain
 	Parameters []Parameter
+	Variants   []string // For enums and unions, the variant names in declaration order
+	Constraint string   // For type parameters, one of the constraints or "" for none
 }
 
+// NewType takes parameters for functions and tuples, and the type arguments of a generic struct
 func NewType(kind TypeKind, next *Type, parameters []Parameter) *Type {
-	if kind != FUNCTION && parameters != nil {
+	if kind != FUNCTION && kind != TUPLE && kind != STRUCT && parameters != nil {
 		panic("Attempted to give parameters to a non function type")
 	}
 
@@ -42,10 +163,20 @@ func NewType(kind TypeKind, next *Type, parameters []Parameter) *Type {
 	}
 }
 
+func (t *Type) IsError() bool {
+	return t != nil && t.Kind == ERROR
+}
+
 func (t *Type) IsPointer() bool {
 	return t.Kind == POINTER
 }
 
+// IsNullptr is the type of the nullptr literal, a pointer to nothing in particular
+// that TypeCompare considers equal to every pointer type
+func (t *Type) IsNullptr() bool {
+	return t.Kind == POINTER && t.Next == nil
+}
+
 func (t *Type) IsArray() bool {
 	return t.Kind == ARRAY
 }
@@ -54,10 +185,95 @@ func (t *Type) IsStruct() bool {
 	return t.Kind == STRUCT
 }
 
+func (t *Type) IsEnum() bool {
+	return t.Kind == ENUM
+}
+
+// NewEnumType is the type of the enum called name, its values are indexes into variants
+func NewEnumType(name string, variants []string) *Type {
+	t := NewType(ENUM, NewType(TypeKind(name), nil, nil), nil)
+	t.Variants = variants
+
+	return t
+}
+
+func (t *Type) IsUnion() bool {
+	return t.Kind == UNION
+}
+
+// NewUnionType is the type of the union called name, the payload types are on its declaration
+func NewUnionType(name string, variants []string) *Type {
+	t := NewType(UNION, NewType(TypeKind(name), nil, nil), nil)
+	t.Variants = variants
+
+	return t
+}
+
+// NewStructType is the struct called name, arguments are the type arguments of a generic struct
+func NewStructType(name string, arguments []*Type) *Type {
+	var params []Parameter
+	for _, argument := range arguments {
+		params = append(params, Parameter{DeclType: argument})
+	}
+
+	return NewType(STRUCT, NewType(TypeKind(name), nil, nil), params)
+}
+
+func (t *Type) IsTypeParameter() bool {
+	return t.Kind == PARAMETER
+}
+
+// NewTypeParameter is the type parameter called name, constraint limits what it can stand for
+func NewTypeParameter(name string, constraint string) *Type {
+	t := NewType(PARAMETER, NewType(TypeKind(name), nil, nil), nil)
+	t.Constraint = constraint
+
+	return t
+}
+
+// Substitute is a copy of t with the type parameters that have a binding replaced by it
+func (t *Type) Substitute(bindings map[string]*Type) *Type {
+	if t == nil {
+		return nil
+	}
+
+	if t.IsTypeParameter() {
+		if bound, ok := bindings[t.String()]; ok {
+			return bound
+		}
+
+		return t
+	}
+
+	copied := *t
+	if t.Parameters != nil {
+		copied.Parameters = make([]Parameter, len(t.Parameters))
+		for i, param := range t.Parameters {
+			copied.Parameters[i] = Parameter{Tok: param.Tok, DeclType: param.DeclType.Substitute(bindings)}
+		}
+	}
+	copied.Next = t.Next.Substitute(bindings)
+
+	return &copied
+}
+
 func (t *Type) IsFunction() bool {
 	return t.Kind == FUNCTION
 }
 
+func (t *Type) IsTuple() bool {
+	return t.Kind == TUPLE
+}
+
+func NewTupleType(elements []*Type) *Type {
+	params := make([]Parameter, len(elements))
+	for i, element := range elements {
+		params[i] = Parameter{DeclType: element}
+	}
+
+	return NewType(TUPLE, nil, params)
+}
+
 func (t *Type) GetReturnType() *Type {
 	if t.Kind != FUNCTION {
 		panic("Return type is not a function")
@@ -104,11 +320,26 @@ func (t *Type) RemoveArrayModifier() *Type {
 	current := NewType(t.Kind, t.Next, t.Parameters)
 
 	current.Kind = current.Next.Kind
+	current.Variants = current.Next.Variants
+	current.Parameters = current.Next.Parameters
+	current.Constraint = current.Next.Constraint
 	current.Next = current.Next.Next
 
 	return current
 }
 
+func (t *Type) AddPointerModifier() *Type {
+	return NewType(POINTER, t, nil)
+}
+
+func (t *Type) RemovePointerModifier() *Type {
+	if t.Kind != POINTER {
+		panic("Expected POINTER type")
+	}
+
+	return t.Next
+}
+
 func (t *Type) AddStructModifier() *Type {
 	if t.Kind == STRUCT {
 		panic("Type is already a struct")
@@ -133,36 +364,67 @@ func (t *Type) RemoveStructModifier() *Type {
 }
 
 func (t *Type) String() string {
+	if t != nil && t.IsNullptr() {
+		return "nullptr"
+	}
+
 	ret := ""
 	current := t
 	for current != nil {
-		ret += string(current.Kind)
+		if current.IsTuple() {
+			return ret + "(" + parameterList(current.Parameters) + ")"
+		}
+
+		if current.IsStruct() && len(current.Parameters) > 0 {
+			return ret + string(current.Next.Kind) + "[" + parameterList(current.Parameters) + "]"
+		}
+
+		if current.IsFunction() {
+			ret += "fn(" + parameterList(current.Parameters) + ") -> "
+		} else if current.Kind != ENUM && current.Kind != UNION && current.Kind != PARAMETER {
+			ret += string(current.Kind)
+		}
 		current = current.Next
 	}
 
 	return ret
 }
 
+// parameterList is the comma separated types of a function's parameters or a tuple's elements
+func parameterList(params []Parameter) string {
+	types := make([]string, len(params))
+	for i, param := range params {
+		types[i] = param.DeclType.String()
+	}
+
+	return strings.Join(types, ", ")
+}
+
 // TypeCompare NOTE(Jovanni):
 // Later on this might have like subtype and type group implications so it probably
 // won't just be a bool it will be some type of int 0 is exact type 1 is super type, -1 is not equal
+//
+// The error type compares equal to everything so a single mistake doesn't cascade into more diagnostics.
 func TypeCompare(c1, c2 *Type) bool {
+	if c1.IsError() || c2.IsError() {
+		return true
+	}
+
 	if c1 == nil || c2 == nil {
 		return false
 	}
 
 	for c1 != nil && c2 != nil {
-		if c1.Kind != c2.Kind {
+		if c1.IsError() || c2.IsError() {
+			return true
+		} else if c1.Kind != c2.Kind {
 			return false
 		} else if len(c1.Parameters) != len(c2.Parameters) {
 			return false
 		} else {
+			// parameter names aren't part of a function's type
 			for i := 0; i < len(c1.Parameters); i++ {
-				param1 := c1.Parameters[i]
-				param2 := c2.Parameters[i]
-				if param1.Tok != param2.Tok {
-					return false
-				} else if !TypeCompare(param1.DeclType, param2.DeclType) {
+				if !TypeCompare(c1.Parameters[i].DeclType, c2.Parameters[i].DeclType) {
 					return false
 				}
 			}
@@ -183,6 +445,10 @@ type BinaryQuery struct {
 
 // GetPromotedType This is strictly for binary operations
 func GetPromotedType(op Token.Token, leftType, rightType *Type) TypeKind {
+	if leftType.IsError() || rightType.IsError() {
+		return ERROR
+	}
+
 	var typeMap = map[BinaryQuery]TypeKind{
 		{"+", INTEGER, INTEGER}: INTEGER,
 		{"+", INTEGER, FLOAT}:   FLOAT,
@@ -206,11 +472,42 @@ func GetPromotedType(op Token.Token, leftType, rightType *Type) TypeKind {
 
 		{"%", INTEGER, INTEGER}: INTEGER,
 
+		{"&", INTEGER, INTEGER}:  INTEGER,
+		{"|", INTEGER, INTEGER}:  INTEGER,
+		{"^", INTEGER, INTEGER}:  INTEGER,
+		{"<<", INTEGER, INTEGER}: INTEGER,
+		{">>", INTEGER, INTEGER}: INTEGER,
+
 		{"+", STRING, STRING}:  STRING,
 		{"+", STRING, INTEGER}: STRING,
 		{"+", STRING, FLOAT}:   STRING,
 		{"+", INTEGER, STRING}: STRING,
 		{"+", FLOAT, STRING}:   STRING,
+		{"+", STRING, BOOL}:    STRING,
+		{"+", BOOL, STRING}:    STRING,
+
+		{"==", STRING, STRING}: BOOL,
+		{"!=", STRING, STRING}: BOOL,
+		{"<", STRING, STRING}:  BOOL,
+		{"<=", STRING, STRING}: BOOL,
+		{">", STRING, STRING}:  BOOL,
+		{">=", STRING, STRING}: BOOL,
+
+		{"+", STRING, ENUM}: STRING,
+		{"+", ENUM, STRING}: STRING,
+
+		{"+", STRING, CHAR}: STRING,
+		{"+", CHAR, STRING}: STRING,
+
+		{"==", CHAR, CHAR}: BOOL,
+		{"!=", CHAR, CHAR}: BOOL,
+		{"<", CHAR, CHAR}:  BOOL,
+		{"<=", CHAR, CHAR}: BOOL,
+		{">", CHAR, CHAR}:  BOOL,
+		{">=", CHAR, CHAR}: BOOL,
+
+		{"==", BOOL, BOOL}: BOOL,
+		{"!=", BOOL, BOOL}: BOOL,
 
 		{"==", INTEGER, INTEGER}: BOOL,
 		{"==", INTEGER, FLOAT}:   BOOL,
@@ -222,6 +519,17 @@ func GetPromotedType(op Token.Token, leftType, rightType *Type) TypeKind {
 		{"!=", FLOAT, INTEGER}:   BOOL,
 		{"!=", FLOAT, FLOAT}:     BOOL,
 
+		{"==", POINTER, POINTER}: BOOL,
+		{"!=", POINTER, POINTER}: BOOL,
+
+		// only for the same enum, see the TypeChecker
+		{"==", ENUM, ENUM}: BOOL,
+		{"!=", ENUM, ENUM}: BOOL,
+
+		// only for the same struct and when all its members are comparable, see the TypeChecker
+		{"==", STRUCT, STRUCT}: BOOL,
+		{"!=", STRUCT, STRUCT}: BOOL,
+
 		{"||", BOOL, BOOL}: BOOL,
 		{"&&", BOOL, BOOL}: BOOL,
 
@@ -255,6 +563,74 @@ func GetPromotedType(op Token.Token, leftType, rightType *Type) TypeKind {
 		return ret
 	}
 
+	return getSizedPromotedType(op.Lexeme, leftType.Kind, rightType.Kind)
+}
+
+// getSizedPromotedType is GetPromotedType for the sized kinds, both sides are promoted with
+// PromoteSized. Shift counts can be of any integer kind, the result has the left side's kind.
+func getSizedPromotedType(op string, left, right TypeKind) TypeKind {
+	switch op {
+	case "<<", ">>":
+		if IsInteger(left) && IsInteger(right) {
+			return left
+		}
+
+		return INVALID_TYPE
+	case "+":
+		if (left == STRING && IsSized(right)) || (IsSized(left) && right == STRING) {
+			return STRING
+		}
+	}
+
+	promoted, ok := PromoteSized(left, right)
+	if !ok {
+		return INVALID_TYPE
+	}
+
+	switch op {
+	case "+", "-", "*", "/":
+		return promoted
+	case "%", "&", "|", "^":
+		if IsInteger(promoted) {
+			return promoted
+		}
+	case "==", "!=", "<", "<=", ">", ">=":
+		return BOOL
+	}
+
+	return INVALID_TYPE
+}
+
+type UnaryQuery struct {
+	Op      string
+	Operand TypeKind
+}
+
+// GetUnaryType is GetPromotedType for unary operators, the result has the operand's type
+func GetUnaryType(op Token.Token, operandType *Type) TypeKind {
+	if operandType.IsError() {
+		return ERROR
+	}
+
+	var typeMap = map[UnaryQuery]TypeKind{
+		{"-", INTEGER}: INTEGER,
+		{"-", FLOAT}:   FLOAT,
+		{"+", INTEGER}: INTEGER,
+		{"+", FLOAT}:   FLOAT,
+		{"~", INTEGER}: INTEGER,
+		{"!", BOOL}:    BOOL,
+	}
+
+	if ret, ok := typeMap[UnaryQuery{Op: op.Lexeme, Operand: operandType.Kind}]; ok {
+		return ret
+	}
+
+	// negating an unsigned value wraps around like any other overflow
+	kind := operandType.Kind
+	if IsSized(kind) && (op.Lexeme == "-" || op.Lexeme == "+" || (op.Lexeme == "~" && IsInteger(kind))) {
+		return kind
+	}
+
 	return INVALID_TYPE
 }
 
@@ -274,6 +650,13 @@ func CanCastType(castType *Type, exprType *Type) bool {
 		{FLOAT, INTEGER}:  true,
 		{STRING, INTEGER}: true,
 		{STRING, FLOAT}:   true,
+		{CHAR, INTEGER}:   true,
+		{INTEGER, CHAR}:   true,
+		{CHAR, STRING}:    true,
+		{STRING, CHAR}:    true,
+		{INTEGER, ENUM}:   true,
+		{ENUM, INTEGER}:   true,
+		{STRING, ENUM}:    true,
 	}
 
 	query := TypeCastQuery{
@@ -285,5 +668,13 @@ func CanCastType(castType *Type, exprType *Type) bool {
 		return true
 	}
 
+	// every numeric kind converts to every other one, integers wrap around to fit
+	cast, expr := castType.Kind, exprType.Kind
+	if IsSized(cast) || IsSized(expr) {
+		return (IsNumeric(cast) && IsNumeric(expr)) ||
+			(cast == STRING && IsNumeric(expr)) ||
+			(cast == CHAR && IsInteger(expr)) || (IsInteger(cast) && expr == CHAR)
+	}
+
 	return false
 }
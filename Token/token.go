@@ -26,6 +26,10 @@ const (
 	RIGHT_BRACKET = "RIGHT_BRACKET" // "]"
 	LEFT_CURLY    = "LEFT_CURLY"    // "{"
 	RIGHT_CURLY   = "RIGHT_CURLY"   // "}"
+	AMPERSAND     = "AMPERSAND"     // "&"
+	PIPE          = "PIPE"          // "|"
+	CARET         = "CARET"         // "^"
+	TILDE         = "TILDE"         // "~"
 
 	// SYNTAX MULTIPLE CHARACTERS
 	EQUALS_EQUALS       = "EQUALS_EQUALS"       // "=="
@@ -35,6 +39,22 @@ const (
 	LOGICAL_AND         = "LOGICAL_AND"         // "&&"
 	LOGICAL_OR          = "LOGICAL_OR"          // "||"
 	RIGHT_ARROW         = "RIGHT_ARROW"         // "->"
+	LEFT_SHIFT          = "LEFT_SHIFT"          // "<<"
+	RIGHT_SHIFT         = "RIGHT_SHIFT"         // ">>"
+
+	// COMPOUND ASSIGNMENT
+	PLUS_EQUALS        = "PLUS_EQUALS"        // "+="
+	MINUS_EQUALS       = "MINUS_EQUALS"       // "-="
+	STAR_EQUALS        = "STAR_EQUALS"        // "*="
+	DIVISION_EQUALS    = "DIVISION_EQUALS"    // "/="
+	MODULUS_EQUALS     = "MODULUS_EQUALS"     // "%="
+	AMPERSAND_EQUALS   = "AMPERSAND_EQUALS"   // "&="
+	PIPE_EQUALS        = "PIPE_EQUALS"        // "|="
+	CARET_EQUALS       = "CARET_EQUALS"       // "^="
+	LEFT_SHIFT_EQUALS  = "LEFT_SHIFT_EQUALS"  // "<<="
+	RIGHT_SHIFT_EQUALS = "RIGHT_SHIFT_EQUALS" // ">>="
+	PLUS_PLUS          = "PLUS_PLUS"          // "++"
+	MINUS_MINUS        = "MINUS_MINUS"        // "--"
 
 	IDENTIFIER        = "IDENTIFIER"
 	INTEGER_LITERAL   = "INTEGER_LITERAL"
@@ -46,6 +66,8 @@ const (
 	// Keywords
 	FN       = "FN"
 	STRUCT   = "STRUCT"
+	ENUM     = "ENUM"
+	UNION    = "UNION"
 	CAST     = "CAST"
 	VAR      = "VAR"
 	IF       = "IF"
@@ -59,6 +81,10 @@ const (
 	PRINT    = "PRINT"
 	PRINTLN  = "PRINTLN"
 	DEFER    = "DEFER"
+	SWITCH   = "SWITCH"
+	CASE     = "CASE"
+	DEFAULT  = "DEFAULT"
+	MATCH    = "MATCH"
 
 	// Builtin
 	BUILTIN_LEN = "BUILTIN_LEN"
@@ -67,17 +93,22 @@ const (
 type Token struct {
 	Kind   TokenType
 	Lexeme string
+	Value  string // the decoded contents of a string or character literal, or a number literal in plain decimal
+	File   string
 	Line   int
+	Column int
 }
 
-func CreateToken(kind TokenType, lexeme string, line int) Token {
-	return Token{kind, lexeme, line}
+func CreateToken(kind TokenType, lexeme string, file string, line int, column int) Token {
+	return Token{Kind: kind, Lexeme: lexeme, File: file, Line: line, Column: column}
 }
 
 func GetKeywordToken(input string) (TokenType, bool) {
 	var m = map[string]TokenType{
 		"fn":       FN,
 		"struct":   STRUCT,
+		"enum":     ENUM,
+		"union":    UNION,
 		"cast":     CAST,
 		"var":      VAR,
 		"if":       IF,
@@ -91,6 +122,10 @@ func GetKeywordToken(input string) (TokenType, bool) {
 		"print":    PRINT,
 		"println":  PRINTLN,
 		"defer":    DEFER,
+		"switch":   SWITCH,
+		"case":     CASE,
+		"default":  DEFAULT,
+		"match":    MATCH,
 		"true":     BOOLEAN_LITERAL,
 		"false":    BOOLEAN_LITERAL,
 	}
@@ -110,6 +145,33 @@ func GetBuiltinToken(input string) (TokenType, bool) {
 	return token, ok
 }
 
+// compoundOperators maps each compound assignment to the binary operator it applies
+var compoundOperators = map[TokenType]TokenType{
+	PLUS_EQUALS:        PLUS,
+	MINUS_EQUALS:       MINUS,
+	STAR_EQUALS:        STAR,
+	DIVISION_EQUALS:    DIVISION,
+	MODULUS_EQUALS:     MODULUS,
+	AMPERSAND_EQUALS:   AMPERSAND,
+	PIPE_EQUALS:        PIPE,
+	CARET_EQUALS:       CARET,
+	LEFT_SHIFT_EQUALS:  LEFT_SHIFT,
+	RIGHT_SHIFT_EQUALS: RIGHT_SHIFT,
+}
+
+// GetCompoundOperator turns a compound assignment like "<<=" into the binary
+// operator token it applies ("<<") at the same position
+func GetCompoundOperator(tok Token) (Token, bool) {
+	kind, ok := compoundOperators[tok.Kind]
+	if !ok {
+		return tok, false
+	}
+
+	tok.Kind = kind
+	tok.Lexeme = tok.Lexeme[:len(tok.Lexeme)-1]
+	return tok, true
+}
+
 func GetSyntaxToken(input string) (TokenType, bool) {
 	var m = map[string]TokenType{
 		"=":  EQUALS,
@@ -131,6 +193,10 @@ func GetSyntaxToken(input string) (TokenType, bool) {
 		"]":  RIGHT_BRACKET,
 		"{":  LEFT_CURLY,
 		"}":  RIGHT_CURLY,
+		"&":  AMPERSAND,
+		"|":  PIPE,
+		"^":  CARET,
+		"~":  TILDE,
 		"==": EQUALS_EQUALS,
 		"!=": NOT_EQUALS,
 		">=": GREATER_THAN_EQUALS,
@@ -138,6 +204,21 @@ func GetSyntaxToken(input string) (TokenType, bool) {
 		"&&": LOGICAL_AND,
 		"||": LOGICAL_OR,
 		"->": RIGHT_ARROW,
+		"<<": LEFT_SHIFT,
+		">>": RIGHT_SHIFT,
+
+		"+=":  PLUS_EQUALS,
+		"-=":  MINUS_EQUALS,
+		"*=":  STAR_EQUALS,
+		"/=":  DIVISION_EQUALS,
+		"%=":  MODULUS_EQUALS,
+		"&=":  AMPERSAND_EQUALS,
+		"|=":  PIPE_EQUALS,
+		"^=":  CARET_EQUALS,
+		"<<=": LEFT_SHIFT_EQUALS,
+		">>=": RIGHT_SHIFT_EQUALS,
+		"++":  PLUS_PLUS,
+		"--":  MINUS_MINUS,
 	}
 
 	token, ok := m[input]
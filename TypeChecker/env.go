@@ -1,7 +1,6 @@
 package TypeChecker
 
 import (
-	"fmt"
 	"ion-go/AST"
 	"ion-go/Token"
 )
@@ -14,16 +13,27 @@ const (
 )
 
 type TypeEnv struct {
+	checker       *checker // reports the errors found while looking up variables
 	parent        *TypeEnv
 	variables     map[string]*AST.DeclarationVariable
 	CurrentStatus Status
 }
 
+// newRootEnv is the environment of the program's top level declarations
+func newRootEnv(tc *checker) *TypeEnv {
+	return &TypeEnv{
+		checker:       tc,
+		variables:     make(map[string]*AST.DeclarationVariable),
+		CurrentStatus: NORMAL,
+	}
+}
+
 func NewTypeEnv(parent *TypeEnv) *TypeEnv {
 	return &TypeEnv{
+		checker:       parent.checker,
 		parent:        parent,
 		variables:     make(map[string]*AST.DeclarationVariable),
-		CurrentStatus: NORMAL,
+		CurrentStatus: parent.CurrentStatus,
 	}
 }
 
@@ -50,13 +60,17 @@ func (t *TypeEnv) get(key Token.Token) *AST.DeclarationVariable {
 		current = current.parent
 	}
 
-	panic(fmt.Sprintf("Line %d | Undeclared Identifier: %s", key.Line, key.Lexeme))
-	return &AST.DeclarationVariable{}
+	t.checker.reportError(key, "Undeclared Identifier: %s", key.Lexeme)
+	return &AST.DeclarationVariable{
+		Tok:      key,
+		DeclType: errorType(),
+	}
 }
 
 func (t *TypeEnv) set(key Token.Token, value *AST.DeclarationVariable) {
 	if t.has(key) {
-		panic(fmt.Sprintf("Line: %d | Variable %s already defined", key.Line, key.Lexeme))
+		t.checker.reportError(key, "Variable %s already defined", key.Lexeme)
+		return
 	}
 
 	t.variables[key.Lexeme] = value
@@ -3,7 +3,14 @@ package TypeChecker
 import (
 	"fmt"
 	"ion-go/AST"
+	"ion-go/Diagnostic"
+	"ion-go/Runtime"
 	"ion-go/TS"
+	"ion-go/Token"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 )
 
 type StatementTypePair struct {
@@ -11,42 +18,979 @@ type StatementTypePair struct {
 	t    *TS.Type
 }
 
-var globalFunctions map[string]*AST.DeclarationFunction
-var globalStruct map[string]*AST.DeclarationStruct
-var globalReturnStatementStack []StatementTypePair
+// checker holds the state of one TypeCheckProgram call, so programs can be checked concurrently
+type checker struct {
+	functions map[string]*AST.DeclarationFunction
+	natives   *Runtime.Natives
+	structs   map[string]*AST.DeclarationStruct
+	enums     map[string]*AST.DeclarationEnum
+	unions    map[string]*AST.DeclarationUnion
 
-func typeCheckFunctionCall(v *AST.SE_FunctionCall, env *TypeEnv) *TS.Type {
-	functionDeclaration, ok := globalFunctions[v.Tok.Lexeme]
+	// returnStatementStack and returnType belong to the function body being checked
+	returnStatementStack []StatementTypePair
+	returnType           *TS.Type
+
+	diagnostics []Diagnostic.Diagnostic
+}
+
+// reportError records the diagnostic and keeps going, callers hand back TS.ERROR
+// (see errorType) for the offending expression so it doesn't cascade.
+func (tc *checker) reportError(tok Token.Token, format string, args ...interface{}) {
+	tc.diagnostics = append(tc.diagnostics, Diagnostic.Error(tok, format, args...))
+}
+
+func errorType() *TS.Type {
+	return TS.NewType(TS.ERROR, nil, nil)
+}
+
+// typeDeclared reports whether a struct, enum or union already has the name, they share one namespace
+func (tc *checker) typeDeclared(name string) bool {
+	_, isStruct := tc.structs[name]
+	_, isEnum := tc.enums[name]
+	_, isUnion := tc.unions[name]
+
+	return isStruct || isEnum || isUnion
+}
+
+// lookupStruct finds the declaration of a struct type, the instantiations of a generic struct are
+// declared the first time they're looked up
+func (tc *checker) lookupStruct(t *TS.Type) (*AST.DeclarationStruct, bool) {
+	if t == nil || !t.IsStruct() {
+		return nil, false
+	}
+
+	if decl, ok := tc.structs[t.String()]; ok {
+		return decl, true
+	}
+
+	generic, ok := tc.structs[string(t.Next.Kind)]
+	if !ok || len(t.Parameters) == 0 || len(generic.TypeParameters) != len(t.Parameters) {
+		return nil, false
+	}
+
+	bindings := make(map[string]*TS.Type)
+	arguments := make([]*TS.Type, len(t.Parameters))
+	for i, param := range generic.TypeParameters {
+		arguments[i] = t.Parameters[i].DeclType
+		bindings[param.String()] = arguments[i]
+	}
+
+	// Declared before its members are substituted so they can point to it, e.g. next: *Node[T]
+	decl := &AST.DeclarationStruct{
+		Tok:           generic.Tok,
+		MemberLookup:  make(map[string]AST.Member),
+		Methods:       make(map[string]*AST.DeclarationFunction),
+		TypeArguments: arguments,
+		Generic:       generic,
+	}
+	tc.structs[t.String()] = decl
+
+	for _, member := range generic.Members {
+		member.DeclType = member.DeclType.Substitute(bindings)
+		decl.Members = append(decl.Members, member)
+		decl.MemberLookup[member.Tok.Lexeme] = member
+	}
+
+	return decl, true
+}
+
+// constraintOperators is an operator each constraint allows, a type satisfies the constraint when it supports it
+var constraintOperators = map[string]string{
+	TS.COMPARABLE: "==",
+	TS.ORDERED:    "<",
+	TS.NUMBER:     "-",
+}
+
+// satisfies reports whether t can stand for a type parameter with the constraint, a type
+// parameter can when its own constraint allows at least as much
+func (tc *checker) satisfies(t *TS.Type, constraint string) bool {
+	if constraint == "" || t.IsError() {
+		return true
+	}
+
+	if t.IsTypeParameter() {
+		return TS.ConstraintImplies(t.Constraint, constraint)
+	}
+
+	op := Token.Token{Lexeme: constraintOperators[constraint]}
+	if TS.GetPromotedType(op, t, t) == TS.INVALID_TYPE {
+		return false
+	}
+
+	if t.IsStruct() {
+		_, _, incomparable := tc.incomparableMember(t, map[string]bool{})
+		return !incomparable
+	}
+
+	return true
+}
+
+// checkBindings reports the type parameters of what that have no type bound to them, or a type
+// their constraint doesn't allow. valueTypes are the types they were inferred from, if any failed
+// to check the parameter not being inferred was already reported.
+func (tc *checker) checkBindings(tok Token.Token, what string, typeParams []*TS.Type, bindings map[string]*TS.Type, valueTypes []*TS.Type) bool {
+	failed := false
+	for _, valueType := range valueTypes {
+		failed = failed || valueType.IsError()
+	}
+
+	ok := true
+	for _, param := range typeParams {
+		bound, found := bindings[param.String()]
+		if !found {
+			if !failed {
+				tc.reportError(tok, "%s can't infer type parameter %s from its arguments", what, param.String())
+			}
+			ok = false
+		} else if !tc.satisfies(bound, param.Constraint) {
+			tc.reportError(tok, "%s can't use %s for %s, it isn't %s", what, bound.String(), param.String(), param.Constraint)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// checkTypeArguments checks that a struct type gives its struct a type argument for each of its type parameters
+func (tc *checker) checkTypeArguments(tok Token.Token, t *TS.Type) bool {
+	ok := true
+	for _, argument := range t.Parameters {
+		ok = tc.checkTypeExists(tok, argument.DeclType) && ok
+	}
+
+	decl, declared := tc.structs[string(t.Next.Kind)]
+	if !declared || !ok {
+		return ok
+	}
+
+	if len(decl.TypeParameters) != len(t.Parameters) {
+		tc.reportError(tok, "%s expects %d type argument(s), got %d", decl.Tok.Lexeme, len(decl.TypeParameters), len(t.Parameters))
+		return false
+	}
+
+	bindings := make(map[string]*TS.Type)
+	for i, param := range decl.TypeParameters {
+		bindings[param.String()] = t.Parameters[i].DeclType
+	}
+
+	return tc.checkBindings(tok, decl.Tok.Lexeme, decl.TypeParameters, bindings, nil)
+}
+
+// checkTypeExists reports type names that are neither primitives, type parameters nor declared
+// structs, enums or unions
+func (tc *checker) checkTypeExists(tok Token.Token, t *TS.Type) bool {
+	if t != nil && t.IsTuple() {
+		ok := true
+		for _, element := range t.Parameters {
+			ok = tc.checkTypeExists(tok, element.DeclType) && ok
+		}
+
+		return ok
+	}
+
+	current := t
+	for current != nil && (current.IsArray() || current.IsStruct() || current.IsEnum() || current.IsUnion() || current.IsPointer()) {
+		if current.IsStruct() && !tc.checkTypeArguments(tok, current) {
+			return false
+		}
+		current = current.Next
+	}
+
+	if current == nil || current.IsError() || current.IsTypeParameter() {
+		return true
+	}
+
+	if current.IsFunction() {
+		ok := tc.checkTypeExists(tok, current.GetReturnType())
+		for _, param := range current.Parameters {
+			ok = tc.checkTypeExists(tok, param.DeclType) && ok
+		}
+
+		return ok
+	}
+
+	switch current.Kind {
+	case TS.VOID, TS.INTEGER, TS.FLOAT, TS.BOOL, TS.STRING, TS.CHAR:
+		return true
+	}
+
+	if TS.IsSized(current.Kind) {
+		return true
+	}
+
+	if !tc.typeDeclared(string(current.Kind)) {
+		tc.reportError(tok, "Undefined type: %s", string(current.Kind))
+		return false
+	}
+
+	return true
+}
+
+// incomparableMember finds a member of a struct type, directly or in a nested struct, that ==
+// can't compare. Arrays are shared like slices and aren't compared, unions and functions aren't
+// comparable, and a type parameter can only be compared when its constraint allows it.
+func (tc *checker) incomparableMember(t *TS.Type, visited map[string]bool) (string, *TS.Type, bool) {
+	name := t.String()
+	decl, ok := tc.lookupStruct(t)
+	if !ok || visited[name] {
+		return "", nil, false
+	}
+	visited[name] = true
+
+	for _, member := range decl.Members {
+		if member.DeclType.IsArray() || member.DeclType.IsUnion() || member.DeclType.IsFunction() || (member.DeclType.IsTypeParameter() && !tc.satisfies(member.DeclType, TS.COMPARABLE)) {
+			return member.Tok.Lexeme, member.DeclType, true
+		}
+
+		if member.DeclType.IsStruct() {
+			if _, _, ok := tc.incomparableMember(member.DeclType, visited); ok {
+				return member.Tok.Lexeme, member.DeclType, true
+			}
+		}
+	}
+
+	return "", nil, false
+}
+
+// isStringElement reports whether chain, which type checked to chainType, ends by indexing a string
+func (tc *checker) isStringElement(chain *AST.ExpressionAccessChain, chainType *TS.Type, env *TypeEnv) bool {
+	last := len(chain.AccessKeys) - 1
+	if _, ok := chain.AccessKeys[last].(*AST.ExpressionArrayAccess); !ok || chainType.Kind != TS.CHAR {
+		return false
+	}
+
+	// the whole chain checked without errors, so its prefix does too
+	if last == 0 && chain.Base != nil {
+		return tc.typeCheckExpression(chain.Base, env).Kind == TS.STRING
+	} else if last == 0 {
+		return env.get(chain.Tok).DeclType.Kind == TS.STRING
+	}
+
+	return tc.typeCheckExpression(&AST.ExpressionAccessChain{Tok: chain.Tok, Base: chain.Base, AccessKeys: chain.AccessKeys[:last]}, env).Kind == TS.STRING
+}
+
+// integerLiteralType checks that an integer literal fits its type, negative is set when it's negated
+func (tc *checker) integerLiteralType(v *AST.ExpressionInteger, negative bool) *TS.Type {
+	kind := v.Kind
+	if kind == "" {
+		kind = TS.INTEGER
+	}
+
+	bits := 64
+	if TS.IsSized(kind) {
+		bits = TS.Bits(kind)
+	}
+
+	limit := uint64(1)<<(bits-1) - 1
+	if negative {
+		limit++
+	}
+
+	if TS.IsUnsigned(kind) {
+		limit = uint64(1)<<(bits-1)<<1 - 1
+		if negative {
+			limit = 0
+		}
+	}
+
+	if !TS.IsFloat(kind) && v.Value > limit {
+		sign := ""
+		if negative {
+			sign = "-"
+		}
+
+		tc.reportError(v.Tok, "Integer literal %s%s is out of range for %s", sign, v.Tok.Lexeme, kind)
+	}
+
+	return TS.NewType(kind, nil, nil)
+}
+
+func (tc *checker) floatLiteralType(v *AST.ExpressionFloat) *TS.Type {
+	kind := v.Kind
+	if kind == "" {
+		kind = TS.FLOAT
+	}
+
+	if kind != TS.F64 && math.Abs(v.Value) > math.MaxFloat32 {
+		tc.reportError(v.Tok, "Float literal %s is out of range for %s", v.Tok.Lexeme, kind)
+	}
+
+	return TS.NewType(kind, nil, nil)
+}
+
+// untypedLiteral reports whether e is made up of number literals without a suffix and arithmetic
+// or bitwise operations on them, and whether one of them is a float literal. %, shifts and bitwise
+// operations only take integer literals.
+func untypedLiteral(e AST.Expression) (untyped bool, float bool) {
+	switch v := e.(type) {
+	case *AST.ExpressionInteger:
+		return v.Kind == "", false
+	case *AST.ExpressionFloat:
+		return v.Kind == "", true
+	case *AST.ExpressionGrouping:
+		return untypedLiteral(v.Expr)
+	case *AST.ExpressionUnary:
+		switch v.Operator.Kind {
+		case Token.MINUS, Token.PLUS:
+			return untypedLiteral(v.Operand)
+		case Token.TILDE:
+			untyped, float := untypedLiteral(v.Operand)
+			return untyped && !float, false
+		}
+	case *AST.ExpressionBinary:
+		leftUntyped, leftFloat := untypedLiteral(v.Left)
+		rightUntyped, rightFloat := untypedLiteral(v.Right)
+		switch v.Operator.Kind {
+		case Token.PLUS, Token.MINUS, Token.STAR, Token.DIVISION:
+			return leftUntyped && rightUntyped, leftFloat || rightFloat
+		case Token.MODULUS, Token.LEFT_SHIFT, Token.RIGHT_SHIFT, Token.AMPERSAND, Token.PIPE, Token.CARET:
+			return leftUntyped && rightUntyped && !leftFloat && !rightFloat, false
+		}
+	}
+
+	return false, false
+}
+
+// inferLiteral gives the literals of an untyped expression the kind it's used as
+func inferLiteral(e AST.Expression, kind TS.TypeKind) {
+	switch v := e.(type) {
+	case *AST.ExpressionInteger:
+		v.Kind = kind
+	case *AST.ExpressionFloat:
+		v.Kind = kind
+	case *AST.ExpressionGrouping:
+		inferLiteral(v.Expr, kind)
+	case *AST.ExpressionUnary:
+		inferLiteral(v.Operand, kind)
+	case *AST.ExpressionBinary:
+		inferLiteral(v.Left, kind)
+		inferLiteral(v.Right, kind)
+	}
+}
+
+// integerOperation reports whether an untyped expression divides, shifts or masks integer literals,
+// which keeps integer semantics so the expression can't take on a float type, e.g. 7 / 2 is 3
+func integerOperation(e AST.Expression) bool {
+	switch v := e.(type) {
+	case *AST.ExpressionGrouping:
+		return integerOperation(v.Expr)
+	case *AST.ExpressionUnary:
+		if v.Operator.Kind == Token.TILDE {
+			return true
+		}
+
+		return integerOperation(v.Operand)
+	case *AST.ExpressionBinary:
+		switch v.Operator.Kind {
+		case Token.DIVISION, Token.MODULUS, Token.LEFT_SHIFT, Token.RIGHT_SHIFT, Token.AMPERSAND, Token.PIPE, Token.CARET:
+			_, leftFloat := untypedLiteral(v.Left)
+			_, rightFloat := untypedLiteral(v.Right)
+			if !leftFloat && !rightFloat {
+				return true
+			}
+		}
+
+		return integerOperation(v.Left) || integerOperation(v.Right)
+	}
+
+	return false
+}
+
+// integerRange is the smallest and the largest value of an integer kind
+func integerRange(kind TS.TypeKind) (*big.Int, *big.Int) {
+	bits := 64
+	if TS.IsSized(kind) {
+		bits = TS.Bits(kind)
+	}
+
+	if TS.IsUnsigned(kind) {
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		return big.NewInt(0), limit.Sub(limit, big.NewInt(1))
+	}
+
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+	return new(big.Int).Neg(limit), new(big.Int).Sub(limit, big.NewInt(1))
+}
+
+func fitsInteger(value *big.Int, kind TS.TypeKind) bool {
+	low, high := integerRange(kind)
+	return value.Cmp(low) >= 0 && value.Cmp(high) <= 0
+}
+
+// constantValue folds an untyped integer expression whose literals took on kind, every operation
+// has to stay in the range of kind like a single literal does, e.g. 200 + 100 doesn't fit a u8.
+// ok is false when the expression can't be folded or doesn't fit, literals that don't fit are
+// reported by integerLiteralType and operations by constantValue.
+func (tc *checker) constantValue(e AST.Expression, kind TS.TypeKind) (value *big.Int, ok bool) {
+	switch v := e.(type) {
+	case *AST.ExpressionInteger:
+		value = new(big.Int).SetUint64(v.Value)
+		return value, fitsInteger(value, kind)
+
+	case *AST.ExpressionGrouping:
+		return tc.constantValue(v.Expr, kind)
+
+	case *AST.ExpressionUnary:
+		if literal, isLiteral := v.Operand.(*AST.ExpressionInteger); isLiteral && v.Operator.Kind == Token.MINUS {
+			value = new(big.Int).Neg(new(big.Int).SetUint64(literal.Value))
+			return value, fitsInteger(value, kind)
+		}
+
+		operand, ok := tc.constantValue(v.Operand, kind)
+		if !ok {
+			return nil, false
+		}
+
+		switch v.Operator.Kind {
+		case Token.PLUS:
+			return operand, true
+		case Token.TILDE:
+			// the bits of kind are flipped, ~0 is 255 for a u8 and -1 for an i8
+			if TS.IsUnsigned(kind) {
+				_, high := integerRange(kind)
+				return high.Sub(high, operand), true
+			}
+
+			return new(big.Int).Not(operand), true
+		case Token.MINUS:
+			value = new(big.Int).Neg(operand)
+			if !fitsInteger(value, kind) {
+				tc.reportError(v.Operator, "Constant -(%s) = %s is out of range for %s", operand, value, kind)
+				return nil, false
+			}
+
+			return value, true
+		}
+
+	case *AST.ExpressionBinary:
+		left, ok := tc.constantValue(v.Left, kind)
+		if !ok {
+			return nil, false
+		}
+
+		right, ok := tc.constantValue(v.Right, kind)
+		if !ok {
+			return nil, false
+		}
+
+		value = new(big.Int)
+		switch v.Operator.Kind {
+		case Token.PLUS:
+			value.Add(left, right)
+		case Token.MINUS:
+			value.Sub(left, right)
+		case Token.STAR:
+			value.Mul(left, right)
+		case Token.DIVISION, Token.MODULUS:
+			// dividing by zero is left to the runtime error
+			if right.Sign() == 0 {
+				return nil, false
+			} else if v.Operator.Kind == Token.DIVISION {
+				value.Quo(left, right)
+			} else {
+				value.Rem(left, right)
+			}
+		case Token.LEFT_SHIFT, Token.RIGHT_SHIFT:
+			// so is a negative count, counts of 64 and more shift every bit out
+			if right.Sign() < 0 {
+				return nil, false
+			}
+
+			count := uint(64)
+			if right.Cmp(big.NewInt(64)) < 0 {
+				count = uint(right.Uint64())
+			}
+
+			if v.Operator.Kind == Token.LEFT_SHIFT {
+				value.Lsh(left, count)
+			} else {
+				value.Rsh(left, count)
+			}
+		case Token.AMPERSAND:
+			value.And(left, right)
+		case Token.PIPE:
+			value.Or(left, right)
+		case Token.CARET:
+			value.Xor(left, right)
+		default:
+			return nil, false
+		}
+
+		if !fitsInteger(value, kind) {
+			tc.reportError(v.Operator, "Constant %s %s %s = %s is out of range for %s", left, v.Operator.Lexeme, right, value, kind)
+			return nil, false
+		}
+
+		return value, true
+	}
+
+	return nil, false
+}
+
+// typeCheckExpected is typeCheckExpression for a value that's used as the expected type, number
+// literals without a suffix take on a numeric expected type, e.g. var b: u8 = 200;
+func (tc *checker) typeCheckExpected(e AST.Expression, expected *TS.Type, env *TypeEnv) *TS.Type {
+	if expected != nil && TS.IsNumeric(expected.Kind) {
+		untyped, float := untypedLiteral(e)
+		if TS.IsFloat(expected.Kind) {
+			untyped = untyped && !integerOperation(e)
+		} else {
+			untyped = untyped && !float
+		}
+
+		if untyped {
+			inferLiteral(e, expected.Kind)
+			if TS.IsInteger(expected.Kind) {
+				tc.constantValue(e, expected.Kind)
+			}
+		}
+	}
+
+	return tc.typeCheckExpression(e, env)
+}
+
+// operandType is the type an untyped literal next to an operand of type t takes on, only sized
+// types are expected since ints and floats already mix
+func operandType(t *TS.Type) *TS.Type {
+	if TS.IsSized(t.Kind) {
+		return t
+	}
+
+	return nil
+}
+
+// typeCheckOperands lets an untyped literal on one side of a binary operation take on the type
+// of the other side, like 1 in x + 1 for x: u8
+func (tc *checker) typeCheckOperands(left, right AST.Expression, env *TypeEnv) (*TS.Type, *TS.Type) {
+	if leftUntyped, _ := untypedLiteral(left); leftUntyped {
+		if rightUntyped, _ := untypedLiteral(right); !rightUntyped {
+			rt := tc.typeCheckExpression(right, env)
+			return tc.typeCheckExpected(left, operandType(rt), env), rt
+		}
+	}
+
+	lt := tc.typeCheckExpression(left, env)
+	return lt, tc.typeCheckExpected(right, operandType(lt), env)
+}
+
+// lookupFunction finds the type of a declared or native function
+func (tc *checker) lookupFunction(name string) (*TS.Type, bool) {
+	if functionDeclaration, ok := tc.functions[name]; ok {
+		return functionDeclaration.DeclType, true
+	}
+
+	if native, ok := tc.natives.Lookup(name); ok {
+		return native.Type, true
+	}
+
+	return nil, false
+}
+
+func (tc *checker) typeCheckFunctionCall(v *AST.SE_FunctionCall, env *TypeEnv) *TS.Type {
+	// a variable holding a function shadows the function with its name
+	if v.Callee == nil && env.has(v.Tok) {
+		v.Callee = &AST.ExpressionIdentifier{Tok: v.Tok}
+	}
+
+	if v.Callee != nil {
+		name := v.Tok.Lexeme
+		if v.Tok.Kind != Token.IDENTIFIER {
+			name = "fn"
+		}
+
+		return tc.typeCheckValueCall(v.Tok, name, tc.typeCheckExpression(v.Callee, env), v.Arguments, env)
+	}
+
+	functionType, ok := tc.lookupFunction(v.Tok.Lexeme)
+	if !ok {
+		tc.reportError(v.Tok, "Undefined function: %s", v.Tok.Lexeme)
+		for _, arg := range v.Arguments {
+			tc.typeCheckExpression(arg, env)
+		}
+
+		return errorType()
+	}
+
+	if decl, ok := tc.functions[v.Tok.Lexeme]; ok && len(decl.TypeParameters) > 0 {
+		return tc.typeCheckGenericCall(v.Tok, decl, v.Arguments, env)
+	}
+
+	tc.typeCheckArguments(v.Tok, v.Tok.Lexeme, functionType.Parameters, v.Arguments, env)
+	return functionType.GetReturnType()
+}
+
+// unify binds the type parameters in param to the parts of arg they line up with, a type
+// parameter keeps the first type bound to it
+func unify(param, arg *TS.Type, bindings map[string]*TS.Type) {
+	for param != nil && arg != nil {
+		if param.IsTypeParameter() {
+			if _, ok := bindings[param.String()]; !ok && !arg.IsError() && !arg.IsNullptr() {
+				bindings[param.String()] = arg
+			}
+
+			return
+		}
+
+		if param.Kind != arg.Kind {
+			return
+		}
+
+		for i := 0; i < len(param.Parameters) && i < len(arg.Parameters); i++ {
+			unify(param.Parameters[i].DeclType, arg.Parameters[i].DeclType, bindings)
+		}
+
+		param, arg = param.Next, arg.Next
+	}
+}
+
+// inferTypeArguments checks values and binds the type parameters in the types they're given to
+// to theirs. Untyped number literals are checked last so they take on the types inferred from the
+// other values, e.g. T is f32 in max(x, 1) for x: f32. The values' types are returned.
+func (tc *checker) inferTypeArguments(params []*TS.Type, values []AST.Expression, bindings map[string]*TS.Type, env *TypeEnv) []*TS.Type {
+	valueTypes := make([]*TS.Type, len(values))
+	for i, value := range values {
+		if untyped, _ := untypedLiteral(value); !untyped {
+			valueTypes[i] = tc.typeCheckExpression(value, env)
+			unify(params[i], valueTypes[i], bindings)
+		}
+	}
+
+	for i, value := range values {
+		if valueTypes[i] == nil {
+			valueTypes[i] = tc.typeCheckExpected(value, params[i].Substitute(bindings), env)
+			unify(params[i], valueTypes[i], bindings)
+		}
+	}
+
+	return valueTypes
+}
+
+// typeCheckGenericCall checks a call of a generic function, its type arguments are inferred from the arguments
+func (tc *checker) typeCheckGenericCall(tok Token.Token, decl *AST.DeclarationFunction, arguments []AST.Expression, env *TypeEnv) *TS.Type {
+	name := decl.Name()
+	params := decl.DeclType.Parameters
+	if len(params) != len(arguments) {
+		tc.reportError(tok, "%s() expected %d argument(s), got %d", name, len(params), len(arguments))
+	}
+
+	count := min(len(params), len(arguments))
+	for _, arg := range arguments[count:] {
+		tc.typeCheckExpression(arg, env)
+	}
+
+	paramTypes := make([]*TS.Type, count)
+	for i := range paramTypes {
+		paramTypes[i] = params[i].DeclType
+	}
+
+	bindings := make(map[string]*TS.Type)
+	argTypes := tc.inferTypeArguments(paramTypes, arguments[:count], bindings, env)
+	if !tc.checkBindings(tok, name+"()", decl.TypeParameters, bindings, argTypes) {
+		return errorType()
+	}
+
+	for i, argType := range argTypes {
+		paramType := paramTypes[i].Substitute(bindings)
+		if !TS.TypeCompare(paramType, argType) {
+			tc.reportError(tok, "%s() argument %d: expected %s, got %s", name, i, paramType.String(), argType.String())
+		}
+	}
+
+	return decl.DeclType.GetReturnType().Substitute(bindings)
+}
+
+// typeCheckValueCall checks a call of a function value of type calleeType
+func (tc *checker) typeCheckValueCall(tok Token.Token, name string, calleeType *TS.Type, arguments []AST.Expression, env *TypeEnv) *TS.Type {
+	if !calleeType.IsFunction() {
+		if !calleeType.IsError() {
+			tc.reportError(tok, "Can't call a value of type %s", calleeType.String())
+		}
+
+		for _, arg := range arguments {
+			tc.typeCheckExpression(arg, env)
+		}
+
+		return errorType()
+	}
+
+	tc.typeCheckArguments(tok, name, calleeType.Parameters, arguments, env)
+	return calleeType.GetReturnType()
+}
+
+// typeCheckArguments checks the arguments of a call to the function called name against its parameters
+func (tc *checker) typeCheckArguments(tok Token.Token, name string, params []TS.Parameter, arguments []AST.Expression, env *TypeEnv) {
+	argCount := len(arguments)
+	paramCount := len(params)
+
+	if paramCount != argCount {
+		tc.reportError(tok, "%s() expected %d argument(s), got %d", name, paramCount, argCount)
+	}
+
+	for i := 0; i < argCount; i++ {
+		if i >= paramCount {
+			tc.typeCheckExpression(arguments[i], env)
+			continue
+		}
+
+		param := params[i]
+		argType := tc.typeCheckExpected(arguments[i], param.DeclType, env)
+		if !TS.TypeCompare(param.DeclType, argType) {
+			tc.reportError(tok, "%s() argument %d: expected %s, got %s", name, i, param.DeclType.String(), argType.String())
+		}
+	}
+}
+
+// receiverStruct is the struct a method receiver, a struct or a pointer to one, is declared on
+func (tc *checker) receiverStruct(t *TS.Type) (*AST.DeclarationStruct, bool) {
+	if t.IsPointer() && !t.IsNullptr() {
+		t = t.Next
+	}
+
+	if !t.IsStruct() {
+		return nil, false
+	}
+
+	return tc.lookupStruct(t)
+}
+
+func (tc *checker) typeCheckMethodCall(v *AST.SE_MethodCall, env *TypeEnv) *TS.Type {
+	v.Method = nil
+	v.ReceiverArgument = v.Receiver
+	v.Callee = nil
+
+	receiverType := tc.typeCheckExpression(v.Receiver, env)
+	decl, ok := tc.receiverStruct(receiverType)
+	if ok {
+		v.Method, ok = decl.Methods[v.Tok.Lexeme]
+	}
+
+	// x.f(...) where the member f holds a function
+	if decl != nil && !ok {
+		if member, isMember := decl.MemberLookup[v.Tok.Lexeme]; isMember {
+			v.Callee = memberAccess(v.Receiver, v.Tok)
+			return tc.typeCheckValueCall(v.Tok, v.Tok.Lexeme, member.DeclType, v.Arguments, env)
+		}
+	}
+
+	if !ok {
+		if !receiverType.IsError() {
+			tc.reportError(v.Tok, "%s has no method named %s", receiverType.String(), v.Tok.Lexeme)
+		}
+
+		for _, arg := range v.Arguments {
+			tc.typeCheckExpression(arg, env)
+		}
+
+		return errorType()
+	}
+
+	// x.m() takes x's address for a pointer receiver and p.m() dereferences p for a value one
+	params := v.Method.DeclType.Parameters
+	if wantsPointer := params[0].DeclType.IsPointer(); wantsPointer && !receiverType.IsPointer() {
+		v.ReceiverArgument = &AST.ExpressionAddressOf{Tok: v.Tok, Operand: v.Receiver}
+	} else if !wantsPointer && receiverType.IsPointer() {
+		v.ReceiverArgument = &AST.ExpressionDereference{Tok: v.Tok, Operand: v.Receiver}
+	}
+
+	tc.typeCheckArguments(v.Tok, v.Method.Name(), params[1:], v.Arguments, env)
+	return v.Method.DeclType.GetReturnType()
+}
+
+// memberAccess is receiver.member, receiver being an identifier, an access chain or a call
+func memberAccess(receiver AST.Expression, member Token.Token) *AST.ExpressionAccessChain {
+	key := &AST.ExpressionIdentifier{Tok: member}
+	switch v := receiver.(type) {
+	case *AST.ExpressionAccessChain:
+		keys := append(append([]AST.Expression{}, v.AccessKeys...), key)
+		return &AST.ExpressionAccessChain{Tok: v.Tok, Base: v.Base, AccessKeys: keys}
+	case *AST.ExpressionIdentifier:
+		return &AST.ExpressionAccessChain{Tok: v.Tok, AccessKeys: []AST.Expression{key}}
+	}
+
+	return &AST.ExpressionAccessChain{Tok: member, Base: receiver, AccessKeys: []AST.Expression{key}}
+}
+
+// functionName reports whether e names a declared function that no variable shadows, functions
+// are values but they can't be assigned to or have their address taken
+func (tc *checker) functionName(e AST.Expression, env *TypeEnv) (string, bool) {
+	ident, ok := e.(*AST.ExpressionIdentifier)
+	if !ok || env.has(ident.Tok) {
+		return "", false
+	}
+
+	_, ok = tc.functions[ident.Tok.Lexeme]
+	return ident.Tok.Lexeme, ok
+}
+
+// typeCheckFunctionBody checks a function's parameters and body, the body sees the variables of env
+func (tc *checker) typeCheckFunctionBody(v *AST.DeclarationFunction, env *TypeEnv) {
+	ok := false
+	if len(v.Block.Body) > 0 {
+		switch v.Block.Body[len(v.Block.Body)-1].(type) {
+		case *AST.StatementReturn, *AST.StatementError:
+			ok = true
+		}
+	}
+
+	if !ok && v.DeclType.GetReturnType().Kind != TS.VOID {
+		tc.reportError(v.Tok, "%s() body is missing a return statement or it is not the last statement in the body", v.Name())
+	}
+
+	if !tc.checkTypeExists(v.Tok, v.DeclType.GetReturnType()) {
+		v.DeclType.Next = errorType()
+	}
+
+	funcEnv := NewTypeEnv(env)
+	funcEnv.CurrentStatus = NORMAL
+	for i, param := range v.DeclType.Parameters {
+		if !tc.checkTypeExists(param.Tok, param.DeclType) {
+			param.DeclType = errorType()
+			v.DeclType.Parameters[i] = param
+		}
+
+		funcEnv.set(param.Tok, &AST.DeclarationVariable{
+			Tok:      param.Tok,
+			DeclType: param.DeclType,
+		})
+	}
+
+	tc.returnType = v.DeclType.GetReturnType()
+	for _, node := range v.Block.Body {
+		tc.typeCheckNode(node, funcEnv)
+		for _, pair := range tc.returnStatementStack {
+			if v.DeclType.GetReturnType().Kind == TS.VOID {
+				tc.reportError(pair.stmt.Tok, "Attempting to return expression in %s() with return type void", v.Name())
+			} else if !TS.TypeCompare(v.DeclType.GetReturnType(), pair.t) {
+				tc.reportError(pair.stmt.Tok, "%s() has a return type of %s but returns a %s", v.Name(), v.DeclType.GetReturnType().String(), pair.t.String())
+			}
+		}
+		tc.returnStatementStack = nil
+	}
+}
+
+// declareMethod adds a method to the struct its receiver is declared on
+func (tc *checker) declareMethod(v *AST.DeclarationFunction) {
+	receiver := v.DeclType.Parameters[0]
+	if receiver.DeclType.IsError() {
+		return
+	}
+
+	decl, ok := tc.receiverStruct(receiver.DeclType)
 	if !ok {
-		panic("undefined function " + v.Tok.Lexeme)
+		tc.reportError(receiver.Tok, "Methods can only be declared on structs and pointers to structs, got %s", receiver.DeclType.String())
+		return
 	}
 
-	argCount := len(v.Arguments)
-	paramCount := len(functionDeclaration.DeclType.Parameters)
+	if _, ok := decl.MemberLookup[v.Tok.Lexeme]; ok {
+		tc.reportError(v.Tok, "%s already has a member named %s", decl.Tok.Lexeme, v.Tok.Lexeme)
+	} else if _, ok := decl.Methods[v.Tok.Lexeme]; ok {
+		tc.reportError(v.Tok, "Attempting to redeclare method %s", v.Name())
+	} else {
+		decl.Methods[v.Tok.Lexeme] = v
+	}
+}
 
-	if paramCount != argCount {
-		panic(fmt.Sprintf("expected %d parameter(s), got %d", argCount, paramCount))
+// typeCheckParameterOperation checks a binary operation on values of a type parameter, both sides
+// have to be the same type parameter and its constraint has to allow the operator
+func (tc *checker) typeCheckParameterOperation(op Token.Token, lt, rt *TS.Type) *TS.Type {
+	if lt.IsError() || rt.IsError() {
+		return errorType()
 	}
 
-	for i := 0; i < argCount; i++ {
-		param := functionDeclaration.DeclType.Parameters[i]
-		argType := typeCheckExpression(v.Arguments[i], env)
+	constraint := TS.OperatorConstraint(op.Lexeme)
+	if constraint == "" || !TS.TypeCompare(lt, rt) {
+		tc.reportError(op, "Operation %s not supported on Left: %s | Right: %s", op.Lexeme, lt.String(), rt.String())
+		return errorType()
+	}
 
-		if !TS.TypeCompare(param.DeclType, argType) {
-			panic(fmt.Sprintf("Line %d | argument %d: expected %s, got %s", v.Tok.Line, i, param.DeclType.String(), argType.String()))
+	if !TS.ConstraintImplies(lt.Constraint, constraint) {
+		tc.reportError(op, "Operation %s not supported on %s, it needs a constraint like %s: %s", op.Lexeme, lt.String(), lt.String(), constraint)
+		return errorType()
+	}
+
+	if constraint == TS.NUMBER {
+		return lt
+	}
+
+	return TS.NewType(TS.BOOL, nil, nil)
+}
+
+// typeCheckMembers checks the member values of a struct literal against the members of decl
+func (tc *checker) typeCheckMembers(v *AST.ExpressionStruct, decl *AST.DeclarationStruct, env *TypeEnv) {
+	argCount := len(v.MemberValues)
+	memberCount := len(decl.Members)
+
+	if memberCount != argCount {
+		tc.reportError(v.Tok, "%s expected %d member(s), got %d", v.Tok.Lexeme, memberCount, argCount)
+	}
+
+	for i, member := range decl.Members {
+		value, ok := v.MemberValues[member.Tok.Lexeme]
+		if !ok {
+			continue
+		}
+
+		argType := tc.typeCheckExpected(value, member.DeclType, env)
+		if !TS.TypeCompare(member.DeclType, argType) {
+			tc.reportError(v.Tok, "member %d: expected %s: %s, got %s", i, member.Tok.Lexeme, member.DeclType.String(), argType.String())
+		}
+	}
+}
+
+// typeCheckGenericStruct checks a literal of a generic struct, when it doesn't give the type
+// arguments they're inferred from the member values
+func (tc *checker) typeCheckGenericStruct(v *AST.ExpressionStruct, generic *AST.DeclarationStruct, env *TypeEnv) *TS.Type {
+	if v.TypeArguments != nil {
+		structType := TS.NewStructType(generic.Tok.Lexeme, v.TypeArguments)
+		if !tc.checkTypeExists(v.Tok, structType) {
+			for _, value := range v.MemberValues {
+				tc.typeCheckExpression(value, env)
+			}
+
+			return errorType()
+		}
+
+		v.Decl, _ = tc.lookupStruct(structType)
+		tc.typeCheckMembers(v, v.Decl, env)
+		return structType
+	}
+
+	memberTypes := make([]*TS.Type, len(generic.Members))
+	values := make([]AST.Expression, len(generic.Members))
+	for i, member := range generic.Members {
+		memberTypes[i] = member.DeclType
+		values[i] = v.MemberValues[member.Tok.Lexeme]
+	}
+
+	bindings := make(map[string]*TS.Type)
+	valueTypes := tc.inferTypeArguments(memberTypes, values, bindings, env)
+	if !tc.checkBindings(v.Tok, generic.Tok.Lexeme, generic.TypeParameters, bindings, valueTypes) {
+		return errorType()
+	}
+
+	arguments := make([]*TS.Type, len(generic.TypeParameters))
+	for i, param := range generic.TypeParameters {
+		arguments[i] = bindings[param.String()]
+	}
+
+	structType := TS.NewStructType(generic.Tok.Lexeme, arguments)
+	v.Decl, _ = tc.lookupStruct(structType)
+	for i, member := range v.Decl.Members {
+		if !TS.TypeCompare(member.DeclType, valueTypes[i]) {
+			tc.reportError(v.Tok, "member %d: expected %s: %s, got %s", i, member.Tok.Lexeme, member.DeclType.String(), valueTypes[i].String())
 		}
 	}
 
-	return functionDeclaration.DeclType.GetReturnType()
+	return structType
 }
 
-func typeCheckExpression(e AST.Expression, env *TypeEnv) *TS.Type {
+func (tc *checker) typeCheckExpression(e AST.Expression, env *TypeEnv) *TS.Type {
 	switch v := e.(type) {
 	case *AST.ExpressionInteger:
-		return TS.NewType(TS.INTEGER, nil, nil)
+		return tc.integerLiteralType(v, false)
 
 	case *AST.ExpressionFloat:
-		return TS.NewType(TS.FLOAT, nil, nil)
+		return tc.floatLiteralType(v)
 
 	case *AST.ExpressionBoolean:
 		return TS.NewType(TS.BOOL, nil, nil)
@@ -54,241 +998,570 @@ func typeCheckExpression(e AST.Expression, env *TypeEnv) *TS.Type {
 	case *AST.ExpressionString:
 		return TS.NewType(TS.STRING, nil, nil)
 
+	case *AST.ExpressionChar:
+		return TS.NewType(TS.CHAR, nil, nil)
+
 	case *AST.ExpressionIdentifier:
+		// a function is a value of its type
+		if !env.has(v.Tok) {
+			if function, ok := tc.functions[v.Tok.Lexeme]; ok {
+				if len(function.TypeParameters) > 0 {
+					tc.reportError(v.Tok, "Generic function %s can't be used as a value", v.Tok.Lexeme)
+					return errorType()
+				}
+
+				return function.DeclType
+			} else if _, ok := tc.natives.Lookup(v.Tok.Lexeme); ok {
+				tc.reportError(v.Tok, "Native function %s can't be used as a value", v.Tok.Lexeme)
+				return errorType()
+			}
+		}
+
 		decl := env.get(v.Tok)
 		return decl.DeclType
 
+	case *AST.ExpressionFunction:
+		// the enclosing function's returns are still being checked
+		returnType, returns := tc.returnType, tc.returnStatementStack
+		tc.returnStatementStack = nil
+		tc.typeCheckFunctionBody(v.Decl, env)
+		tc.returnType, tc.returnStatementStack = returnType, returns
+
+		return v.Decl.DeclType
+
+	case *AST.ExpressionEnumValue:
+		return v.DeclType
+
+	case *AST.ExpressionUnion:
+		variant := tc.unions[v.Tok.Lexeme].Variants[v.Index]
+		payloadType := tc.typeCheckExpected(v.Payload, variant.DeclType, env)
+		if !TS.TypeCompare(variant.DeclType, payloadType) {
+			tc.reportError(v.Variant, "%s.%s expects a payload of type %s, got %s", v.Tok.Lexeme, variant.Tok.Lexeme, variant.DeclType.String(), payloadType.String())
+		}
+
+		return v.DeclType
+
 	case *AST.ExpressionBinary:
-		lt := typeCheckExpression(v.Left, env)
-		rt := typeCheckExpression(v.Right, env)
+		lt, rt := tc.typeCheckOperands(v.Left, v.Right, env)
+		if lt.IsTypeParameter() || rt.IsTypeParameter() {
+			return tc.typeCheckParameterOperation(v.Operator, lt, rt)
+		}
 
 		promotedType := TS.GetPromotedType(v.Operator, lt, rt)
-		if promotedType == TS.INVALID_TYPE {
-			panic(fmt.Sprintf("Typechecking error Line %d | Operation %s not supported on Left: %s | Right: %s", v.Operator.Line, v.Operator.Lexeme, lt.String(), rt.String()))
+		if promotedType == TS.INVALID_TYPE || ((lt.IsPointer() || lt.IsStruct() || (lt.IsEnum() && rt.IsEnum())) && !TS.TypeCompare(lt, rt)) {
+			tc.reportError(v.Operator, "Operation %s not supported on Left: %s | Right: %s", v.Operator.Lexeme, lt.String(), rt.String())
+			return errorType()
+		}
+
+		if lt.IsStruct() {
+			if member, memberType, ok := tc.incomparableMember(lt, map[string]bool{}); ok {
+				tc.reportError(v.Operator, "Operation %s not supported on %s, member %s of type %s can't be compared", v.Operator.Lexeme, lt.String(), member, memberType.String())
+				return errorType()
+			}
 		}
 
 		return TS.NewType(promotedType, nil, nil)
 
 	case *AST.SE_FunctionCall:
-		return typeCheckFunctionCall(v, env)
+		return tc.typeCheckFunctionCall(v, env)
+
+	case *AST.SE_MethodCall:
+		return tc.typeCheckMethodCall(v, env)
 
 	case *AST.ExpressionArray:
+		if !tc.checkTypeExists(v.Tok, v.DeclType) {
+			v.DeclType = errorType()
+			return v.DeclType
+		}
+
 		for i, element := range v.Elements {
 			if ref, ok := element.(*AST.ExpressionArray); ok {
 				ref.DeclType = v.DeclType.RemoveArrayModifier()
 			}
 
-			elementType := typeCheckExpression(element, env)
+			elementType := tc.typeCheckExpected(element, v.DeclType.RemoveArrayModifier(), env)
 			if !TS.TypeCompare(elementType, v.DeclType.RemoveArrayModifier()) {
-				panic(fmt.Sprintf("Element %d: expected %s, got %s", i, v.DeclType.RemoveArrayModifier().String(), elementType.String()))
+				tc.reportError(v.Tok, "Element %d: expected %s, got %s", i, v.DeclType.RemoveArrayModifier().String(), elementType.String())
 			}
 		}
 
 		return v.DeclType
 
 	case *AST.ExpressionLen:
-		switch ev := v.Iterable.(type) {
-		case *AST.ExpressionArray:
-		case *AST.ExpressionString:
-		case *AST.ExpressionIdentifier:
-			evType := env.get(ev.Tok)
-			if evType.DeclType.Kind != TS.ARRAY && evType.DeclType.Kind != TS.STRING {
-				panic(fmt.Sprintf("Builtin Len() argument is not iterable"))
-			}
-		default:
-			panic(fmt.Sprintf("Builtin Len() argument is not iterable %T", v.Iterable))
+		iterableType := tc.typeCheckExpression(v.Iterable, env)
+		if !iterableType.IsError() && iterableType.Kind != TS.ARRAY && iterableType.Kind != TS.STRING {
+			tc.reportError(v.Tok, "Builtin len() argument is not iterable, got %s", iterableType.String())
 		}
 
 		return TS.NewType(TS.INTEGER, nil, nil)
 
 	case *AST.ExpressionUnary:
-		return typeCheckExpression(v.Operand, env)
+		var operandType *TS.Type
+		if literal, ok := v.Operand.(*AST.ExpressionInteger); ok && v.Operator.Kind == Token.MINUS {
+			// -128 fits an i8 even though 128 doesn't
+			operandType = tc.integerLiteralType(literal, true)
+		} else {
+			operandType = tc.typeCheckExpression(v.Operand, env)
+		}
+
+		if TS.GetUnaryType(v.Operator, operandType) == TS.INVALID_TYPE {
+			tc.reportError(v.Operator, "Operation %s not supported on %s", v.Operator.Lexeme, operandType.String())
+			return errorType()
+		}
+
+		return operandType
+
+	case *AST.ExpressionNullptr:
+		return TS.NewType(TS.POINTER, nil, nil)
+
+	case *AST.ExpressionTuple:
+		tc.typeCheckTuple(v, nil, env)
+		tc.reportError(v.Tok, "Multiple values can only be returned from a function")
+		return errorType()
+
+	case *AST.ExpressionAddressOf:
+		if name, ok := tc.functionName(v.Operand, env); ok {
+			tc.reportError(v.Tok, "Can't take the address of function %s, it isn't a variable", name)
+			return errorType()
+		}
+
+		operandType := tc.typeCheckExpression(v.Operand, env)
+		switch v.Operand.(type) {
+		case *AST.ExpressionIdentifier, *AST.ExpressionAccessChain, *AST.ExpressionStruct, *AST.ExpressionUnion, *AST.ExpressionArray:
+		default:
+			tc.reportError(v.Tok, "Can't take the address of this expression, only of variables, members, elements and struct, union or array literals")
+			return errorType()
+		}
+
+		if operandType.IsError() {
+			return operandType
+		}
+
+		if chain, ok := v.Operand.(*AST.ExpressionAccessChain); ok && tc.isStringElement(chain, operandType, env) {
+			tc.reportError(v.Tok, "Can't take the address of a character in a string, strings are immutable")
+			return errorType()
+		}
+
+		return operandType.AddPointerModifier()
+
+	case *AST.ExpressionDereference:
+		operandType := tc.typeCheckExpression(v.Operand, env)
+		if operandType.IsError() {
+			return operandType
+		}
+
+		if !operandType.IsPointer() || operandType.IsNullptr() {
+			tc.reportError(v.Tok, "Can't dereference %s, it isn't a pointer", operandType.String())
+			return errorType()
+		}
+
+		return operandType.RemovePointerModifier()
 
 	case *AST.ExpressionGrouping:
-		return typeCheckExpression(v.Expr, env)
+		return tc.typeCheckExpression(v.Expr, env)
 
 	case *AST.ExpressionTypeCast:
-		exprType := typeCheckExpression(v.Expr, env)
+		exprType := tc.typeCheckExpression(v.Expr, env)
+		if !tc.checkTypeExists(v.Tok, v.CastType) {
+			return errorType()
+		}
+
 		if TS.TypeCompare(v.CastType, exprType) {
 			return v.CastType
 		}
 
 		if !TS.CanCastType(v.CastType, exprType) {
-			panic(fmt.Sprintf("Typechecking error Line %d | Invalid cast to %s from %s", v.Tok.Line, v.CastType.String(), exprType.String()))
+			tc.reportError(v.Tok, "Invalid cast to %s from %s", v.CastType.String(), exprType.String())
 		}
 
 		return v.CastType
 
 	case *AST.ExpressionStruct:
-		structDecl, ok := globalStruct[v.Tok.Lexeme]
+		structDecl, ok := tc.structs[v.Tok.Lexeme]
 		if !ok {
-			panic("Undefined type: " + v.Tok.Lexeme)
-		}
-
-		argCount := len(v.MemberValues)
-		memberCount := len(structDecl.Members)
+			tc.reportError(v.Tok, "Undefined type: %s", v.Tok.Lexeme)
+			for _, value := range v.MemberValues {
+				tc.typeCheckExpression(value, env)
+			}
 
-		if memberCount != argCount {
-			panic(fmt.Sprintf("expected %d parameter(s), got %d", argCount, memberCount))
+			return errorType()
 		}
 
-		for i := 0; i < argCount; i++ {
-			member := structDecl.Members[i]
-			argType := typeCheckExpression(v.MemberValues[member.Tok.Lexeme], env)
-
-			if !TS.TypeCompare(member.DeclType, argType) {
-				panic(fmt.Sprintf("Line %d | argument %d: expected %s: %s, got %s", v.Tok.Line, i, member.Tok.Lexeme, member.DeclType.String(), argType.String()))
-			}
+		v.Decl = nil
+		if len(structDecl.TypeParameters) > 0 {
+			return tc.typeCheckGenericStruct(v, structDecl, env)
 		}
 
-		return TS.NewType(TS.STRUCT, TS.NewType(TS.TypeKind(structDecl.Tok.Lexeme), nil, nil), nil)
+		tc.typeCheckMembers(v, structDecl, env)
+		return TS.NewStructType(structDecl.Tok.Lexeme, nil)
 
 	case *AST.ExpressionAccessChain:
-		ident := env.get(v.Tok)
-		decl := globalStruct[ident.DeclType.String()]
-
-		accessType := ident.DeclType
-		accessString := ident.Tok.Lexeme
+		var accessType *TS.Type
+		accessString := v.Tok.Lexeme
+		if v.Base != nil {
+			accessType = tc.typeCheckExpression(v.Base, env)
+			accessString = "(...)"
+			if v.Tok.Kind == Token.IDENTIFIER {
+				accessString = v.Tok.Lexeme + "(...)"
+			}
+		} else {
+			accessType = env.get(v.Tok).DeclType
+		}
+		decl, _ := tc.lookupStruct(accessType)
 
 		for i := 0; i < len(v.AccessKeys); i++ {
 			switch ev := v.AccessKeys[i].(type) {
 			case *AST.ExpressionIdentifier:
 				memberName := ev.Tok
 				accessString += "." + memberName.Lexeme
-				if accessType == nil || !accessType.IsStruct() {
-					panic(fmt.Sprintf("Line: %d | undefined struct access: %s", v.Tok.Line, accessString))
+				if accessType.IsError() {
+					return accessType
 				}
 
-				accessType = decl.MemberLookup[memberName.Lexeme].DeclType
-				decl = globalStruct[accessType.String()]
-
-			case *AST.ExpressionArrayAccess:
-				index, ok := ev.Index.(*AST.ExpressionInteger)
-				if ok {
-					accessString += fmt.Sprintf("[%d]", index.Value)
+				// Members are reached through pointers to structs without an explicit dereference
+				if accessType.IsPointer() && !accessType.IsNullptr() && accessType.Next.IsStruct() {
+					accessType = accessType.RemovePointerModifier()
+					decl, _ = tc.lookupStruct(accessType)
 				}
 
-				identifier, ok := ev.Index.(*AST.ExpressionIdentifier)
-				if ok {
-					if !TS.TypeCompare(typeCheckExpression(identifier, env), TS.NewType(TS.INTEGER, nil, nil)) {
-						panic("Array Index Access is not of type int")
-					}
+				if !accessType.IsStruct() || decl == nil {
+					tc.reportError(memberName, "undefined struct access: %s", accessString)
+					return errorType()
+				}
 
-					accessString += fmt.Sprintf("[%d]", identifier.Tok.Lexeme)
+				member, ok := decl.MemberLookup[memberName.Lexeme]
+				if !ok {
+					tc.reportError(memberName, "%s has no member named %s", decl.Tok.Lexeme, memberName.Lexeme)
+					return errorType()
 				}
 
-				acc, ok := ev.Index.(*AST.ExpressionAccessChain)
-				if ok {
-					if !TS.TypeCompare(typeCheckExpression(acc, env), TS.NewType(TS.INTEGER, nil, nil)) {
-						panic("Array Index Access is not of type int")
-					}
+				accessType = member.DeclType
+				decl, _ = tc.lookupStruct(accessType)
+
+			case *AST.ExpressionArrayAccess:
+				switch index := ev.Index.(type) {
+				case *AST.ExpressionInteger:
+					accessString += fmt.Sprintf("[%d]", index.Value)
+				case *AST.ExpressionIdentifier:
+					accessString += fmt.Sprintf("[%s]", index.Tok.Lexeme)
+				default:
+					accessString += "[...]"
+				}
 
-					accessString += fmt.Sprintf("[...]")
+				indexType := tc.typeCheckExpression(ev.Index, env)
+				if !TS.TypeCompare(indexType, TS.NewType(TS.INTEGER, nil, nil)) {
+					tc.reportError(ev.Tok, "Array index of %s is not of type int, got %s", accessString, indexType.String())
 				}
 
-				cast, ok := ev.Index.(*AST.ExpressionTypeCast)
-				if ok {
-					ct := typeCheckExpression(cast, env)
-					if !TS.TypeCompare(ct, TS.NewType(TS.INTEGER, nil, nil)) {
-						panic("Array Index Access is not of type int")
-					}
+				if accessType.IsError() {
+					return accessType
+				}
 
-					accessString += fmt.Sprintf("[...]")
+				if accessType.Kind == TS.STRING {
+					accessType = TS.NewType(TS.CHAR, nil, nil)
+					decl = nil
+					continue
 				}
 
-				if accessType.IsArray() {
-					accessType = accessType.RemoveArrayModifier()
-					decl = globalStruct[accessType.String()]
-				} else {
-					panic(fmt.Sprintf("Line: %d | undefined array access: %s", v.Tok.Line, accessString))
+				if !accessType.IsArray() {
+					tc.reportError(ev.Tok, "undefined array access: %s", accessString)
+					return errorType()
 				}
+
+				accessType = accessType.RemoveArrayModifier()
+				decl, _ = tc.lookupStruct(accessType)
 			}
 		}
 
 		return accessType
 
 	default:
-		panic(fmt.Sprintf("undefined statement: %T", v))
+		panic(fmt.Sprintf("undefined expression: %T", v))
+	}
+}
+
+// typeCheckTuple checks the elements against the elements of expected when it's a tuple type
+func (tc *checker) typeCheckTuple(tuple *AST.ExpressionTuple, expected *TS.Type, env *TypeEnv) *TS.Type {
+	elements := make([]*TS.Type, len(tuple.Elements))
+	for i, element := range tuple.Elements {
+		var expectedElement *TS.Type
+		if expected != nil && expected.IsTuple() && i < len(expected.Parameters) {
+			expectedElement = expected.Parameters[i].DeclType
+		}
+
+		elements[i] = tc.typeCheckExpected(element, expectedElement, env)
+		if elements[i].IsTuple() {
+			tc.reportError(tuple.Tok, "Multiple values can't be nested")
+			elements[i] = errorType()
+		}
+	}
+
+	return TS.NewTupleType(elements)
+}
+
+// caseKey is how a case value reads in diagnostics, equal values have the same key
+func caseKey(e AST.Expression) (string, bool) {
+	switch v := e.(type) {
+	case *AST.ExpressionInteger:
+		return strconv.FormatUint(v.Value, 10), true
+	case *AST.ExpressionUnary:
+		if literal, ok := v.Operand.(*AST.ExpressionInteger); ok && v.Operator.Kind == Token.MINUS {
+			if literal.Value == 0 {
+				return "0", true
+			}
+
+			return "-" + strconv.FormatUint(literal.Value, 10), true
+		}
+	case *AST.ExpressionChar:
+		return strconv.QuoteRune(v.Value), true
+	case *AST.ExpressionString:
+		return strconv.Quote(v.Value), true
+	case *AST.ExpressionEnumValue:
+		return v.Tok.Lexeme + "." + v.Variant.Lexeme, true
+	}
+
+	return "", false
+}
+
+// missingVariants lists the variants of an enum or union that no case handles, in declaration
+// order, seen is keyed by Type.Variant
+func missingVariants(t *TS.Type, seen map[string]Token.Token) []string {
+	var missing []string
+	for _, variant := range t.Variants {
+		if _, ok := seen[t.String()+"."+variant]; !ok {
+			missing = append(missing, variant)
+		}
+	}
+
+	return missing
+}
+
+func (tc *checker) typeCheckSwitch(v *AST.StatementSwitch, env *TypeEnv) {
+	valueType := tc.typeCheckExpression(v.Value, env)
+	if !valueType.IsError() && !TS.IsInteger(valueType.Kind) && valueType.Kind != TS.CHAR && valueType.Kind != TS.STRING && !valueType.IsEnum() {
+		tc.reportError(v.Tok, "Switch value has to be an integer, char, string or enum, got %s", valueType.String())
+		valueType = errorType()
+	}
+
+	seen := make(map[string]Token.Token)
+	for _, switchCase := range v.Cases {
+		for _, value := range switchCase.Values {
+			caseType := tc.typeCheckExpected(value, valueType, env)
+			key, ok := caseKey(value)
+			if !ok {
+				tc.reportError(switchCase.Tok, "Case values have to be literals")
+				continue
+			}
+
+			if !TS.TypeCompare(valueType, caseType) {
+				tc.reportError(switchCase.Tok, "Case value %s of type %s doesn't match the switch value of type %s", key, caseType.String(), valueType.String())
+			} else if previous, ok := seen[key]; ok {
+				tc.reportError(switchCase.Tok, "Duplicate case %s, it's already handled on line %d", key, previous.Line)
+			} else {
+				seen[key] = switchCase.Tok
+			}
+		}
+
+		tc.typeCheckStatement(switchCase.Body, env)
+	}
+
+	if v.Default != nil {
+		tc.typeCheckStatement(v.Default, env)
+	} else if valueType.IsEnum() {
+		// without a default every variant needs a case, so adding one flags every switch to update
+		if missing := missingVariants(valueType, seen); len(missing) > 0 {
+			tc.reportError(v.Tok, "Switch on %s doesn't handle %s, add a case for each or a default", valueType.String(), strings.Join(missing, ", "))
+		}
+	}
+}
+
+func (tc *checker) typeCheckMatch(v *AST.StatementMatch, env *TypeEnv) {
+	valueType := tc.typeCheckExpression(v.Value, env)
+	var decl *AST.DeclarationUnion
+	if valueType.IsUnion() {
+		decl = tc.unions[valueType.String()]
+	} else if !valueType.IsError() {
+		tc.reportError(v.Tok, "Match value has to be a union, got %s", valueType.String())
+	}
+
+	seen := make(map[string]Token.Token)
+	for _, matchCase := range v.Cases {
+		caseEnv := NewTypeEnv(env)
+		payloadType := errorType()
+		matchCase.Index = -1
+
+		if decl != nil {
+			for i, variant := range decl.Variants {
+				if variant.Tok.Lexeme == matchCase.Variant.Lexeme {
+					matchCase.Index = i
+					payloadType = variant.DeclType
+					break
+				}
+			}
+
+			key := decl.Tok.Lexeme + "." + matchCase.Variant.Lexeme
+			if matchCase.Index == -1 {
+				tc.reportError(matchCase.Variant, "%s has no variant named %s", decl.Tok.Lexeme, matchCase.Variant.Lexeme)
+			} else if previous, ok := seen[key]; ok {
+				tc.reportError(matchCase.Tok, "Duplicate case %s, it's already handled on line %d", matchCase.Variant.Lexeme, previous.Line)
+			} else {
+				seen[key] = matchCase.Tok
+			}
+		}
+
+		if matchCase.Binding != nil {
+			caseEnv.set(*matchCase.Binding, &AST.DeclarationVariable{
+				Tok:      *matchCase.Binding,
+				DeclType: payloadType,
+			})
+		}
+
+		tc.typeCheckStatement(matchCase.Body, caseEnv)
+	}
+
+	if v.Default != nil {
+		tc.typeCheckStatement(v.Default, env)
+	} else if decl != nil {
+		if missing := missingVariants(valueType, seen); len(missing) > 0 {
+			tc.reportError(v.Tok, "Match on %s doesn't handle %s, add a case for each or a default", valueType.String(), strings.Join(missing, ", "))
+		}
 	}
+}
 
-	return TS.NewType(TS.INVALID_TYPE, nil, nil)
+func (tc *checker) checkCondition(tok Token.Token, statement string, condition *TS.Type) {
+	if !condition.IsError() && condition.Kind != TS.BOOL {
+		tc.reportError(tok, "%s statement condition doesn't resolve to a bool it resolves to: %s", statement, condition.String())
+	}
 }
 
-func typeCheckStatement(s AST.Statement, env *TypeEnv) {
+func (tc *checker) typeCheckStatement(s AST.Statement, env *TypeEnv) {
 	switch v := s.(type) {
 	case *AST.StatementAssignment:
-		lhsType := typeCheckExpression(v.LHS, env)
-		rhsType := typeCheckExpression(v.RHS, env)
+		if name, ok := tc.functionName(v.LHS, env); ok {
+			tc.reportError(v.Tok, "Can't assign to function %s, it isn't a variable", name)
+			tc.typeCheckExpression(v.RHS, env)
+			return
+		}
+
+		lhsType := tc.typeCheckExpression(v.LHS, env)
+		expected := lhsType
+		if v.Operator != nil {
+			expected = operandType(lhsType)
+		}
+
+		rhsType := tc.typeCheckExpected(v.RHS, expected, env)
+
+		if chain, ok := v.LHS.(*AST.ExpressionAccessChain); ok && tc.isStringElement(chain, lhsType, env) {
+			tc.reportError(v.Tok, "Can't assign to a character of a string, strings are immutable")
+			return
+		}
+
+		if v.Increment && !lhsType.IsError() && !TS.IsNumeric(lhsType.Kind) {
+			tc.reportError(*v.Operator, "Operation %s%s not supported on %s", v.Operator.Lexeme, v.Operator.Lexeme, lhsType.String())
+			return
+		}
+
+		if v.Operator != nil && (lhsType.IsTypeParameter() || rhsType.IsTypeParameter()) {
+			if rhsType = tc.typeCheckParameterOperation(*v.Operator, lhsType, rhsType); rhsType.IsError() {
+				return
+			}
+		} else if v.Operator != nil {
+			// x op= y is checked like x = x op y
+			promotedType := TS.GetPromotedType(*v.Operator, lhsType, rhsType)
+			if promotedType == TS.INVALID_TYPE {
+				tc.reportError(*v.Operator, "Operation %s= not supported on Left: %s | Right: %s", v.Operator.Lexeme, lhsType.String(), rhsType.String())
+				return
+			}
+
+			if promotedType == TS.ERROR {
+				return
+			}
+
+			rhsType = TS.NewType(promotedType, nil, nil)
+		}
 
 		if !TS.TypeCompare(lhsType, rhsType) {
-			panic(fmt.Sprintf("Line %d | Can't assign type %s to type %s", v.Tok.Line, rhsType.String(), lhsType.String()))
+			tc.reportError(v.Tok, "Can't assign type %s to type %s", rhsType.String(), lhsType.String())
 		}
 
 	case *AST.StatementPrint:
-		typeCheckExpression(v.Expr, env)
+		tc.typeCheckExpression(v.Expr, env)
 
 	case *AST.StatementReturn:
-		if v.Expr != nil {
-			globalReturnStatementStack = append(globalReturnStatementStack,
+		if tuple, ok := v.Expr.(*AST.ExpressionTuple); ok {
+			tc.returnStatementStack = append(tc.returnStatementStack,
+				StatementTypePair{
+					stmt: v,
+					t:    tc.typeCheckTuple(tuple, tc.returnType, env),
+				},
+			)
+		} else if v.Expr != nil {
+			tc.returnStatementStack = append(tc.returnStatementStack,
 				StatementTypePair{
 					stmt: v,
-					t:    typeCheckExpression(v.Expr, env),
+					t:    tc.typeCheckExpected(v.Expr, tc.returnType, env),
 				},
 			)
 		}
 
-	case *AST.StatementBreak, *AST.StatementContinue:
+	case *AST.StatementBreak:
 		if env.CurrentStatus != IN_LOOP {
-			panic("break statement is not in loop")
+			tc.reportError(v.Tok, "break statement is not in loop")
 		}
 
-	case *AST.StatementFor:
-		typeCheckDeclaration(v.Initializer, env)
-		condition := typeCheckExpression(v.Condition, env)
-		if condition.Kind != TS.BOOL {
-			panic("For statement condition doesn't resolve to a bool it resolves to: " + condition.String())
+	case *AST.StatementContinue:
+		if env.CurrentStatus != IN_LOOP {
+			tc.reportError(v.Tok, "continue statement is not in loop")
 		}
 
-		typeCheckStatement(v.Increment, env)
+	case *AST.StatementFor:
+		forEnv := NewTypeEnv(env)
+		tc.typeCheckDeclaration(v.Initializer, forEnv)
+		tc.checkCondition(v.Tok, "For", tc.typeCheckExpression(v.Condition, forEnv))
+		tc.typeCheckStatement(v.Increment, forEnv)
 
-		env.CurrentStatus = IN_LOOP
-		for _, node := range v.Block.Body {
-			typeCheckNode(node, env)
-		}
-		env.CurrentStatus = NORMAL
+		forEnv.CurrentStatus = IN_LOOP
+		tc.typeCheckStatement(v.Block, forEnv)
 
 	case *AST.StatementWhile:
-		condition := typeCheckExpression(v.Condition, env)
-		if condition.Kind != TS.BOOL {
-			panic("For statement condition doesn't resolve to a bool it resolves to: " + condition.String())
-		}
+		tc.checkCondition(v.Tok, "While", tc.typeCheckExpression(v.Condition, env))
 
-		env.CurrentStatus = IN_LOOP
-		typeCheckStatement(v.Block, env)
-		env.CurrentStatus = NORMAL
+		whileEnv := NewTypeEnv(env)
+		whileEnv.CurrentStatus = IN_LOOP
+		tc.typeCheckStatement(v.Block, whileEnv)
 
 	case *AST.StatementIfElse:
-		condition := typeCheckExpression(v.Condition, env)
-		if condition.Kind != TS.BOOL {
-			panic("For statement condition doesn't resolve to a bool it resolves to: " + condition.String())
-		}
+		tc.checkCondition(v.Tok, "If", tc.typeCheckExpression(v.Condition, env))
 
-		typeCheckStatement(v.IfBlock, env)
+		tc.typeCheckStatement(v.IfBlock, env)
 
 		if v.ElseBlock != nil {
-			typeCheckStatement(v.ElseBlock, env)
+			tc.typeCheckStatement(v.ElseBlock, env)
 		}
 
+	case *AST.StatementSwitch:
+		tc.typeCheckSwitch(v, env)
+
+	case *AST.StatementMatch:
+		tc.typeCheckMatch(v, env)
+
 	case *AST.StatementDefer:
-		typeCheckNode(v.DeferredNode.(AST.Node), env)
+		tc.typeCheckNode(v.DeferredNode.(AST.Node), env)
 
 	case *AST.StatementBlock:
+		blockEnv := NewTypeEnv(env)
 		for _, node := range v.Body {
-			typeCheckNode(node, env)
+			tc.typeCheckNode(node, blockEnv)
 		}
 
 	case *AST.SE_FunctionCall:
-		typeCheckFunctionCall(v, env)
+		tc.typeCheckFunctionCall(v, env)
+
+	case *AST.SE_MethodCall:
+		tc.typeCheckMethodCall(v, env)
+
+	case *AST.StatementError:
+		// Already reported by the parser
 
 	default:
 		panic(fmt.Sprintf("undefined statement: %T", v))
@@ -296,83 +1569,184 @@ func typeCheckStatement(s AST.Statement, env *TypeEnv) {
 	}
 }
 
-func typeCheckDeclaration(decl AST.Declaration, env *TypeEnv) {
+// checkMainSignature only allows the entry points the Interpreter knows how to call:
+// fn main([args: []string]) -> void | int
+func (tc *checker) checkMainSignature(v *AST.DeclarationFunction) {
+	if len(v.TypeParameters) > 0 {
+		tc.reportError(v.Tok, "main() can't have type parameters")
+	}
+
+	params := v.DeclType.Parameters
+	stringSlice := TS.NewType(TS.STRING, nil, nil).AddArrayModifier()
+	if len(params) > 1 || (len(params) == 1 && !TS.TypeCompare(params[0].DeclType, stringSlice)) {
+		tc.reportError(v.Tok, "main() must take no parameters or a single []string parameter")
+	}
+
+	returnKind := v.DeclType.GetReturnType().Kind
+	if returnKind != TS.VOID && returnKind != TS.INTEGER && returnKind != TS.ERROR {
+		tc.reportError(v.Tok, "main() must return void or int, got %s", v.DeclType.GetReturnType().String())
+	}
+}
+
+func (tc *checker) typeCheckDeclaration(decl AST.Declaration, env *TypeEnv) {
 	switch v := decl.(type) {
 	case *AST.DeclarationVariable:
-		rhsType := typeCheckExpression(v.RHS, env)
+		if !tc.checkTypeExists(v.Tok, v.DeclType) {
+			v.DeclType = errorType()
+		}
+
+		rhsType := tc.typeCheckExpected(v.RHS, v.DeclType, env)
 		if v.DeclType == nil || v.DeclType.Kind == TS.INVALID_TYPE {
 			v.DeclType = rhsType
+			if rhsType.IsNullptr() {
+				tc.reportError(v.Tok, "Can't infer the type of %s from nullptr, declare it as var %s: *T = nullptr", v.Tok.Lexeme, v.Tok.Lexeme)
+				v.DeclType = errorType()
+			}
+
+			if rhsType.IsTuple() {
+				tc.reportError(v.Tok, "Can't assign %d values to %s, declare one variable per value with var a, b := ...", len(rhsType.Parameters), v.Tok.Lexeme)
+				v.DeclType = errorType()
+			}
 		}
 
 		env.set(v.Tok, v)
 
 		if !TS.TypeCompare(v.DeclType, rhsType) {
-			panic(fmt.Sprintf("Line: %d | Can't assign type %s to type %s", v.Tok.Line, rhsType.String(), v.DeclType.String()))
+			tc.reportError(v.Tok, "Can't assign type %s to type %s", rhsType.String(), v.DeclType.String())
+		}
+
+	case *AST.DeclarationDestructure:
+		rhsType := tc.typeCheckExpression(v.RHS, env)
+		if !rhsType.IsError() && !rhsType.IsTuple() {
+			tc.reportError(v.Tok, "Expected %d values to destructure, got a single %s", len(v.Variables), rhsType.String())
+		} else if rhsType.IsTuple() && len(rhsType.Parameters) != len(v.Variables) {
+			tc.reportError(v.Tok, "Expected %d values to destructure, got %d", len(v.Variables), len(rhsType.Parameters))
+		}
+
+		for i, variable := range v.Variables {
+			variable.DeclType = errorType()
+			if rhsType.IsTuple() && i < len(rhsType.Parameters) {
+				variable.DeclType = rhsType.Parameters[i].DeclType
+			}
+
+			env.set(variable.Tok, variable)
 		}
 
 	case *AST.DeclarationFunction:
-		if _, ok := globalFunctions[v.Tok.Lexeme]; ok {
-			panic("Attempting to redeclare function " + v.Tok.Lexeme)
+		if v.Receiver != nil {
+			receiver := &v.DeclType.Parameters[0]
+			if !tc.checkTypeExists(receiver.Tok, receiver.DeclType) {
+				receiver.DeclType = errorType()
+			}
+			tc.declareMethod(v)
+		} else if _, ok := tc.functions[v.Tok.Lexeme]; ok {
+			tc.reportError(v.Tok, "Attempting to redeclare function %s", v.Tok.Lexeme)
+		} else if _, ok := tc.natives.Lookup(v.Tok.Lexeme); ok {
+			tc.reportError(v.Tok, "Attempting to redeclare native function %s", v.Tok.Lexeme)
 		} else {
-			globalFunctions[v.Tok.Lexeme] = v
+			tc.functions[v.Tok.Lexeme] = v
 		}
 
-		_, ok := v.Block.Body[len(v.Block.Body)-1].(*AST.StatementReturn)
-		if !ok && v.DeclType.GetReturnType().Kind != TS.VOID {
-			panic(fmt.Sprintf("%s() body is missing a return statement or it is not the last statement in the body", v.Tok.Lexeme))
+		if v.Receiver == nil && v.Tok.Lexeme == "main" && env.parent == nil {
+			tc.checkMainSignature(v)
 		}
 
-		funcEnv := NewTypeEnv(env)
-		for _, param := range v.DeclType.Parameters {
-			funcEnv.set(param.Tok, &AST.DeclarationVariable{
-				Tok:      param.Tok,
-				DeclType: param.DeclType,
-			})
+		tc.typeCheckFunctionBody(v, env)
+
+	case *AST.DeclarationStruct:
+		if tc.typeDeclared(v.Tok.Lexeme) {
+			tc.reportError(v.Tok, "Attempting to redeclare type: %s", v.Tok.Lexeme)
+		} else {
+			tc.structs[v.Tok.Lexeme] = v
 		}
 
-		for _, node := range v.Block.Body {
-			typeCheckNode(node, funcEnv)
-			for _, pair := range globalReturnStatementStack {
-				if v.DeclType.GetReturnType().Kind == TS.VOID {
-					panic(fmt.Sprintf("Attempting to return expression in %s() with return type void", v.Tok.Lexeme))
-				}
+		v.Methods = make(map[string]*AST.DeclarationFunction)
+		for i, member := range v.Members {
+			if !tc.checkTypeExists(member.Tok, member.DeclType) {
+				member.DeclType = errorType()
+				v.Members[i] = member
+				v.MemberLookup[member.Tok.Lexeme] = member
+			}
+		}
 
-				if !TS.TypeCompare(v.DeclType.GetReturnType(), pair.t) {
-					panic(fmt.Sprintf("Line %d | %s() has a return type of %s but returns a %s", pair.stmt.Tok.Line, v.Tok.Lexeme, v.DeclType.GetReturnType().String(), pair.t.String()))
-				}
+	case *AST.DeclarationEnum:
+		if tc.typeDeclared(v.Tok.Lexeme) {
+			tc.reportError(v.Tok, "Attempting to redeclare type: %s", v.Tok.Lexeme)
+		} else {
+			tc.enums[v.Tok.Lexeme] = v
+		}
+
+		if len(v.Variants) == 0 {
+			tc.reportError(v.Tok, "Enum %s has no variants", v.Tok.Lexeme)
+		}
+
+		declared := make(map[string]bool)
+		for _, variant := range v.Variants {
+			if declared[variant.Lexeme] {
+				tc.reportError(variant, "Enum %s already has a variant named %s", v.Tok.Lexeme, variant.Lexeme)
 			}
-			globalReturnStatementStack = nil
+			declared[variant.Lexeme] = true
 		}
 
-	case *AST.DeclarationStruct:
-		if _, ok := globalStruct[v.Tok.Lexeme]; ok {
-			panic("Attempting to redeclare type: " + v.Tok.Lexeme)
+	case *AST.DeclarationUnion:
+		if tc.typeDeclared(v.Tok.Lexeme) {
+			tc.reportError(v.Tok, "Attempting to redeclare type: %s", v.Tok.Lexeme)
 		} else {
-			globalStruct[v.Tok.Lexeme] = v
+			tc.unions[v.Tok.Lexeme] = v
+		}
+
+		if len(v.Variants) == 0 {
+			tc.reportError(v.Tok, "Union %s has no variants", v.Tok.Lexeme)
 		}
 
+		declared := make(map[string]bool)
+		for i, variant := range v.Variants {
+			if declared[variant.Tok.Lexeme] {
+				tc.reportError(variant.Tok, "Union %s already has a variant named %s", v.Tok.Lexeme, variant.Tok.Lexeme)
+			}
+			declared[variant.Tok.Lexeme] = true
+
+			if !tc.checkTypeExists(variant.Tok, variant.DeclType) {
+				variant.DeclType = errorType()
+				v.Variants[i] = variant
+			}
+		}
+
+	case *AST.DeclarationError:
+		// Already reported by the parser
+
 	default:
 		panic(fmt.Sprintf("undefined declaration: %T", v))
 	}
 }
 
-func typeCheckNode(node AST.Node, env *TypeEnv) {
+func (tc *checker) typeCheckNode(node AST.Node, env *TypeEnv) {
 	switch v := node.(type) {
 	case AST.Statement:
-		typeCheckStatement(v, env)
+		tc.typeCheckStatement(v, env)
 	case AST.Expression:
-		typeCheckExpression(v, env)
+		tc.typeCheckExpression(v, env)
 	case AST.Declaration:
-		typeCheckDeclaration(v, env)
+		tc.typeCheckDeclaration(v, env)
 	}
 }
 
-func TypeCheckProgram(program AST.Program) {
-	globalEnv := NewTypeEnv(nil)
-	globalFunctions = make(map[string]*AST.DeclarationFunction)
-	globalStruct = make(map[string]*AST.DeclarationStruct)
+// TypeCheckProgram checks the whole program and returns every type error it finds,
+// an empty result means the program is safe to hand to the Interpreter. Calls to natives,
+// which may be nil, are checked against their registered signatures.
+func TypeCheckProgram(program AST.Program, natives *Runtime.Natives) []Diagnostic.Diagnostic {
+	tc := &checker{
+		functions: make(map[string]*AST.DeclarationFunction),
+		natives:   natives,
+		structs:   make(map[string]*AST.DeclarationStruct),
+		enums:     make(map[string]*AST.DeclarationEnum),
+		unions:    make(map[string]*AST.DeclarationUnion),
+	}
 
+	globalEnv := newRootEnv(tc)
 	for _, decl := range program.Declarations {
-		typeCheckDeclaration(decl, globalEnv)
+		tc.typeCheckDeclaration(decl, globalEnv)
 	}
+
+	return tc.diagnostics
 }
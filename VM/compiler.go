@@ -0,0 +1,1177 @@
+package VM
+
+import (
+	"fmt"
+	"ion-go/AST"
+	"ion-go/Runtime"
+	"ion-go/TS"
+	"ion-go/Token"
+)
+
+// block mirrors an Interpreter scope, defers counts the defer statements compiled so far
+// in this block, all of them have run by the time control reaches any later point of it.
+type block struct {
+	locals    map[string]int
+	firstSlot int
+	defers    int
+}
+
+type loop struct {
+	blockDepth int
+	breaks     []int
+	continues  []int
+}
+
+// deferBody is the code of a deferred statement, a return, break or continue inside it
+// only ends the deferred statement just like in the Interpreter.
+type deferBody struct {
+	blockDepth int
+	exits      []int
+}
+
+// functionState is the function being compiled. Locals named in boxed live in a Cell and
+// their slot holds a pointer to it, so &local stays valid after the function returns.
+// escaped collects the locals whose address is taken but weren't boxed, the function is
+// compiled again with those boxed.
+//
+// An anonymous function is compiled inside its enclosing function's state, the variables it
+// uses from there are captured: they're boxed in the enclosing function and the closure gets
+// the pointers to their cells.
+type functionState struct {
+	function  *Function
+	blocks    []*block
+	loops     []*loop
+	deferred  []*deferBody
+	nextSlot  int
+	boxed     map[string]bool
+	escaped   map[string]bool
+	enclosing *functionState
+	captures  map[string]int
+}
+
+// nullptrKey is the constant key of nullptr, it can't collide with a literal's
+type nullptrKey struct{}
+
+// functionKey is the constant key of a named function used as a value
+type functionKey int
+
+type Compiler struct {
+	program   *Program
+	functions map[string]int
+	literals  map[*AST.ExpressionFunction]int
+	natives   map[string]int
+	structs   map[string]int
+	globals   map[string]int
+	constants map[interface{}]int
+	state     *functionState
+	position  Position
+}
+
+var binaryOpcodes = map[Token.TokenType]Opcode{
+	Token.PLUS:                OP_ADD,
+	Token.MINUS:               OP_SUBTRACT,
+	Token.STAR:                OP_MULTIPLY,
+	Token.DIVISION:            OP_DIVIDE,
+	Token.MODULUS:             OP_MODULO,
+	Token.EQUALS_EQUALS:       OP_EQUAL,
+	Token.NOT_EQUALS:          OP_NOT_EQUAL,
+	Token.LESS_THAN:           OP_LESS,
+	Token.LESS_THAN_EQUALS:    OP_LESS_EQUAL,
+	Token.GREATER_THAN:        OP_GREATER,
+	Token.GREATER_THAN_EQUALS: OP_GREATER_EQUAL,
+	Token.AMPERSAND:           OP_BIT_AND,
+	Token.PIPE:                OP_BIT_OR,
+	Token.CARET:               OP_BIT_XOR,
+	Token.LEFT_SHIFT:          OP_SHIFT_LEFT,
+	Token.RIGHT_SHIFT:         OP_SHIFT_RIGHT,
+}
+
+var unaryOpcodes = map[Token.TokenType]Opcode{
+	Token.MINUS: OP_NEGATE,
+	Token.NOT:   OP_NOT,
+	Token.TILDE: OP_BIT_NOT,
+}
+
+// at sets the source position of the instructions emitted next, the Interpreter reports
+// runtime errors at the same tokens so both engines agree on where an error happened
+func (c *Compiler) at(tok Token.Token) {
+	c.position = Position{Line: tok.Line, Column: tok.Column}
+}
+
+func (c *Compiler) code() []byte {
+	return c.state.function.Code
+}
+
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+	function := c.state.function
+	offset := len(function.Code)
+
+	function.Code = append(function.Code, byte(op))
+	function.Positions = append(function.Positions, c.position)
+	for _, operand := range operands {
+		if operand < 0 || operand > 0xFFFF {
+			panic(fmt.Sprintf("%s: operand %d doesn't fit in 16 bits", function.Name, operand))
+		}
+
+		function.Code = append(function.Code, byte(operand>>8), byte(operand))
+		function.Positions = append(function.Positions, c.position, c.position)
+	}
+
+	return offset
+}
+
+// emitJump emits a jump with a placeholder target and returns its offset for patchJump
+func (c *Compiler) emitJump(op Opcode) int {
+	return c.emit(op, 0)
+}
+
+func (c *Compiler) patchJump(offset int) {
+	target := len(c.code())
+	if target > 0xFFFF {
+		panic(fmt.Sprintf("%s: function is too large to jump in", c.state.function.Name))
+	}
+
+	c.code()[offset+1] = byte(target >> 8)
+	c.code()[offset+2] = byte(target)
+}
+
+func (c *Compiler) addConstant(value Runtime.Value, key interface{}) int {
+	if index, ok := c.constants[key]; ok {
+		return index
+	}
+
+	c.state.function.Constants = append(c.state.function.Constants, value)
+	index := len(c.state.function.Constants) - 1
+	c.constants[key] = index
+
+	return index
+}
+
+// literalKey tells number literals of different kinds apart in the constant table
+type literalKey struct {
+	kind  TS.TypeKind
+	value interface{}
+}
+
+func (c *Compiler) emitConstant(value Runtime.Value, key interface{}) {
+	c.emit(OP_CONSTANT, c.addConstant(value, key))
+}
+
+func (c *Compiler) addType(t *TS.Type) int {
+	for i, existing := range c.program.Types {
+		if existing == t {
+			return i
+		}
+	}
+
+	c.program.Types = append(c.program.Types, t)
+	return len(c.program.Types) - 1
+}
+
+func (c *Compiler) beginBlock() {
+	c.state.blocks = append(c.state.blocks, &block{
+		locals:    make(map[string]int),
+		firstSlot: c.state.nextSlot,
+	})
+}
+
+func (c *Compiler) endBlock() {
+	current := c.state.blocks[len(c.state.blocks)-1]
+	if current.defers > 0 {
+		c.emit(OP_RUN_DEFERS, current.defers)
+	}
+
+	c.state.nextSlot = current.firstSlot
+	c.state.blocks = c.state.blocks[:len(c.state.blocks)-1]
+}
+
+// pendingDefers counts the defers that have to run when leaving every block from depth onwards
+func (c *Compiler) pendingDefers(depth int) int {
+	count := 0
+	for _, b := range c.state.blocks[depth:] {
+		count += b.defers
+	}
+
+	return count
+}
+
+func (c *Compiler) emitRunDefers(depth int) {
+	if count := c.pendingDefers(depth); count > 0 {
+		c.emit(OP_RUN_DEFERS, count)
+	}
+}
+
+func (c *Compiler) declareLocal(name string) int {
+	slot := c.state.nextSlot
+	c.state.nextSlot += 1
+	if c.state.nextSlot > c.state.function.NumLocals {
+		c.state.function.NumLocals = c.state.nextSlot
+	}
+
+	c.state.blocks[len(c.state.blocks)-1].locals[name] = slot
+	return slot
+}
+
+func (c *Compiler) isGlobalScope() bool {
+	return c.state.function == c.program.Init
+}
+
+func (c *Compiler) resolveLocal(name string) (int, bool) {
+	return c.state.resolveLocal(name)
+}
+
+func (s *functionState) resolveLocal(name string) (int, bool) {
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if slot, ok := s.blocks[i].locals[name]; ok {
+			return slot, true
+		}
+	}
+
+	return -1, false
+}
+
+// resolveCapture finds name in the functions enclosing s, capturing it in every anonymous
+// function in between
+func (s *functionState) resolveCapture(name string) (int, bool) {
+	if index, ok := s.captures[name]; ok {
+		return index, true
+	}
+
+	if s.enclosing == nil {
+		return -1, false
+	}
+
+	if _, ok := s.enclosing.resolveLocal(name); ok {
+		if !s.enclosing.boxed[name] {
+			s.enclosing.escaped[name] = true
+		}
+	} else if _, ok := s.enclosing.resolveCapture(name); !ok {
+		return -1, false
+	}
+
+	s.captures[name] = len(s.function.Captures)
+	s.function.Captures = append(s.function.Captures, name)
+	return s.captures[name], true
+}
+
+func (c *Compiler) emitGetVariable(tok Token.Token) {
+	if slot, ok := c.resolveLocal(tok.Lexeme); ok {
+		c.emit(OP_GET_LOCAL, slot)
+		if c.state.boxed[tok.Lexeme] {
+			c.emit(OP_DEREFERENCE)
+		}
+	} else if capture, ok := c.state.resolveCapture(tok.Lexeme); ok {
+		c.emit(OP_GET_CAPTURE, capture)
+		c.emit(OP_DEREFERENCE)
+	} else if global, ok := c.globals[tok.Lexeme]; ok {
+		c.emit(OP_GET_GLOBAL, global)
+	} else if index, ok := c.functions[tok.Lexeme]; ok {
+		function := c.program.Functions[index]
+		value := &Runtime.ValueFunction{Decl: function.Decl, Closure: &closure{function: function}}
+		c.emitConstant(value, functionKey(index))
+	} else {
+		panic(fmt.Sprintf("Line: %d | Undeclared Identifier: %s", tok.Line, tok.Lexeme))
+	}
+}
+
+func (c *Compiler) emitSetVariable(tok Token.Token) {
+	if slot, ok := c.resolveLocal(tok.Lexeme); ok && c.state.boxed[tok.Lexeme] {
+		c.emit(OP_GET_LOCAL, slot)
+		c.emit(OP_STORE)
+	} else if ok {
+		c.emit(OP_SET_LOCAL, slot)
+	} else if capture, ok := c.state.resolveCapture(tok.Lexeme); ok {
+		c.emit(OP_GET_CAPTURE, capture)
+		c.emit(OP_STORE)
+	} else if global, ok := c.globals[tok.Lexeme]; ok {
+		c.emit(OP_SET_GLOBAL, global)
+	} else {
+		panic(fmt.Sprintf("Line %d | Attempting to assign to undeclared identifier: %s", tok.Line, tok.Lexeme))
+	}
+}
+
+// emitDeclareLocal stores the value on top of the stack in a new local
+func (c *Compiler) emitDeclareLocal(name string) {
+	if c.state.boxed[name] {
+		c.emit(OP_BOX)
+	}
+
+	c.emit(OP_SET_LOCAL, c.declareLocal(name))
+}
+
+func (c *Compiler) emitAddressOfVariable(tok Token.Token) {
+	if slot, ok := c.resolveLocal(tok.Lexeme); ok {
+		if !c.state.boxed[tok.Lexeme] {
+			c.state.escaped[tok.Lexeme] = true
+		}
+
+		c.emit(OP_GET_LOCAL, slot)
+	} else if capture, ok := c.state.resolveCapture(tok.Lexeme); ok {
+		c.emit(OP_GET_CAPTURE, capture)
+	} else if global, ok := c.globals[tok.Lexeme]; ok {
+		c.emit(OP_ADDRESS_GLOBAL, global)
+	} else {
+		panic(fmt.Sprintf("Line: %d | Undeclared Identifier: %s", tok.Line, tok.Lexeme))
+	}
+}
+
+// compileAddress mirrors the Interpreter's address, variables and everything reached from
+// them are pointed to in place while literals get storage of their own
+func (c *Compiler) compileAddress(e AST.Expression) {
+	switch v := e.(type) {
+	case *AST.ExpressionIdentifier:
+		c.emitAddressOfVariable(v.Tok)
+
+	case *AST.ExpressionAccessChain:
+		if v.Base != nil {
+			c.compileAddress(v.Base)
+		} else {
+			c.emitAddressOfVariable(v.Tok)
+		}
+
+		for _, key := range v.AccessKeys {
+			switch k := key.(type) {
+			case *AST.ExpressionArrayAccess:
+				c.compileExpression(k.Index)
+				c.at(k.Tok)
+				c.emit(OP_ADDRESS_INDEX)
+
+			case *AST.ExpressionIdentifier:
+				c.at(k.Tok)
+				c.emit(OP_ADDRESS_MEMBER, c.addConstant(&Runtime.ValueString{Value: k.Tok.Lexeme}, k.Tok.Lexeme))
+			}
+		}
+
+	default:
+		c.compileExpression(e)
+		c.emit(OP_BOX)
+	}
+}
+
+// compileContainer leaves the struct or array that the last key of the chain indexes on the stack
+func (c *Compiler) compileContainer(chain *AST.ExpressionAccessChain) AST.Expression {
+	if chain.Base != nil {
+		c.compileExpression(chain.Base)
+	} else {
+		c.emitGetVariable(chain.Tok)
+	}
+
+	for _, key := range chain.AccessKeys[:len(chain.AccessKeys)-1] {
+		c.compileAccessKey(key)
+	}
+
+	return chain.AccessKeys[len(chain.AccessKeys)-1]
+}
+
+func (c *Compiler) compileAccessKey(key AST.Expression) {
+	switch k := key.(type) {
+	case *AST.ExpressionArrayAccess:
+		c.compileExpression(k.Index)
+		c.at(k.Tok)
+		c.emit(OP_GET_INDEX)
+
+	case *AST.ExpressionIdentifier:
+		c.at(k.Tok)
+		c.emit(OP_GET_MEMBER, c.addConstant(&Runtime.ValueString{Value: k.Tok.Lexeme}, k.Tok.Lexeme))
+
+	default:
+		panic(fmt.Sprintf("unreachable access key: %T", key))
+	}
+}
+
+func (c *Compiler) compileExpression(e AST.Expression) {
+	switch v := e.(type) {
+	case *AST.ExpressionInteger:
+		c.emitConstant(Runtime.NewInteger(v.Kind, v.Value), literalKey{kind: v.Kind, value: v.Value})
+
+	case *AST.ExpressionFloat:
+		c.emitConstant(Runtime.NewFloat(v.Kind, v.Value), literalKey{kind: v.Kind, value: v.Value})
+
+	case *AST.ExpressionBoolean:
+		c.emitConstant(&Runtime.ValueBoolean{Value: v.Value}, v.Value)
+
+	case *AST.ExpressionString:
+		c.emitConstant(&Runtime.ValueString{Value: v.Value}, v.Value)
+
+	case *AST.ExpressionChar:
+		c.emitConstant(&Runtime.ValueChar{Value: v.Value}, v.Value)
+
+	case *AST.ExpressionIdentifier:
+		c.emitGetVariable(v.Tok)
+
+	case *AST.ExpressionEnumValue:
+		value := &Runtime.ValueEnum{DeclType: v.DeclType, Index: v.Index}
+		c.emitConstant(value, literalKey{kind: TS.TypeKind(v.Tok.Lexeme), value: v.Index})
+
+	case *AST.SE_FunctionCall:
+		c.compileFunctionCall(v)
+
+	case *AST.SE_MethodCall:
+		c.compileMethodCall(v)
+
+	case *AST.ExpressionFunction:
+		c.compileFunctionLiteral(v)
+
+	case *AST.ExpressionLen:
+		c.compileExpression(v.Iterable)
+		c.at(v.Tok)
+		c.emit(OP_LEN)
+
+	case *AST.ExpressionGrouping:
+		c.compileExpression(v.Expr)
+
+	case *AST.ExpressionNullptr:
+		c.emitConstant(&Runtime.ValuePointer{}, nullptrKey{})
+
+	case *AST.ExpressionTuple:
+		for _, element := range v.Elements {
+			c.compileExpression(element)
+		}
+
+		c.emit(OP_TUPLE, len(v.Elements))
+
+	case *AST.ExpressionAddressOf:
+		c.compileAddress(v.Operand)
+
+	case *AST.ExpressionDereference:
+		c.compileExpression(v.Operand)
+		c.at(v.Tok)
+		c.emit(OP_DEREFERENCE)
+
+	case *AST.ExpressionBinary:
+		c.at(v.Operator)
+		c.compileExpression(v.Left)
+
+		if v.Operator.Kind == Token.LOGICAL_OR || v.Operator.Kind == Token.LOGICAL_AND {
+			op := OP_JUMP_IF_TRUE_OR_POP
+			if v.Operator.Kind == Token.LOGICAL_AND {
+				op = OP_JUMP_IF_FALSE_OR_POP
+			}
+
+			end := c.emitJump(op)
+			c.compileExpression(v.Right)
+			c.patchJump(end)
+			return
+		}
+
+		op, ok := binaryOpcodes[v.Operator.Kind]
+		if !ok {
+			panic(fmt.Sprintf("unhandled operator: %v", v.Operator.Kind))
+		}
+
+		c.compileExpression(v.Right)
+		c.at(v.Operator)
+		c.emit(op)
+
+	case *AST.ExpressionArray:
+		for _, element := range v.Elements {
+			c.compileExpression(element)
+		}
+
+		c.emit(OP_ARRAY, c.addType(v.DeclType), len(v.Elements))
+
+	case *AST.ExpressionUnary:
+		c.compileExpression(v.Operand)
+		if v.Operator.Kind == Token.PLUS {
+			// +x is x, the TypeChecker only allows it on numbers
+			return
+		}
+
+		op, ok := unaryOpcodes[v.Operator.Kind]
+		if !ok {
+			panic(fmt.Sprintf("unhandled operator: %v", v.Operator.Kind))
+		}
+
+		c.at(v.Operator)
+		c.emit(op)
+
+	case *AST.ExpressionStruct:
+		name := v.Tok.Lexeme
+		if v.Decl != nil {
+			// an instantiation of a generic struct, declared the first time it's used
+			c.declareStruct(v.Decl)
+			name = v.Decl.Name()
+		}
+
+		index := c.structs[name]
+		for _, member := range c.program.Structs[index].Members {
+			if value, ok := v.MemberValues[member.Tok.Lexeme]; ok {
+				c.compileExpression(value)
+			} else {
+				c.emit(OP_NIL)
+			}
+		}
+
+		c.emit(OP_STRUCT, index)
+
+	case *AST.ExpressionUnion:
+		c.compileExpression(v.Payload)
+		c.emit(OP_UNION, c.addType(v.DeclType), v.Index)
+
+	case *AST.ExpressionAccessChain:
+		c.compileAccessKey(c.compileContainer(v))
+
+	case *AST.ExpressionTypeCast:
+		c.compileExpression(v.Expr)
+		c.at(v.Tok)
+		c.emit(OP_CAST, c.addType(v.CastType))
+
+	default:
+		panic(fmt.Sprintf("unreachable expression: %T", e))
+	}
+}
+
+func (c *Compiler) compileFunctionCall(call *AST.SE_FunctionCall) {
+	if call.Callee != nil {
+		c.compileValueCall(call.Tok, call.Callee, call.Arguments)
+		return
+	}
+
+	if index, ok := c.natives[call.Tok.Lexeme]; ok {
+		for _, argument := range call.Arguments {
+			c.compileExpression(argument)
+		}
+
+		c.at(call.Tok)
+		c.emit(OP_CALL_NATIVE, index)
+		return
+	}
+
+	index, ok := c.functions[call.Tok.Lexeme]
+	if !ok {
+		panic(fmt.Sprintf("Line %d | Undefined function: %s", call.Tok.Line, call.Tok.Lexeme))
+	}
+
+	if arity := c.program.Functions[index].Arity; arity != len(call.Arguments) {
+		panic(fmt.Sprintf("expected %d parameter(s), got %d", arity, len(call.Arguments)))
+	}
+
+	for _, argument := range call.Arguments {
+		c.compileExpression(argument)
+	}
+
+	c.at(call.Tok)
+	c.emit(OP_CALL, index)
+}
+
+// compileValueCall calls the function callee evaluates to, the callee is evaluated before the arguments
+func (c *Compiler) compileValueCall(tok Token.Token, callee AST.Expression, arguments []AST.Expression) {
+	c.compileExpression(callee)
+	for _, argument := range arguments {
+		c.compileExpression(argument)
+	}
+
+	c.at(tok)
+	c.emit(OP_CALL_VALUE, len(arguments))
+}
+
+// compileMethodCall calls the method like a function with the receiver as its first argument
+func (c *Compiler) compileMethodCall(call *AST.SE_MethodCall) {
+	if call.Callee != nil {
+		c.compileValueCall(call.Tok, call.Callee, call.Arguments)
+		return
+	}
+
+	index, ok := c.functions[call.Method.Name()]
+	if !ok {
+		panic(fmt.Sprintf("Line %d | Undefined method: %s", call.Tok.Line, call.Method.Name()))
+	}
+
+	c.compileExpression(call.ReceiverArgument)
+	for _, argument := range call.Arguments {
+		c.compileExpression(argument)
+	}
+
+	c.at(call.Tok)
+	c.emit(OP_CALL, index)
+}
+
+// exitDeferBody reports whether the innermost deferred statement sits between the
+// current code and the block at depth, in which case leaving just ends the deferred statement.
+func (c *Compiler) exitDeferBody(depth int) bool {
+	if len(c.state.deferred) == 0 {
+		return false
+	}
+
+	body := c.state.deferred[len(c.state.deferred)-1]
+	if body.blockDepth < depth {
+		return false
+	}
+
+	c.emitRunDefers(body.blockDepth)
+	body.exits = append(body.exits, c.emitJump(OP_JUMP))
+
+	return true
+}
+
+func (c *Compiler) compileLoopExit(isBreak bool) {
+	current := c.state.loops[len(c.state.loops)-1]
+	if c.exitDeferBody(current.blockDepth) {
+		return
+	}
+
+	c.emitRunDefers(current.blockDepth)
+	jump := c.emitJump(OP_JUMP)
+	if isBreak {
+		current.breaks = append(current.breaks, jump)
+	} else {
+		current.continues = append(current.continues, jump)
+	}
+}
+
+func (c *Compiler) compileLoopBody(body *AST.StatementBlock) *loop {
+	current := &loop{blockDepth: len(c.state.blocks)}
+	c.state.loops = append(c.state.loops, current)
+	c.compileStatement(body)
+	c.state.loops = c.state.loops[:len(c.state.loops)-1]
+
+	return current
+}
+
+// compileCompoundAssignment evaluates the location of LHS once, like the Interpreter, and keeps
+// it on the stack under the current value while the operator is applied
+func (c *Compiler) compileCompoundAssignment(v *AST.StatementAssignment) {
+	apply := func() {
+		c.compileExpression(v.RHS)
+		c.at(*v.Operator)
+		c.emit(binaryOpcodes[v.Operator.Kind])
+	}
+
+	c.at(v.Tok)
+	switch lhs := v.LHS.(type) {
+	case *AST.ExpressionIdentifier:
+		c.emitGetVariable(lhs.Tok)
+		apply()
+		c.at(v.Tok)
+		c.emitSetVariable(lhs.Tok)
+
+	case *AST.ExpressionAccessChain:
+		switch key := c.compileContainer(lhs).(type) {
+		case *AST.ExpressionArrayAccess:
+			c.compileExpression(key.Index)
+			c.emit(OP_DUP, 2)
+			c.at(key.Tok)
+			c.emit(OP_GET_INDEX)
+			apply()
+			c.emit(OP_ROTATE, 3)
+			c.at(key.Tok)
+			c.emit(OP_SET_INDEX)
+
+		case *AST.ExpressionIdentifier:
+			name := c.addConstant(&Runtime.ValueString{Value: key.Tok.Lexeme}, key.Tok.Lexeme)
+			c.emit(OP_DUP, 1)
+			c.at(key.Tok)
+			c.emit(OP_GET_MEMBER, name)
+			apply()
+			c.emit(OP_ROTATE, 2)
+			c.at(key.Tok)
+			c.emit(OP_SET_MEMBER, name)
+		}
+
+	case *AST.ExpressionDereference:
+		c.compileExpression(lhs.Operand)
+		c.emit(OP_DUP, 1)
+		c.at(lhs.Tok)
+		c.emit(OP_DEREFERENCE)
+		apply()
+		c.emit(OP_ROTATE, 2)
+		c.at(lhs.Tok)
+		c.emit(OP_STORE)
+
+	default:
+		panic("unreachable")
+	}
+}
+
+func (c *Compiler) compileStatement(s AST.Statement) {
+	switch v := s.(type) {
+	case *AST.StatementPrint:
+		c.at(v.Tok)
+		c.compileExpression(v.Expr)
+		c.at(v.Tok)
+		if v.IsNewLine {
+			c.emit(OP_PRINTLN)
+		} else {
+			c.emit(OP_PRINT)
+		}
+
+	case *AST.StatementAssignment:
+		if v.Operator != nil {
+			c.compileCompoundAssignment(v)
+			return
+		}
+
+		c.at(v.Tok)
+		c.compileExpression(v.RHS)
+
+		switch lhs := v.LHS.(type) {
+		case *AST.ExpressionIdentifier:
+			c.at(v.Tok)
+			c.emitSetVariable(lhs.Tok)
+
+		case *AST.ExpressionAccessChain:
+			switch key := c.compileContainer(lhs).(type) {
+			case *AST.ExpressionArrayAccess:
+				c.compileExpression(key.Index)
+				c.at(key.Tok)
+				c.emit(OP_SET_INDEX)
+
+			case *AST.ExpressionIdentifier:
+				c.at(key.Tok)
+				c.emit(OP_SET_MEMBER, c.addConstant(&Runtime.ValueString{Value: key.Tok.Lexeme}, key.Tok.Lexeme))
+			}
+
+		case *AST.ExpressionDereference:
+			c.compileExpression(lhs.Operand)
+			c.at(lhs.Tok)
+			c.emit(OP_STORE)
+
+		default:
+			panic("unreachable")
+		}
+
+	case *AST.StatementBlock:
+		c.beginBlock()
+		c.compileNodes(v.Body)
+		c.endBlock()
+
+	case *AST.StatementFor:
+		c.at(v.Tok)
+		c.beginBlock()
+		c.compileDeclaration(v.Initializer)
+
+		condition := len(c.code())
+		c.compileExpression(v.Condition)
+		exit := c.emitJump(OP_JUMP_IF_FALSE)
+
+		current := c.compileLoopBody(v.Block)
+		for _, jump := range current.continues {
+			c.patchJump(jump)
+		}
+
+		c.compileStatement(v.Increment)
+		c.emit(OP_JUMP, condition)
+
+		c.patchJump(exit)
+		for _, jump := range current.breaks {
+			c.patchJump(jump)
+		}
+
+		c.endBlock()
+
+	case *AST.StatementWhile:
+		c.at(v.Tok)
+		condition := len(c.code())
+		c.compileExpression(v.Condition)
+		exit := c.emitJump(OP_JUMP_IF_FALSE)
+
+		current := c.compileLoopBody(v.Block)
+		for _, jump := range current.continues {
+			c.patchJump(jump)
+		}
+
+		c.emit(OP_JUMP, condition)
+
+		c.patchJump(exit)
+		for _, jump := range current.breaks {
+			c.patchJump(jump)
+		}
+
+	case *AST.StatementReturn:
+		c.at(v.Tok)
+		if len(c.state.deferred) > 0 {
+			if v.Expr != nil {
+				c.compileExpression(v.Expr)
+				c.emit(OP_POP)
+			}
+
+			c.exitDeferBody(0)
+			return
+		}
+
+		if v.Expr != nil {
+			c.compileExpression(v.Expr)
+		} else {
+			c.emit(OP_NIL)
+		}
+
+		c.emitRunDefers(0)
+		c.emit(OP_RETURN)
+
+	case *AST.StatementDefer:
+		c.at(v.Tok)
+		over := c.emitJump(OP_JUMP)
+		start := len(c.code())
+
+		body := &deferBody{blockDepth: len(c.state.blocks)}
+		c.state.deferred = append(c.state.deferred, body)
+		c.compileNode(v.DeferredNode)
+		c.state.deferred = c.state.deferred[:len(c.state.deferred)-1]
+
+		for _, jump := range body.exits {
+			c.patchJump(jump)
+		}
+
+		c.emit(OP_END_DEFER)
+		c.patchJump(over)
+
+		c.at(v.Tok)
+		c.emit(OP_DEFER, start)
+		c.state.blocks[len(c.state.blocks)-1].defers += 1
+
+	case *AST.StatementBreak:
+		c.at(v.Tok)
+		c.compileLoopExit(true)
+
+	case *AST.StatementContinue:
+		c.at(v.Tok)
+		c.compileLoopExit(false)
+
+	case *AST.StatementIfElse:
+		c.at(v.Tok)
+		c.compileExpression(v.Condition)
+		otherwise := c.emitJump(OP_JUMP_IF_FALSE)
+		c.compileStatement(v.IfBlock)
+
+		if v.ElseBlock != nil {
+			end := c.emitJump(OP_JUMP)
+			c.patchJump(otherwise)
+			c.compileStatement(v.ElseBlock)
+			c.patchJump(end)
+		} else {
+			c.patchJump(otherwise)
+		}
+
+	case *AST.StatementSwitch:
+		c.at(v.Tok)
+		c.compileExpression(v.Value)
+
+		// the value stays on the stack while the cases compare with it, it's popped before
+		// a body runs so the body's locals are where they'd be without the switch
+		var ends []int
+		for _, switchCase := range v.Cases {
+			var matches []int
+			for _, value := range switchCase.Values {
+				c.emit(OP_DUP, 1)
+				c.compileExpression(value)
+				c.emit(OP_EQUAL)
+				matches = append(matches, c.emitJump(OP_JUMP_IF_TRUE_OR_POP))
+			}
+
+			next := c.emitJump(OP_JUMP)
+			for _, match := range matches {
+				c.patchJump(match)
+			}
+
+			c.emit(OP_POP)
+			c.emit(OP_POP)
+			c.compileStatement(switchCase.Body)
+			ends = append(ends, c.emitJump(OP_JUMP))
+			c.patchJump(next)
+		}
+
+		c.emit(OP_POP)
+		if v.Default != nil {
+			c.compileStatement(v.Default)
+		}
+
+		for _, end := range ends {
+			c.patchJump(end)
+		}
+
+	case *AST.StatementMatch:
+		c.at(v.Tok)
+		c.compileExpression(v.Value)
+
+		// like a switch the value stays on the stack while the cases compare its variant, the
+		// matching case replaces it with the payload it binds
+		var ends []int
+		for _, matchCase := range v.Cases {
+			c.emit(OP_DUP, 1)
+			c.emit(OP_VARIANT)
+			c.emitConstant(&Runtime.ValueInteger{Value: matchCase.Index}, literalKey{kind: TS.INTEGER, value: matchCase.Index})
+			c.emit(OP_EQUAL)
+			next := c.emitJump(OP_JUMP_IF_FALSE)
+
+			c.beginBlock()
+			if matchCase.Binding != nil {
+				c.emit(OP_PAYLOAD)
+				c.emitDeclareLocal(matchCase.Binding.Lexeme)
+			} else {
+				c.emit(OP_POP)
+			}
+
+			c.compileStatement(matchCase.Body)
+			c.endBlock()
+			ends = append(ends, c.emitJump(OP_JUMP))
+			c.patchJump(next)
+		}
+
+		c.emit(OP_POP)
+		if v.Default != nil {
+			c.compileStatement(v.Default)
+		}
+
+		for _, end := range ends {
+			c.patchJump(end)
+		}
+
+	case *AST.SE_FunctionCall:
+		c.compileFunctionCall(v)
+		c.emit(OP_POP)
+
+	case *AST.SE_MethodCall:
+		c.compileMethodCall(v)
+		c.emit(OP_POP)
+
+	case *AST.StatementError:
+		panic("attempting to compile a program with syntax errors")
+
+	default:
+		panic(fmt.Sprintf("unreachable statement: %T", v))
+	}
+}
+
+func (c *Compiler) compileDeclaration(decl AST.Declaration) {
+	switch v := decl.(type) {
+	case *AST.DeclarationVariable:
+		c.at(v.Tok)
+		c.compileExpression(v.RHS)
+
+		if c.isGlobalScope() {
+			c.emit(OP_SET_GLOBAL, c.globals[v.Tok.Lexeme])
+		} else {
+			c.emitDeclareLocal(v.Tok.Lexeme)
+		}
+
+	case *AST.DeclarationDestructure:
+		c.at(v.Tok)
+		c.compileExpression(v.RHS)
+		c.emit(OP_UNPACK, len(v.Variables))
+
+		// UNPACK leaves the last value on top
+		for i := len(v.Variables) - 1; i >= 0; i-- {
+			name := v.Variables[i].Tok.Lexeme
+			if c.isGlobalScope() {
+				c.emit(OP_SET_GLOBAL, c.globals[name])
+			} else {
+				c.emitDeclareLocal(name)
+			}
+		}
+
+	case *AST.DeclarationFunction:
+		if _, ok := c.functions[v.Name()]; !ok {
+			c.declareFunction(v)
+		}
+
+		c.compileFunction(v)
+
+	case *AST.DeclarationStruct:
+		c.declareStruct(v)
+
+	case *AST.DeclarationEnum, *AST.DeclarationUnion:
+		// enum and union values carry their type
+
+	case *AST.DeclarationError:
+		panic("attempting to compile a program with syntax errors")
+
+	default:
+		panic(fmt.Sprintf("unhandled declaration: %T", decl))
+	}
+}
+
+func (c *Compiler) compileNode(node AST.Node) {
+	switch v := node.(type) {
+	case AST.Statement:
+		c.compileStatement(v)
+
+	case AST.Declaration:
+		c.compileDeclaration(v)
+	}
+}
+
+func (c *Compiler) compileNodes(nodes []AST.Node) {
+	for _, node := range nodes {
+		c.compileNode(node)
+	}
+}
+
+func (c *Compiler) declareFunction(decl *AST.DeclarationFunction) {
+	c.functions[decl.Name()] = len(c.program.Functions)
+	c.program.Functions = append(c.program.Functions, &Function{
+		Name:  decl.Name(),
+		File:  decl.Tok.File,
+		Arity: len(decl.DeclType.Parameters),
+		Decl:  decl,
+	})
+}
+
+func (c *Compiler) declareGlobal(name string) {
+	c.globals[name] = len(c.program.Globals)
+	c.program.Globals = append(c.program.Globals, name)
+}
+
+func (c *Compiler) declareStruct(decl *AST.DeclarationStruct) {
+	if _, ok := c.structs[decl.Name()]; ok {
+		return // a nested struct seen again while recompiling its function, or an instantiation used again
+	}
+
+	c.structs[decl.Name()] = len(c.program.Structs)
+	c.program.Structs = append(c.program.Structs, decl)
+}
+
+func (c *Compiler) beginFunction(function *Function) *functionState {
+	previous := c.state
+	c.state = &functionState{
+		function: function,
+		boxed:    make(map[string]bool),
+		escaped:  make(map[string]bool),
+	}
+	c.constants = make(map[interface{}]int)
+	c.beginBlock()
+
+	return previous
+}
+
+func (c *Compiler) endFunction(previous *functionState, previousConstants map[interface{}]int) {
+	c.endBlock()
+	c.emit(OP_NIL)
+	c.emit(OP_RETURN)
+
+	c.state = previous
+	c.constants = previousConstants
+}
+
+func (c *Compiler) compileFunction(decl *AST.DeclarationFunction) {
+	c.compileFunctionBody(c.program.Functions[c.functions[decl.Name()]], decl, nil)
+}
+
+// compileFunctionLiteral compiles the anonymous function and leaves a closure of it on the stack
+func (c *Compiler) compileFunctionLiteral(literal *AST.ExpressionFunction) {
+	index, ok := c.literals[literal]
+	if !ok {
+		index = len(c.program.Functions)
+		c.literals[literal] = index
+		c.program.Functions = append(c.program.Functions, &Function{
+			Name:  literal.Decl.Name(),
+			File:  literal.Tok.File,
+			Arity: len(literal.Decl.DeclType.Parameters),
+			Decl:  literal.Decl,
+		})
+	}
+
+	function := c.program.Functions[index]
+	c.compileFunctionBody(function, literal.Decl, c.state)
+
+	c.at(literal.Tok)
+	for _, name := range function.Captures {
+		if slot, ok := c.resolveLocal(name); ok {
+			c.emit(OP_GET_LOCAL, slot)
+		} else {
+			capture, _ := c.state.resolveCapture(name)
+			c.emit(OP_GET_CAPTURE, capture)
+		}
+	}
+
+	c.emit(OP_CLOSURE, index)
+}
+
+func (c *Compiler) compileFunctionBody(function *Function, decl *AST.DeclarationFunction, enclosing *functionState) {
+	boxed := make(map[string]bool)
+
+	for {
+		function.Code, function.Positions, function.Constants, function.NumLocals = nil, nil, nil, 0
+		function.Captures = nil
+
+		previousConstants := c.constants
+		previous := c.beginFunction(function)
+		c.state.boxed = boxed
+		c.state.enclosing = enclosing
+		c.state.captures = make(map[string]int)
+
+		c.at(decl.Tok)
+		for _, param := range decl.DeclType.Parameters {
+			slot := c.declareLocal(param.Tok.Lexeme)
+			if boxed[param.Tok.Lexeme] {
+				c.emit(OP_GET_LOCAL, slot)
+				c.emit(OP_BOX)
+				c.emit(OP_SET_LOCAL, slot)
+			}
+		}
+
+		c.compileNodes(decl.Block.Body)
+
+		escaped := c.state.escaped
+		c.endFunction(previous, previousConstants)
+		if len(escaped) == 0 {
+			return
+		}
+
+		for name := range escaped {
+			boxed[name] = true
+		}
+	}
+}
+
+// Compile turns a type checked program into bytecode, it panics on programs the
+// TypeChecker would have rejected. natives, which may be nil, has to be what the
+// program was type checked with.
+func Compile(program AST.Program, natives *Runtime.Natives) *Program {
+	c := &Compiler{
+		program: &Program{
+			Init: &Function{Name: "<init>"},
+			Main: -1,
+		},
+		functions: make(map[string]int),
+		literals:  make(map[*AST.ExpressionFunction]int),
+		natives:   make(map[string]int),
+		structs:   make(map[string]int),
+		globals:   make(map[string]int),
+	}
+
+	for _, native := range natives.Functions() {
+		c.natives[native.Name] = len(c.program.Natives)
+		c.program.Natives = append(c.program.Natives, native)
+	}
+
+	// Functions can call anything declared at the top level and see every global
+	for _, decl := range program.Declarations {
+		switch v := decl.(type) {
+		case *AST.DeclarationFunction:
+			c.declareFunction(v)
+		case *AST.DeclarationStruct:
+			c.declareStruct(v)
+		case *AST.DeclarationVariable:
+			c.declareGlobal(v.Tok.Lexeme)
+		case *AST.DeclarationDestructure:
+			for _, variable := range v.Variables {
+				c.declareGlobal(variable.Tok.Lexeme)
+			}
+		}
+	}
+
+	if main, ok := c.functions["main"]; ok {
+		c.program.Main = main
+	}
+
+	previous := c.beginFunction(c.program.Init)
+	for _, decl := range program.Declarations {
+		switch v := decl.(type) {
+		case *AST.DeclarationVariable:
+			c.program.Init.File = v.Tok.File
+			c.compileDeclaration(v)
+		case *AST.DeclarationDestructure:
+			c.program.Init.File = v.Tok.File
+			c.compileDeclaration(v)
+		}
+	}
+	c.endFunction(previous, nil)
+
+	for _, decl := range program.Declarations {
+		if v, ok := decl.(*AST.DeclarationFunction); ok {
+			c.compileFunction(v)
+		}
+	}
+
+	return c.program
+}
@@ -0,0 +1,275 @@
+package VM
+
+import (
+	"fmt"
+	"io"
+	"ion-go/AST"
+	"ion-go/Runtime"
+	"ion-go/TS"
+	"strings"
+)
+
+type Opcode byte
+
+// Operands are big endian uint16, jump targets are absolute offsets into the function's code
+const (
+	OP_CONSTANT Opcode = iota // constant
+	OP_NIL
+	OP_POP
+	OP_DUP        // count, pushes the top count values again in the same order
+	OP_ROTATE     // count, moves the top value below the count-1 values under it
+	OP_GET_LOCAL  // slot
+	OP_SET_LOCAL  // slot
+	OP_GET_GLOBAL // global
+	OP_SET_GLOBAL // global
+	OP_GET_INDEX
+	OP_SET_INDEX
+	OP_GET_MEMBER // constant holding the member name
+	OP_SET_MEMBER // constant holding the member name
+	OP_ARRAY      // type, element count
+	OP_STRUCT     // struct
+	OP_UNION      // type, variant
+	OP_VARIANT
+	OP_PAYLOAD
+	OP_TUPLE  // element count
+	OP_UNPACK // element count
+	OP_BOX
+	OP_DEREFERENCE
+	OP_STORE
+	OP_ADDRESS_GLOBAL // global
+	OP_ADDRESS_INDEX
+	OP_ADDRESS_MEMBER // constant holding the member name
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_MODULO
+	OP_EQUAL
+	OP_NOT_EQUAL
+	OP_LESS
+	OP_LESS_EQUAL
+	OP_GREATER
+	OP_GREATER_EQUAL
+	OP_BIT_AND
+	OP_BIT_OR
+	OP_BIT_XOR
+	OP_SHIFT_LEFT
+	OP_SHIFT_RIGHT
+	OP_NEGATE
+	OP_NOT
+	OP_BIT_NOT
+	OP_CAST // type
+	OP_LEN
+	OP_JUMP                 // target
+	OP_JUMP_IF_FALSE        // target
+	OP_JUMP_IF_TRUE_OR_POP  // target
+	OP_JUMP_IF_FALSE_OR_POP // target
+	OP_CALL                 // function
+	OP_CALL_NATIVE          // native
+	OP_CALL_VALUE           // argument count, the function value is below the arguments
+	OP_CLOSURE              // function, pops the pointers to the variables it captures
+	OP_GET_CAPTURE          // capture, pushes the pointer to the captured variable
+	OP_RETURN
+	OP_PRINT
+	OP_PRINTLN
+	OP_DEFER      // target of the deferred code
+	OP_RUN_DEFERS // how many of the most recent defers to run
+	OP_END_DEFER
+)
+
+var opcodeNames = [...]string{
+	OP_CONSTANT:             "CONSTANT",
+	OP_NIL:                  "NIL",
+	OP_POP:                  "POP",
+	OP_DUP:                  "DUP",
+	OP_ROTATE:               "ROTATE",
+	OP_GET_LOCAL:            "GET_LOCAL",
+	OP_SET_LOCAL:            "SET_LOCAL",
+	OP_GET_GLOBAL:           "GET_GLOBAL",
+	OP_SET_GLOBAL:           "SET_GLOBAL",
+	OP_GET_INDEX:            "GET_INDEX",
+	OP_SET_INDEX:            "SET_INDEX",
+	OP_GET_MEMBER:           "GET_MEMBER",
+	OP_SET_MEMBER:           "SET_MEMBER",
+	OP_ARRAY:                "ARRAY",
+	OP_STRUCT:               "STRUCT",
+	OP_UNION:                "UNION",
+	OP_VARIANT:              "VARIANT",
+	OP_PAYLOAD:              "PAYLOAD",
+	OP_TUPLE:                "TUPLE",
+	OP_UNPACK:               "UNPACK",
+	OP_BOX:                  "BOX",
+	OP_DEREFERENCE:          "DEREFERENCE",
+	OP_STORE:                "STORE",
+	OP_ADDRESS_GLOBAL:       "ADDRESS_GLOBAL",
+	OP_ADDRESS_INDEX:        "ADDRESS_INDEX",
+	OP_ADDRESS_MEMBER:       "ADDRESS_MEMBER",
+	OP_ADD:                  "ADD",
+	OP_SUBTRACT:             "SUBTRACT",
+	OP_MULTIPLY:             "MULTIPLY",
+	OP_DIVIDE:               "DIVIDE",
+	OP_MODULO:               "MODULO",
+	OP_EQUAL:                "EQUAL",
+	OP_NOT_EQUAL:            "NOT_EQUAL",
+	OP_LESS:                 "LESS",
+	OP_LESS_EQUAL:           "LESS_EQUAL",
+	OP_GREATER:              "GREATER",
+	OP_GREATER_EQUAL:        "GREATER_EQUAL",
+	OP_BIT_AND:              "BIT_AND",
+	OP_BIT_OR:               "BIT_OR",
+	OP_BIT_XOR:              "BIT_XOR",
+	OP_SHIFT_LEFT:           "SHIFT_LEFT",
+	OP_SHIFT_RIGHT:          "SHIFT_RIGHT",
+	OP_NEGATE:               "NEGATE",
+	OP_NOT:                  "NOT",
+	OP_BIT_NOT:              "BIT_NOT",
+	OP_CAST:                 "CAST",
+	OP_LEN:                  "LEN",
+	OP_JUMP:                 "JUMP",
+	OP_JUMP_IF_FALSE:        "JUMP_IF_FALSE",
+	OP_JUMP_IF_TRUE_OR_POP:  "JUMP_IF_TRUE_OR_POP",
+	OP_JUMP_IF_FALSE_OR_POP: "JUMP_IF_FALSE_OR_POP",
+	OP_CALL:                 "CALL",
+	OP_CALL_NATIVE:          "CALL_NATIVE",
+	OP_CALL_VALUE:           "CALL_VALUE",
+	OP_CLOSURE:              "CLOSURE",
+	OP_GET_CAPTURE:          "GET_CAPTURE",
+	OP_RETURN:               "RETURN",
+	OP_PRINT:                "PRINT",
+	OP_PRINTLN:              "PRINTLN",
+	OP_DEFER:                "DEFER",
+	OP_RUN_DEFERS:           "RUN_DEFERS",
+	OP_END_DEFER:            "END_DEFER",
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodeNames) {
+		return opcodeNames[op]
+	}
+
+	return fmt.Sprintf("OP_%d", op)
+}
+
+// operandCount is the number of uint16 operands that follow the opcode
+func (op Opcode) operandCount() int {
+	switch op {
+	case OP_ARRAY, OP_UNION:
+		return 2
+	case OP_CONSTANT, OP_DUP, OP_ROTATE, OP_GET_LOCAL, OP_SET_LOCAL, OP_GET_GLOBAL, OP_SET_GLOBAL,
+		OP_GET_MEMBER, OP_SET_MEMBER, OP_STRUCT, OP_TUPLE, OP_UNPACK, OP_CAST, OP_ADDRESS_GLOBAL, OP_ADDRESS_MEMBER,
+		OP_JUMP, OP_JUMP_IF_FALSE, OP_JUMP_IF_TRUE_OR_POP, OP_JUMP_IF_FALSE_OR_POP,
+		OP_CALL, OP_CALL_NATIVE, OP_CALL_VALUE, OP_CLOSURE, OP_GET_CAPTURE, OP_DEFER, OP_RUN_DEFERS:
+		return 1
+	}
+
+	return 0
+}
+
+type Position struct {
+	Line   int
+	Column int
+}
+
+type Function struct {
+	Name      string
+	File      string
+	Arity     int
+	NumLocals int
+	Code      []byte
+	Positions []Position // source position of every byte in Code
+	Constants []Runtime.Value
+	Captures  []string // the variables an anonymous function captures, in the order CLOSURE pops them
+	Decl      *AST.DeclarationFunction
+}
+
+// closure is a ValueFunction's Closure in the VM, captures point to the cells of the captured variables
+type closure struct {
+	function *Function
+	captures []Runtime.Value
+}
+
+// Program is the compiled form of a type checked AST.Program. Init evaluates the
+// global variables in declaration order and Main is -1 when there is no main function.
+type Program struct {
+	Functions []*Function
+	Types     []*TS.Type
+	Structs   []*AST.DeclarationStruct
+	Natives   []*Runtime.NativeFunction
+	Globals   []string
+	Init      *Function
+	Main      int
+}
+
+func readOperand(code []byte, offset int) int {
+	return int(code[offset])<<8 | int(code[offset+1])
+}
+
+func (p *Program) describeOperands(function *Function, op Opcode, operands []int) string {
+	switch op {
+	case OP_CONSTANT, OP_GET_MEMBER, OP_SET_MEMBER, OP_ADDRESS_MEMBER:
+		constant := function.Constants[operands[0]]
+		if s, ok := constant.(*Runtime.ValueString); ok {
+			return fmt.Sprintf("%q", s.Value)
+		}
+
+		return Runtime.Format(constant)
+	case OP_GET_GLOBAL, OP_SET_GLOBAL, OP_ADDRESS_GLOBAL:
+		return p.Globals[operands[0]]
+	case OP_ARRAY, OP_CAST:
+		return p.Types[operands[0]].String()
+	case OP_UNION:
+		t := p.Types[operands[0]]
+		return t.String() + "." + t.Variants[operands[1]]
+	case OP_STRUCT:
+		return p.Structs[operands[0]].Name()
+	case OP_CALL, OP_CLOSURE:
+		return p.Functions[operands[0]].Name
+	case OP_GET_CAPTURE:
+		return function.Captures[operands[0]]
+	case OP_CALL_NATIVE:
+		return p.Natives[operands[0]].Name
+	}
+
+	return ""
+}
+
+func (p *Program) disassembleFunction(w io.Writer, function *Function) {
+	fmt.Fprintf(w, "== %s (arity %d, %d locals) ==\n", function.Name, function.Arity, function.NumLocals)
+
+	for offset := 0; offset < len(function.Code); {
+		op := Opcode(function.Code[offset])
+
+		var operands []int
+		for i := 0; i < op.operandCount(); i++ {
+			operands = append(operands, readOperand(function.Code, offset+1+i*2))
+		}
+
+		line := fmt.Sprintf("%4d", function.Positions[offset].Line)
+		if offset > 0 && function.Positions[offset-1].Line == function.Positions[offset].Line {
+			line = "   |"
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%04d %s %-20s", offset, line, op)
+		for _, operand := range operands {
+			fmt.Fprintf(&sb, " %d", operand)
+		}
+
+		if description := p.describeOperands(function, op, operands); description != "" {
+			fmt.Fprintf(&sb, " (%s)", description)
+		}
+
+		fmt.Fprintln(w, strings.TrimRight(sb.String(), " "))
+		offset += 1 + op.operandCount()*2
+	}
+
+	fmt.Fprintln(w)
+}
+
+// Disassemble prints every function in a human readable listing, used by `ion bytecode`
+func (p *Program) Disassemble(w io.Writer) {
+	p.disassembleFunction(w, p.Init)
+	for _, function := range p.Functions {
+		p.disassembleFunction(w, function)
+	}
+}
@@ -0,0 +1,488 @@
+package VM
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ion-go/AST"
+	"ion-go/Runtime"
+	"ion-go/TS"
+	"ion-go/Token"
+	"os"
+)
+
+// unwind remembers where to continue once RUN_DEFERS has run the defers down to target
+type unwind struct {
+	target int
+	resume int
+}
+
+type Frame struct {
+	function  *Function
+	ip        int
+	base      int // stack index of local slot 0
+	captures  []Runtime.Value
+	defers    []int
+	unwinding []unwind
+}
+
+// globalReference is what &global points to
+type globalReference struct {
+	vm    *VM
+	index int
+}
+
+func (r globalReference) Load() Runtime.Value {
+	return r.vm.globals[r.index]
+}
+
+func (r globalReference) Store(value Runtime.Value) {
+	r.vm.globals[r.index] = value
+}
+
+type VM struct {
+	program *Program
+	stack   []Runtime.Value
+	frames  []Frame
+	globals []Runtime.Value
+	stdout  io.Writer
+	host    *Runtime.Host
+}
+
+var binaryOperators = map[Opcode]Token.TokenType{
+	OP_ADD:           Token.PLUS,
+	OP_SUBTRACT:      Token.MINUS,
+	OP_MULTIPLY:      Token.STAR,
+	OP_DIVIDE:        Token.DIVISION,
+	OP_MODULO:        Token.MODULUS,
+	OP_EQUAL:         Token.EQUALS_EQUALS,
+	OP_NOT_EQUAL:     Token.NOT_EQUALS,
+	OP_LESS:          Token.LESS_THAN,
+	OP_LESS_EQUAL:    Token.LESS_THAN_EQUALS,
+	OP_GREATER:       Token.GREATER_THAN,
+	OP_GREATER_EQUAL: Token.GREATER_THAN_EQUALS,
+	OP_BIT_AND:       Token.AMPERSAND,
+	OP_BIT_OR:        Token.PIPE,
+	OP_BIT_XOR:       Token.CARET,
+	OP_SHIFT_LEFT:    Token.LEFT_SHIFT,
+	OP_SHIFT_RIGHT:   Token.RIGHT_SHIFT,
+}
+
+func NewVM(program *Program, stdout io.Writer) *VM {
+	return &VM{
+		program: program,
+		globals: make([]Runtime.Value, len(program.Globals)),
+		stdout:  stdout,
+		host: &Runtime.Host{
+			Context: context.Background(),
+			Stdout:  stdout,
+			Stderr:  os.Stderr,
+			Stdin:   os.Stdin,
+		},
+	}
+}
+
+func (vm *VM) push(value Runtime.Value) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() Runtime.Value {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+
+	return value
+}
+
+func (vm *VM) peek() Runtime.Value {
+	return vm.stack[len(vm.stack)-1]
+}
+
+// pushFrame expects the arguments on top of the stack, they become the first local slots
+func (vm *VM) pushFrame(function *Function) {
+	if len(vm.frames) >= Runtime.MAX_CALL_DEPTH {
+		Runtime.Throw("stack overflow calling %s, more than %d nested calls", function.Name, Runtime.MAX_CALL_DEPTH)
+	}
+
+	base := len(vm.stack) - function.Arity
+	for i := base; i < len(vm.stack); i++ {
+		vm.stack[i] = Runtime.Copy(vm.stack[i])
+	}
+
+	for len(vm.stack) < base+function.NumLocals {
+		vm.push(nil)
+	}
+
+	vm.frames = append(vm.frames, Frame{
+		function: function,
+		base:     base,
+	})
+}
+
+// nextDefer jumps into the most recent pending defer of the innermost RUN_DEFERS, or back
+// to the code after it once they have all run.
+func nextDefer(frame *Frame) {
+	current := frame.unwinding[len(frame.unwinding)-1]
+	if len(frame.defers) > current.target {
+		frame.ip = frame.defers[len(frame.defers)-1]
+		frame.defers = frame.defers[:len(frame.defers)-1]
+		return
+	}
+
+	frame.unwinding = frame.unwinding[:len(frame.unwinding)-1]
+	frame.ip = current.resume
+}
+
+// stackTrace maps every frame's instruction pointer back to a source position, innermost call
+// first. ip is already past the instruction that was executing, its last byte has the same position.
+func (vm *VM) stackTrace() []Runtime.StackFrame {
+	trace := make([]Runtime.StackFrame, 0, len(vm.frames))
+	for i := len(vm.frames) - 1; i >= 0; i-- {
+		frame := vm.frames[i]
+
+		var position Position
+		if frame.ip > 0 {
+			position = frame.function.Positions[frame.ip-1]
+		}
+
+		trace = append(trace, Runtime.StackFrame{
+			Function: frame.function.Name,
+			File:     frame.function.File,
+			Line:     position.Line,
+			Column:   position.Column,
+		})
+	}
+
+	return trace
+}
+
+func asBoolean(value Runtime.Value) bool {
+	return value.(*Runtime.ValueBoolean).Value
+}
+
+// Call runs function with the given arguments to completion and returns its result, nil for
+// void functions. A runtime error is returned as a *Runtime.Error and leaves the VM unusable.
+func (vm *VM) Call(function *Function, args ...Runtime.Value) (result Runtime.Value, err error) {
+	if len(args) != function.Arity {
+		return nil, &Runtime.Error{Message: fmt.Sprintf("expected %d parameter(s), got %d", function.Arity, len(args))}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, Runtime.Recovered(r, vm.stackTrace())
+		}
+	}()
+
+	for _, arg := range args {
+		vm.push(arg)
+	}
+
+	depth := len(vm.frames)
+	vm.pushFrame(function)
+
+	return vm.run(depth), nil
+}
+
+func (vm *VM) run(depth int) Runtime.Value {
+	frame := &vm.frames[len(vm.frames)-1]
+
+	for {
+		code := frame.function.Code
+		op := Opcode(code[frame.ip])
+		operand := 0
+		if op.operandCount() > 0 {
+			operand = readOperand(code, frame.ip+1)
+		}
+
+		frame.ip += 1 + op.operandCount()*2
+
+		switch op {
+		case OP_CONSTANT:
+			vm.push(frame.function.Constants[operand])
+
+		case OP_NIL:
+			vm.push(nil)
+
+		case OP_POP:
+			vm.pop()
+
+		case OP_DUP:
+			vm.stack = append(vm.stack, vm.stack[len(vm.stack)-operand:]...)
+
+		case OP_ROTATE:
+			top := vm.peek()
+			below := vm.stack[len(vm.stack)-operand:]
+			copy(below[1:], below[:operand-1])
+			below[0] = top
+
+		case OP_GET_LOCAL:
+			vm.push(vm.stack[frame.base+operand])
+
+		case OP_SET_LOCAL:
+			vm.stack[frame.base+operand] = Runtime.Copy(vm.pop())
+
+		case OP_GET_GLOBAL:
+			vm.push(vm.globals[operand])
+
+		case OP_SET_GLOBAL:
+			vm.globals[operand] = Runtime.Copy(vm.pop())
+
+		case OP_GET_INDEX:
+			index := vm.pop()
+			vm.push(Runtime.Index(vm.pop(), index))
+
+		case OP_SET_INDEX:
+			index := vm.pop()
+			array := vm.pop()
+			Runtime.SetIndex(array, index, Runtime.Copy(vm.pop()))
+
+		case OP_GET_MEMBER:
+			name := frame.function.Constants[operand].(*Runtime.ValueString).Value
+			vm.push(Runtime.Member(vm.pop(), name))
+
+		case OP_SET_MEMBER:
+			name := frame.function.Constants[operand].(*Runtime.ValueString).Value
+			structure := vm.pop()
+			Runtime.SetMember(structure, name, Runtime.Copy(vm.pop()))
+
+		case OP_ARRAY:
+			count := readOperand(code, frame.ip-2)
+			elements := make([]Runtime.Value, count)
+			for i, element := range vm.stack[len(vm.stack)-count:] {
+				elements[i] = Runtime.Copy(element)
+			}
+
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(&Runtime.ValueArray{
+				Elements: elements,
+				DeclType: vm.program.Types[operand],
+			})
+
+		case OP_STRUCT:
+			decl := vm.program.Structs[operand]
+			values := vm.stack[len(vm.stack)-len(decl.Members):]
+
+			members := make(map[string]Runtime.Value, len(decl.Members))
+			for i, member := range decl.Members {
+				if values[i] != nil {
+					members[member.Tok.Lexeme] = Runtime.Copy(values[i])
+				}
+			}
+
+			vm.stack = vm.stack[:len(vm.stack)-len(decl.Members)]
+			vm.push(&Runtime.ValueStruct{
+				Decl:    decl,
+				Members: members,
+			})
+
+		case OP_TUPLE:
+			elements := make([]Runtime.Value, operand)
+			for i, element := range vm.stack[len(vm.stack)-operand:] {
+				elements[i] = Runtime.Copy(element)
+			}
+
+			vm.stack = vm.stack[:len(vm.stack)-operand]
+			vm.push(&Runtime.ValueTuple{Elements: elements})
+
+		case OP_UNPACK:
+			for _, element := range vm.pop().(*Runtime.ValueTuple).Elements {
+				vm.push(element)
+			}
+
+		case OP_BOX:
+			vm.push(Runtime.Box(vm.pop()))
+
+		case OP_DEREFERENCE:
+			vm.push(Runtime.Dereference(vm.pop()))
+
+		case OP_STORE:
+			pointer := vm.pop()
+			Runtime.Store(pointer, Runtime.Copy(vm.pop()))
+
+		case OP_ADDRESS_GLOBAL:
+			vm.push(&Runtime.ValuePointer{Target: globalReference{vm: vm, index: operand}})
+
+		case OP_ADDRESS_INDEX:
+			index := vm.pop()
+			vm.push(Runtime.AddressIndex(vm.pop(), index))
+
+		case OP_ADDRESS_MEMBER:
+			name := frame.function.Constants[operand].(*Runtime.ValueString).Value
+			vm.push(Runtime.AddressMember(vm.pop(), name))
+
+		case OP_ADD, OP_SUBTRACT, OP_MULTIPLY, OP_DIVIDE, OP_MODULO,
+			OP_EQUAL, OP_NOT_EQUAL, OP_LESS, OP_LESS_EQUAL, OP_GREATER, OP_GREATER_EQUAL,
+			OP_BIT_AND, OP_BIT_OR, OP_BIT_XOR, OP_SHIFT_LEFT, OP_SHIFT_RIGHT:
+			right := vm.pop()
+			left := vm.pop()
+			vm.push(Runtime.BinaryOperation(binaryOperators[op], left, right))
+
+		case OP_NEGATE:
+			vm.push(Runtime.UnaryOperation(Token.MINUS, vm.pop()))
+
+		case OP_NOT:
+			vm.push(Runtime.UnaryOperation(Token.NOT, vm.pop()))
+
+		case OP_BIT_NOT:
+			vm.push(Runtime.UnaryOperation(Token.TILDE, vm.pop()))
+
+		case OP_UNION:
+			vm.push(&Runtime.ValueUnion{
+				DeclType: vm.program.Types[operand],
+				Index:    readOperand(code, frame.ip-2),
+				Payload:  Runtime.Copy(vm.pop()),
+			})
+
+		case OP_VARIANT:
+			vm.push(&Runtime.ValueInteger{Value: Runtime.Variant(vm.pop())})
+
+		case OP_PAYLOAD:
+			vm.push(Runtime.Payload(vm.pop()))
+
+		case OP_CAST:
+			vm.push(Runtime.Cast(vm.program.Types[operand], vm.pop()))
+
+		case OP_LEN:
+			vm.push(Runtime.Len(vm.pop()))
+
+		case OP_JUMP:
+			frame.ip = operand
+
+		case OP_JUMP_IF_FALSE:
+			if !asBoolean(vm.pop()) {
+				frame.ip = operand
+			}
+
+		case OP_JUMP_IF_TRUE_OR_POP:
+			if asBoolean(vm.peek()) {
+				frame.ip = operand
+			} else {
+				vm.pop()
+			}
+
+		case OP_JUMP_IF_FALSE_OR_POP:
+			if !asBoolean(vm.peek()) {
+				frame.ip = operand
+			} else {
+				vm.pop()
+			}
+
+		case OP_CALL:
+			vm.pushFrame(vm.program.Functions[operand])
+			frame = &vm.frames[len(vm.frames)-1]
+
+		case OP_CALL_VALUE:
+			callee := len(vm.stack) - operand - 1
+			function := Runtime.AsFunction(vm.stack[callee]).Closure.(*closure)
+			copy(vm.stack[callee:], vm.stack[callee+1:])
+			vm.stack = vm.stack[:len(vm.stack)-1]
+
+			vm.pushFrame(function.function)
+			frame = &vm.frames[len(vm.frames)-1]
+			frame.captures = function.captures
+
+		case OP_CLOSURE:
+			function := vm.program.Functions[operand]
+			captures := make([]Runtime.Value, len(function.Captures))
+			copy(captures, vm.stack[len(vm.stack)-len(captures):])
+			vm.stack = vm.stack[:len(vm.stack)-len(captures)]
+
+			vm.push(&Runtime.ValueFunction{
+				Decl:    function.Decl,
+				Closure: &closure{function: function, captures: captures},
+			})
+
+		case OP_GET_CAPTURE:
+			vm.push(frame.captures[operand])
+
+		case OP_CALL_NATIVE:
+			native := vm.program.Natives[operand]
+			arguments := vm.stack[len(vm.stack)-len(native.Type.Parameters):]
+			result := Runtime.CallNative(native, vm.host, arguments)
+			vm.stack = vm.stack[:len(vm.stack)-len(arguments)]
+			vm.push(result)
+
+		case OP_RETURN:
+			result := vm.pop()
+			vm.stack = vm.stack[:frame.base]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == depth {
+				return result
+			}
+
+			vm.push(result)
+			frame = &vm.frames[len(vm.frames)-1]
+
+		case OP_PRINT, OP_PRINTLN:
+			fmt.Fprint(vm.stdout, Runtime.Format(vm.pop()))
+			if op == OP_PRINTLN {
+				fmt.Fprintln(vm.stdout)
+			}
+
+		case OP_DEFER:
+			frame.defers = append(frame.defers, operand)
+
+		case OP_RUN_DEFERS:
+			frame.unwinding = append(frame.unwinding, unwind{
+				target: len(frame.defers) - operand,
+				resume: frame.ip,
+			})
+			nextDefer(frame)
+
+		case OP_END_DEFER:
+			nextDefer(frame)
+
+		default:
+			panic(fmt.Sprintf("unknown opcode: %v", op))
+		}
+	}
+}
+
+// RunProgram compiles and runs a type checked program the same way
+// Interpreter.InterpretProgram does and returns main's exit status or runtime error.
+func RunProgram(program AST.Program, args []string, stdout io.Writer) (int, error) {
+	compiled := Compile(program, nil)
+	if compiled.Main == -1 {
+		return 0, &Runtime.Error{Message: "main function not found"}
+	}
+
+	vm := NewVM(compiled, stdout)
+	if _, err := vm.Call(compiled.Init); err != nil {
+		return 0, err
+	}
+
+	main := compiled.Functions[compiled.Main]
+
+	var arguments []Runtime.Value
+	if main.Arity == 1 {
+		elements := make([]Runtime.Value, len(args))
+		for i, arg := range args {
+			elements[i] = &Runtime.ValueString{Value: arg}
+		}
+
+		arguments = append(arguments, &Runtime.ValueArray{
+			Elements: elements,
+			DeclType: findMainParameterType(program),
+		})
+	}
+
+	ret, err := vm.Call(main, arguments...)
+	if err != nil {
+		return 0, err
+	}
+
+	if ret, ok := ret.(*Runtime.ValueInteger); ok {
+		return ret.Value, nil
+	}
+
+	return 0, nil
+}
+
+func findMainParameterType(program AST.Program) *TS.Type {
+	for _, decl := range program.Declarations {
+		if fn, ok := decl.(*AST.DeclarationFunction); ok && fn.Tok.Lexeme == "main" {
+			return fn.DeclType.Parameters[0].DeclType
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+package VM
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"ion-go/AST"
+	"ion-go/Diagnostic"
+	"ion-go/Interpreter"
+	"ion-go/Lexer"
+	"ion-go/Parser"
+	"ion-go/Runtime"
+	"ion-go/TS"
+	"ion-go/TypeChecker"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// programs exercise control flow the example files don't reach
+var programs = map[string]string{
+	"defer_unwinding": `
+fn work(n: int) -> int {
+    defer println("leaving work " + n);
+    for (var i := 0; i < 10; i = i + 1) {
+        defer println("iteration " + i);
+        if (i == 1) {
+            continue;
+        }
+
+        if (i == n) {
+            defer println("found " + i);
+            return i * 10;
+        }
+    }
+
+    return -1;
+}
+
+fn main() -> void {
+    println(work(3));
+    println(work(20));
+}
+`,
+	"defer_in_defer": `
+fn main() -> void {
+    var x := 1;
+    defer {
+        defer println("inner " + x);
+        x = x + 1;
+        println("outer " + x);
+        return;
+    }
+
+    while (true) {
+        defer {
+            println("loop defer");
+            break;
+        }
+        x = x * 10;
+        if (x > 100) {
+            break;
+        }
+    }
+
+    println(x);
+}
+`,
+	"globals": `
+struct Point {
+    x: int,
+    y: int
+}
+
+var origin := Point.{0, 0};
+var counter := 10;
+
+fn bump() -> int {
+    counter = counter + 1;
+    return counter;
+}
+
+fn main() -> int {
+    bump();
+    bump();
+    var p := origin;
+    p.x = bump();
+    println(origin);
+    println(p);
+    return counter;
+}
+`,
+	"sibling_scopes": `
+fn main(args: []string) -> void {
+    {
+        var x := 1.5;
+        println(x);
+    }
+    for (var x := 0; x < 2; x = x + 1) {
+        var y := x * 2;
+        println(y);
+    }
+    var x := "after";
+    println(x + " " + len(args) + " " + args[1]);
+}
+`,
+	"short_circuit": `
+fn loud(name: string, value: bool) -> bool {
+    println(name);
+    return value;
+}
+
+fn main() -> void {
+    println(loud("a", true) || loud("b", true));
+    println(loud("c", false) && loud("d", true));
+    println(loud("e", false) || loud("f", true) && loud("g", false));
+}
+`,
+	"runtime_error": `
+fn main() -> void {
+    var xs := []int.[1, 2, 3];
+    println(xs[1]);
+    println(xs[3]);
+}
+`,
+	"stack_overflow": `
+fn down(n: int) -> int {
+    return down(n + 1) + 1;
+}
+
+fn main() -> void {
+    println(down(0));
+}
+`,
+	"error_in_defer": `
+fn divide(a: int, b: int) -> int {
+    defer println("divided " + a / b);
+    return a;
+}
+
+fn main() -> void {
+    println(divide(4, 2));
+    println(divide(1, 0));
+}
+`,
+}
+
+type outcome struct {
+	stdout   string
+	exitCode int
+	err      string
+}
+
+func run(engine func(AST.Program, []string, io.Writer) (int, error), program AST.Program) outcome {
+	var stdout bytes.Buffer
+	exitCode, err := engine(program, []string{"first", "second"}, &stdout)
+
+	result := outcome{stdout: stdout.String(), exitCode: exitCode}
+	if runtimeError, ok := err.(*Runtime.Error); ok {
+		result.err = runtimeError.Error() + "\n" + runtimeError.StackTrace()
+	} else if err != nil {
+		result.err = err.Error()
+	}
+
+	return result
+}
+
+func compile(filePath string, source []byte) (AST.Program, bool) {
+	tokenStream, diagnostics := Lexer.GenerateTokenStreamFromSource(filePath, source)
+	program, parseDiagnostics := Parser.ParseProgram(tokenStream)
+	diagnostics = append(diagnostics, parseDiagnostics...)
+	if Diagnostic.HasErrors(diagnostics) {
+		return program, false
+	}
+
+	return program, !Diagnostic.HasErrors(TypeChecker.TypeCheckProgram(program, nil))
+}
+
+func differential(t *testing.T, program AST.Program) {
+	expected := run(Interpreter.InterpretProgram, program)
+	actual := run(RunProgram, program)
+
+	if expected != actual {
+		t.Errorf("engines disagree\ntree: %+v\nvm:   %+v", expected, actual)
+	}
+}
+
+// TestDifferential runs every program through the tree walking Interpreter and the VM,
+// both have to print the same output and agree on the exit status or runtime error.
+func TestDifferential(t *testing.T) {
+	for name, source := range programs {
+		t.Run(name, func(t *testing.T) {
+			program, ok := compile(name+".ion", []byte(source))
+			if !ok {
+				t.Fatal("program doesn't compile")
+			}
+
+			differential(t, program)
+		})
+	}
+
+	err := filepath.WalkDir("..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() && path != ".." && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		if d.IsDir() || filepath.Ext(path) != ".ion" {
+			return nil
+		}
+
+		name, _ := filepath.Rel("..", path)
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			program, ok := compile(path, source)
+			if !ok {
+				t.Skip("program doesn't compile")
+			}
+
+			differential(t, program)
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	program, ok := compile("add.ion", []byte("fn add(a: int, b: int) -> int {\n    return a + b;\n}\n\nfn main() -> void {\n    println(add(1, 2));\n}\n"))
+	if !ok {
+		t.Fatal("program doesn't compile")
+	}
+
+	var listing bytes.Buffer
+	Compile(program, nil).Disassemble(&listing)
+
+	for _, expected := range []string{"== add (arity 2, 2 locals) ==", "ADD", "(add)", "PRINTLN"} {
+		if !strings.Contains(listing.String(), expected) {
+			t.Errorf("listing is missing %q:\n%s", expected, listing.String())
+		}
+	}
+}
+
+func TestNatives(t *testing.T) {
+	intType := TS.NewType(TS.INTEGER, nil, nil)
+	natives := Runtime.NewNatives()
+	err := natives.Register("twice", TS.NewType(TS.FUNCTION, intType, []TS.Parameter{{DeclType: intType}}),
+		func(host *Runtime.Host, args []Runtime.Value) (Runtime.Value, error) {
+			return &Runtime.ValueInteger{Value: args[0].(*Runtime.ValueInteger).Value * 2}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenStream, _ := Lexer.GenerateTokenStreamFromSource("natives.ion", []byte("fn main() -> void {\n    println(twice(1 + twice(3)));\n}\n"))
+	program, _ := Parser.ParseProgram(tokenStream)
+	if diagnostics := TypeChecker.TypeCheckProgram(program, natives); len(diagnostics) != 0 {
+		t.Fatal(diagnostics)
+	}
+
+	var stdout bytes.Buffer
+	compiled := Compile(program, natives)
+	if _, err := NewVM(compiled, &stdout).Call(compiled.Functions[compiled.Main]); err != nil {
+		t.Fatal(err)
+	}
+
+	if stdout.String() != "14\n" {
+		t.Errorf("expected 14, got %q", stdout.String())
+	}
+}
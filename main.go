@@ -1,32 +1,383 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"ion-go/AST"
+	"ion-go/Diagnostic"
+	"ion-go/Golden"
 	"ion-go/Interpreter"
 	"ion-go/JSON"
 	"ion-go/Lexer"
 	"ion-go/Parser"
+	"ion-go/Runtime"
+	"ion-go/Token"
 	"ion-go/TypeChecker"
+	"ion-go/VM"
+	"os"
+	"sort"
+	"strings"
 )
 
+const Version = "0.1.0"
+
+const (
+	EXIT_OK            = 0
+	EXIT_COMPILE_ERROR = 1 // lexer, parser or type checker diagnostics
+	EXIT_USAGE         = 2
+	EXIT_RUNTIME_ERROR = 3
+	EXIT_TEST_FAILURE  = 4
+)
+
+type Command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(args []string) int
+}
+
+var commands []Command
+
+// engines can run a type checked program, the tree walking Interpreter is the reference implementation
+var engines = map[string]Golden.Engine{
+	"tree": Interpreter.InterpretProgram,
+	"vm":   VM.RunProgram,
+}
+
+func init() {
+	commands = []Command{
+		{"run", "run [--engine tree|vm] <file> [args...]", "type check and run a program, args are passed to main", runCommand},
+		{"check", "check <file>", "report every lexer, parser and type error without running", checkCommand},
+		{"tokens", "tokens <file>", "print the token stream", tokensCommand},
+		{"ast", "ast <file> [--format tree|json]", "print the parsed program", astCommand},
+		{"bytecode", "bytecode <file>", "print the compiled bytecode", bytecodeCommand},
+		{"test", "test [--engine tree|vm] [files or dirs...]", "check programs against their OUTPUT blocks and ERROR annotations", testCommand},
+		{"version", "version", "print the ion version", versionCommand},
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: ion <command> [arguments]\n\nCommands:\n")
+	for _, command := range commands {
+		fmt.Fprintf(w, "    %-44s %s\n", command.Usage, command.Summary)
+	}
+
+	fmt.Fprintf(w, "\nExit codes: %d ok, %d compile error, %d usage error, %d runtime error, %d test failure\n",
+		EXIT_OK, EXIT_COMPILE_ERROR, EXIT_USAGE, EXIT_RUNTIME_ERROR, EXIT_TEST_FAILURE)
+}
+
+func newFlagSet(command Command) *flag.FlagSet {
+	flags := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ion %s\n", command.Usage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// parseFileArgs parses the command flags and returns the source file and whatever follows it
+func parseFileArgs(flags *flag.FlagSet, args []string) (string, []string, bool) {
+	if err := flags.Parse(args); err != nil {
+		return "", nil, false
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return "", nil, false
+	}
+
+	return flags.Arg(0), flags.Args()[1:], true
+}
+
+func engineFlag(flags *flag.FlagSet) *string {
+	var names []string
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return flags.String("engine", "tree", "execution engine, one of "+strings.Join(names, ", "))
+}
+
+func findEngine(name string) (Golden.Engine, bool) {
+	engine, ok := engines[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown engine %q, expected tree or vm\n", name)
+	}
+
+	return engine, ok
+}
+
+func reportDiagnostics(diagnostics []Diagnostic.Diagnostic, filePath string) {
+	source, _ := os.ReadFile(filePath)
+	fmt.Fprint(os.Stderr, Diagnostic.RenderAll(diagnostics, source))
+}
+
+func lex(filePath string) ([]Token.Token, bool) {
+	tokenStream, diagnostics := Lexer.GenerateTokenStream(filePath)
+	if Diagnostic.HasErrors(diagnostics) {
+		reportDiagnostics(diagnostics, filePath)
+		return nil, false
+	}
+
+	return tokenStream, true
+}
+
+// parse stops after the front end, typeCheck additionally runs the TypeChecker
+func parse(filePath string) (AST.Program, bool) {
+	tokenStream, diagnostics := Lexer.GenerateTokenStream(filePath)
+	program, parseDiagnostics := Parser.ParseProgram(tokenStream)
+	diagnostics = append(diagnostics, parseDiagnostics...)
+
+	if Diagnostic.HasErrors(diagnostics) {
+		reportDiagnostics(diagnostics, filePath)
+		return program, false
+	}
+
+	return program, true
+}
+
+func typeCheck(filePath string) (AST.Program, bool) {
+	program, ok := parse(filePath)
+	if !ok {
+		return program, false
+	}
+
+	diagnostics := TypeChecker.TypeCheckProgram(program, nil)
+	if Diagnostic.HasErrors(diagnostics) {
+		reportDiagnostics(diagnostics, filePath)
+		return program, false
+	}
+
+	return program, true
+}
+
+func runCommand(args []string) int {
+	flags := newFlagSet(findCommand("run"))
+	engineName := engineFlag(flags)
+	filePath, programArgs, ok := parseFileArgs(flags, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+
+	engine, ok := findEngine(*engineName)
+	if !ok {
+		return EXIT_USAGE
+	}
+
+	program, ok := typeCheck(filePath)
+	if !ok {
+		return EXIT_COMPILE_ERROR
+	}
+
+	if !hasMain(program) {
+		fmt.Fprintf(os.Stderr, "%s: error: main function not found\n", filePath)
+		return EXIT_COMPILE_ERROR
+	}
+
+	return interpret(engine, program, programArgs)
+}
+
+func hasMain(program AST.Program) bool {
+	for _, decl := range program.Declarations {
+		if fn, ok := decl.(*AST.DeclarationFunction); ok && fn.Tok.Lexeme == "main" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func interpret(engine Golden.Engine, program AST.Program, args []string) int {
+	exitCode, err := engine(program, args, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if runtimeError, ok := err.(*Runtime.Error); ok {
+			fmt.Fprint(os.Stderr, runtimeError.StackTrace())
+		}
+
+		return EXIT_RUNTIME_ERROR
+	}
+
+	return exitCode
+}
+
+func checkCommand(args []string) int {
+	flags := newFlagSet(findCommand("check"))
+	filePath, _, ok := parseFileArgs(flags, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+
+	if _, ok := typeCheck(filePath); !ok {
+		return EXIT_COMPILE_ERROR
+	}
+
+	return EXIT_OK
+}
+
+func tokensCommand(args []string) int {
+	flags := newFlagSet(findCommand("tokens"))
+	filePath, _, ok := parseFileArgs(flags, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+
+	tokenStream, ok := lex(filePath)
+	if !ok {
+		return EXIT_COMPILE_ERROR
+	}
+
+	for _, token := range tokenStream {
+		fmt.Printf("Type: %s(%s) | Line: %d | Column: %d\n", token.Kind, token.Lexeme, token.Line, token.Column)
+	}
+
+	return EXIT_OK
+}
+
+func astCommand(args []string) int {
+	flags := newFlagSet(findCommand("ast"))
+	format := flags.String("format", "tree", "output format, tree or json")
+	filePath, _, ok := parseFileArgs(flags, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+
+	// Flags are also accepted after the file, e.g. ion ast struct.ion --format json
+	if err := flags.Parse(flags.Args()[1:]); err != nil {
+		return EXIT_USAGE
+	}
+
+	if *format != "tree" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected tree or json\n", *format)
+		return EXIT_USAGE
+	}
+
+	program, ok := parse(filePath)
+	if !ok {
+		return EXIT_COMPILE_ERROR
+	}
+
+	if *format == "json" {
+		if err := JSON.Fprint(os.Stdout, program); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return EXIT_COMPILE_ERROR
+		}
+	} else {
+		JSON.PrintTree(os.Stdout, program)
+	}
+
+	return EXIT_OK
+}
+
+func bytecodeCommand(args []string) int {
+	flags := newFlagSet(findCommand("bytecode"))
+	filePath, _, ok := parseFileArgs(flags, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+
+	program, ok := typeCheck(filePath)
+	if !ok {
+		return EXIT_COMPILE_ERROR
+	}
+
+	VM.Compile(program, nil).Disassemble(os.Stdout)
+	return EXIT_OK
+}
+
+func testCommand(args []string) int {
+	flags := newFlagSet(findCommand("test"))
+	verbose := flags.Bool("v", false, "also list skipped files")
+	engineName := engineFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+
+	engine, ok := findEngine(*engineName)
+	if !ok {
+		return EXIT_USAGE
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := Golden.CollectFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_USAGE
+	}
+
+	passed, failed, skipped := 0, 0, 0
+	for _, file := range files {
+		result := Golden.RunFile(file, engine)
+		if result.Skipped {
+			skipped += 1
+			if *verbose {
+				fmt.Printf("SKIP %s (no OUTPUT block or ERROR annotations)\n", file)
+			}
+		} else if result.Passed() {
+			passed += 1
+			fmt.Printf("PASS %s\n", file)
+		} else {
+			failed += 1
+			fmt.Printf("FAIL %s\n", file)
+			for _, failure := range result.Failures {
+				fmt.Printf("    %s\n", strings.ReplaceAll(strings.TrimRight(failure, "\n"), "\n", "\n    "))
+			}
+		}
+	}
+
+	fmt.Printf("\n%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	if failed > 0 {
+		return EXIT_TEST_FAILURE
+	}
+
+	return EXIT_OK
+}
+
+func versionCommand(args []string) int {
+	fmt.Printf("ion version %s\n", Version)
+	return EXIT_OK
+}
+
+func findCommand(name string) Command {
+	for _, command := range commands {
+		if command.Name == name {
+			return command
+		}
+	}
+
+	panic("unknown command " + name)
+}
+
 func main() {
-	// tokenStream := Lexer.GenerateTokenStream("./factorial.ion")
-	// tokenStream := Lexer.GenerateTokenStream("./fib.ion")
-	// tokenStream := Lexer.GenerateTokenStream("./array.ion")
-	// tokenStream := Lexer.GenerateTokenStream("./fractal.ion")
-	// tokenStream := Lexer.GenerateTokenStream("./test.ion")
-	tokenStream := Lexer.GenerateTokenStream("./struct.ion")
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(EXIT_USAGE)
+	}
 
-	for i := 0; i < len(tokenStream); i++ {
-		token := tokenStream[i]
-		tokenType, tokenValue := token.Kind, token.Lexeme
-		fmt.Print("Type: ", tokenType, "(", tokenValue, ") | Line:", token.Line, "\n")
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		os.Exit(EXIT_OK)
+	case "--version", "-v":
+		name = "version"
 	}
 
-	program := Parser.ParseProgram(tokenStream)
-	//fmt.Printf("%+v\n", program)
+	for _, command := range commands {
+		if command.Name == name {
+			os.Exit(command.Run(os.Args[2:]))
+		}
+	}
 
-	TypeChecker.TypeCheckProgram(program)
-	JSON.PrettyPrint(program)
-	Interpreter.InterpretProgram(program)
+	fmt.Fprintf(os.Stderr, "ion: unknown command %q\n\n", name)
+	usage(os.Stderr)
+	os.Exit(EXIT_USAGE)
 }